@@ -1,16 +1,47 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/cli"
+	"github.com/wiktor-mazur/dns-go/src/dnssec"
+	"github.com/wiktor-mazur/dns-go/src/dnstap"
+	"github.com/wiktor-mazur/dns-go/src/logging"
+	"github.com/wiktor-mazur/dns-go/src/metrics"
 	"github.com/wiktor-mazur/dns-go/src/resolver"
+	"github.com/wiktor-mazur/dns-go/src/server/acl"
+	"github.com/wiktor-mazur/dns-go/src/server/admin_server"
+	"github.com/wiktor-mazur/dns-go/src/server/doh_server"
+	"github.com/wiktor-mazur/dns-go/src/server/doq_server"
+	"github.com/wiktor-mazur/dns-go/src/server/metrics_server"
+	"github.com/wiktor-mazur/dns-go/src/server/query_handler"
+	"github.com/wiktor-mazur/dns-go/src/server/rate_limiter"
+	"github.com/wiktor-mazur/dns-go/src/server/tcp_server"
+	"github.com/wiktor-mazur/dns-go/src/server/tls_certificate"
 	"github.com/wiktor-mazur/dns-go/src/server/udp_server"
+	"github.com/wiktor-mazur/dns-go/src/tracing"
 	"github.com/wiktor-mazur/dns-go/src/utils"
-	"log"
+	"log/slog"
 	"net"
+	"os"
+	"os/signal"
 	"sync"
+	"sync/atomic"
+	"syscall"
 )
 
+type server interface {
+	Start(wg *sync.WaitGroup) error
+	Shutdown(ctx context.Context) error
+}
+
 func main() {
+	// subcommands (e.g. "dns-go query example.com") are run instead of the server
+	if cli.IsCommand(os.Args[1:]) {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	var wg sync.WaitGroup
 
 	cfg, err := utils.LoadConfig()
@@ -18,22 +49,389 @@ func main() {
 		panic(fmt.Errorf("error loading config: %s", err.Error()))
 	}
 
-	nameResolver := resolver.New(resolver.Config{InternetRootServer: cfg.INTERNET_ROOT_SERVER})
+	setupLogging(cfg)
+
+	trustAnchors := make([]*dnssec.TrustAnchor, 0, len(cfg.DNSSEC_TRUST_ANCHORS))
+	for _, value := range cfg.DNSSEC_TRUST_ANCHORS {
+		anchor, err := dnssec.ParseTrustAnchor(value)
+		if err != nil {
+			panic(fmt.Errorf("error loading config: %s", err.Error()))
+		}
+
+		trustAnchors = append(trustAnchors, anchor)
+	}
+
+	upstreams := make([]resolver.Upstream, 0, len(cfg.FORWARD_UPSTREAMS))
+	for _, value := range cfg.FORWARD_UPSTREAMS {
+		upstream, err := resolver.ParseUpstream(value)
+		if err != nil {
+			panic(fmt.Errorf("error loading config: %s", err.Error()))
+		}
+
+		upstreams = append(upstreams, upstream)
+	}
+
+	if cfg.TRACING_ENABLED {
+		shutdownTracing, err := tracing.Setup(tracing.Config{
+			Endpoint:    cfg.TRACING_OTLP_ENDPOINT,
+			ServiceName: cfg.TRACING_SERVICE_NAME,
+			SampleRatio: cfg.TRACING_SAMPLE_RATIO,
+		})
+		if err != nil {
+			panic(fmt.Errorf("error starting tracing: %s", err.Error()))
+		}
+
+		defer flushTraces(shutdownTracing, cfg)
+	}
+
+	var tap *dnstap.Tap
+	if cfg.DNSTAP_ENABLED {
+		tap = newDnstap(cfg)
+		defer tap.Close()
+	}
+
+	resolverConfig := resolver.Config{
+		InternetRootServer: cfg.INTERNET_ROOT_SERVER,
+		Upstreams:          upstreams,
+		DNSSECValidation:   cfg.DNSSEC_VALIDATION,
+		TrustAnchors:       trustAnchors,
+	}
+
+	if tap != nil && cfg.DNSTAP_RESOLVER {
+		resolverConfig.Dnstap = tap
+	}
+
+	nameResolver := resolver.New(resolverConfig)
+
+	handler := query_handler.New(nameResolver)
+
+	if tap != nil && cfg.DNSTAP_CLIENT {
+		handler = handler.WithDnstap(tap)
+	}
+
+	if cfg.QUERY_LOG_ENABLED {
+		handler = handler.WithQueryLog(newQueryLog(cfg))
+	}
+
+	servers := make(map[string]server)
+	acls := make(map[string]*acl.ACL)
 
 	if cfg.UDP_ENABLED {
+		queueFullPolicy, err := udp_server.ParseQueueFullPolicy(cfg.UDP_QUEUE_FULL_POLICY)
+		if err != nil {
+			panic(fmt.Errorf("error loading config: %s", err.Error()))
+		}
+
+		var rateLimiter *rate_limiter.RateLimiter
+		if cfg.RRL_ENABLED {
+			exempt, err := utils.ParseCIDRs(cfg.RRL_EXEMPT)
+			if err != nil {
+				panic(fmt.Errorf("error loading config: %s", err.Error()))
+			}
+
+			rateLimiter = rate_limiter.New(rate_limiter.Config{
+				ResponsesPerSecond: cfg.RRL_RESPONSES_PER_SECOND,
+				Slip:               cfg.RRL_SLIP,
+				Window:             cfg.RRL_WINDOW,
+				IPv4PrefixLength:   cfg.RRL_IPV4_PREFIX_LENGTH,
+				IPv6PrefixLength:   cfg.RRL_IPV6_PREFIX_LENGTH,
+				Exempt:             exempt,
+				MaxEntries:         cfg.RRL_MAX_ENTRIES,
+			})
+
+			metrics.RegisterCounterFunc("rrl_dropped_responses_total", "Responses dropped by response rate limiting.", func() float64 {
+				return float64(rateLimiter.Dropped())
+			})
+			metrics.RegisterCounterFunc("rrl_slipped_responses_total", "Responses replaced with truncated ones by response rate limiting.", func() float64 {
+				return float64(rateLimiter.Slipped())
+			})
+		}
+
+		acls["UDP"] = newACL("UDP", cfg)
+
+		servers["UDP"] = udp_server.New(udp_server.Config{
+			ListenIP:        net.ParseIP(cfg.UDP_IP),
+			ListenPort:      cfg.UDP_PORT,
+			Workers:         cfg.UDP_WORKERS,
+			QueueSize:       cfg.UDP_QUEUE_SIZE,
+			QueueFullPolicy: queueFullPolicy,
+			RateLimiter:     rateLimiter,
+		}, handler.WithACL(acls["UDP"]))
+	}
+
+	if cfg.TCP_ENABLED {
+		acls["TCP"] = newACL("TCP", cfg)
+
+		servers["TCP"] = tcp_server.New(tcp_server.Config{
+			ListenIP:    net.ParseIP(cfg.TCP_IP),
+			ListenPort:  cfg.TCP_PORT,
+			IdleTimeout: cfg.TCP_IDLE_TIMEOUT,
+		}, handler.WithACL(acls["TCP"]))
+	}
+
+	certificates := make([]*tls_certificate.Loader, 0)
+
+	if cfg.TLS_ENABLED {
+		certificate, err := tls_certificate.NewLoader(cfg.TLS_CERT_FILE, cfg.TLS_KEY_FILE)
+		if err != nil {
+			panic(fmt.Errorf("error loading TLS certificate: %s", err.Error()))
+		}
+
+		certificates = append(certificates, certificate)
+		acls["TLS"] = newACL("TLS", cfg)
+
+		servers["TLS"] = tcp_server.New(tcp_server.Config{
+			ListenIP:    net.ParseIP(cfg.TLS_IP),
+			ListenPort:  cfg.TLS_PORT,
+			IdleTimeout: cfg.TLS_IDLE_TIMEOUT,
+			TLS:         certificate.TLSConfig("dot"),
+		}, handler.WithACL(acls["TLS"]))
+	}
+
+	if cfg.DOH_ENABLED {
+		trustedProxies, err := utils.ParseCIDRs(cfg.DOH_TRUSTED_PROXIES)
+		if err != nil {
+			panic(fmt.Errorf("error loading config: %s", err.Error()))
+		}
+
+		var tlsConfig *tls.Config
+		if cfg.DOH_TLS {
+			certificate, err := tls_certificate.NewLoader(cfg.DOH_CERT_FILE, cfg.DOH_KEY_FILE)
+			if err != nil {
+				panic(fmt.Errorf("error loading TLS certificate: %s", err.Error()))
+			}
+
+			certificates = append(certificates, certificate)
+			tlsConfig = certificate.TLSConfig("h2", "http/1.1")
+		}
+
+		acls["DoH"] = newACL("DoH", cfg)
+
+		servers["DoH"] = doh_server.New(doh_server.Config{
+			ListenIP:       net.ParseIP(cfg.DOH_IP),
+			ListenPort:     cfg.DOH_PORT,
+			TLS:            tlsConfig,
+			TrustedProxies: trustedProxies,
+		}, handler.WithACL(acls["DoH"]))
+	}
+
+	if cfg.DOQ_ENABLED {
+		certificate, err := tls_certificate.NewLoader(cfg.DOQ_CERT_FILE, cfg.DOQ_KEY_FILE)
+		if err != nil {
+			panic(fmt.Errorf("error loading TLS certificate: %s", err.Error()))
+		}
+
+		certificates = append(certificates, certificate)
+		acls["DoQ"] = newACL("DoQ", cfg)
+
+		servers["DoQ"] = doq_server.New(doq_server.Config{
+			ListenIP:    net.ParseIP(cfg.DOQ_IP),
+			ListenPort:  cfg.DOQ_PORT,
+			IdleTimeout: cfg.DOQ_IDLE_TIMEOUT,
+			TLS:         certificate.TLSConfig("doq"),
+		}, handler.WithACL(acls["DoQ"]))
+	}
+
+	if cfg.METRICS_ENABLED {
+		servers["metrics"] = metrics_server.New(metrics_server.Config{
+			ListenIP:   net.ParseIP(cfg.METRICS_IP),
+			ListenPort: cfg.METRICS_PORT,
+		})
+	}
+
+	var currentConfig atomic.Pointer[utils.Config]
+	currentConfig.Store(cfg)
+
+	if cfg.ADMIN_ENABLED {
+		if cfg.ADMIN_TOKEN == "" {
+			panic(fmt.Errorf("error loading config: ADMIN_TOKEN has to be set when the admin API is enabled"))
+		}
+
+		servers["admin"] = admin_server.New(admin_server.Config{
+			ListenIP:      net.ParseIP(cfg.ADMIN_IP),
+			ListenPort:    cfg.ADMIN_PORT,
+			Token:         cfg.ADMIN_TOKEN,
+			CurrentConfig: currentConfig.Load,
+		}, nameResolver)
+	}
+
+	for name, srv := range servers {
+		wg.Add(1)
+		go func(name string, srv server) {
+			err := srv.Start(&wg)
+			if err != nil {
+				slog.Error("Could not start the server", "server", name, "error", err)
+			}
+		}(name, srv)
+	}
+
+	var shutdown sync.WaitGroup
+	go shutdownOnSignal(servers, cfg, &shutdown)
+	go reloadOnSignal(acls, certificates, &currentConfig)
+
+	wg.Wait()
+
+	// servers stop accepting queries before the ones in progress are handled, so the shutdown has to finish as well
+	shutdown.Wait()
+}
+
+// setupLogging makes the logger configured with the log level and format the default one
+func setupLogging(cfg *utils.Config) {
+	level, err := logging.ParseLevel(cfg.LOG_LEVEL)
+	if err != nil {
+		panic(fmt.Errorf("error loading config: %s", err.Error()))
+	}
+
+	format, err := logging.ParseFormat(cfg.LOG_FORMAT)
+	if err != nil {
+		panic(fmt.Errorf("error loading config: %s", err.Error()))
+	}
+
+	slog.SetDefault(logging.New(os.Stderr, format, level))
+}
+
+func newQueryLog(cfg *utils.Config) *logging.QueryLog {
+	format, err := logging.ParseFormat(cfg.QUERY_LOG_FORMAT)
+	if err != nil {
+		panic(fmt.Errorf("error loading config: %s", err.Error()))
+	}
+
+	output := os.Stdout
+	if cfg.QUERY_LOG_FILE != "" {
+		output, err = os.OpenFile(cfg.QUERY_LOG_FILE, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			panic(fmt.Errorf("error opening query log: %s", err.Error()))
+		}
+	}
+
+	return logging.NewQueryLog(logging.New(output, format, slog.LevelInfo), cfg.QUERY_LOG_SAMPLE_RATE)
+}
+
+// flushTraces exports the spans which are still waiting, giving up after the shutdown timeout
+func flushTraces(shutdownTracing func(context.Context) error, cfg *utils.Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	err := shutdownTracing(ctx)
+	if err != nil {
+		slog.Warn("Could not export remaining traces", "error", err)
+	}
+}
+
+func newDnstap(cfg *utils.Config) *dnstap.Tap {
+	tap, err := dnstap.New(dnstap.Config{
+		Socket:     cfg.DNSTAP_SOCKET,
+		File:       cfg.DNSTAP_FILE,
+		Identity:   cfg.DNSTAP_IDENTITY,
+		Version:    "dns-go",
+		BufferSize: cfg.DNSTAP_BUFFER_SIZE,
+	})
+	if err != nil {
+		panic(fmt.Errorf("error starting dnstap: %s", err.Error()))
+	}
+
+	metrics.RegisterCounterFunc("dnstap_dropped_messages_total", "Messages not written to dnstap, because its buffer was full.", func() float64 {
+		return float64(tap.Dropped())
+	})
+
+	return tap
+}
+
+// shutdownOnSignal gracefully stops all servers after receiving SIGINT or SIGTERM
+func shutdownOnSignal(servers map[string]server, cfg *utils.Config, shutdown *sync.WaitGroup) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-signals
+
+	shutdown.Add(1)
+	defer shutdown.Done()
+
+	slog.Info("Shutting down, waiting for queries in progress", "signal", sig.String(), "timeout", cfg.SHUTDOWN_TIMEOUT)
+
+	// another signal stops the server immediately
+	signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	for name, srv := range servers {
 		wg.Add(1)
-		go func() {
-			server := udp_server.New(udp_server.Config{
-				ListenIP:   net.ParseIP(cfg.UDP_IP),
-				ListenPort: cfg.UDP_PORT,
-			}, nameResolver)
+		go func(name string, srv server) {
+			defer wg.Done()
 
-			err := server.Start(&wg)
+			err := srv.Shutdown(ctx)
 			if err != nil {
-				log.Printf("Could not start the UDP server: %s", err.Error())
+				slog.Warn("Could not gracefully shut down the server", "server", name, "error", err)
 			}
-		}()
+		}(name, srv)
 	}
 
 	wg.Wait()
 }
+
+// reloadOnSignal applies ACLs from the reloaded config and reloads TLS certificates after receiving SIGHUP
+func reloadOnSignal(acls map[string]*acl.ACL, certificates []*tls_certificate.Loader, currentConfig *atomic.Pointer[utils.Config]) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		for _, certificate := range certificates {
+			err := certificate.Reload()
+			if err != nil {
+				slog.Error("Could not reload TLS certificate", "error", err)
+			} else {
+				slog.Info("TLS certificate reloaded")
+			}
+		}
+
+		cfg, err := utils.ReloadConfig()
+		if err != nil {
+			slog.Error("Could not reload config", "error", err)
+			continue
+		}
+
+		currentConfig.Store(cfg)
+
+		for name, listenerACL := range acls {
+			listenerRules, err := aclRules[name](cfg)
+			if err != nil {
+				slog.Error("Could not reload ACL", "listener", name, "error", err)
+				continue
+			}
+
+			listenerACL.SetRules(listenerRules)
+			slog.Info("ACL reloaded", "listener", name)
+		}
+	}
+}
+
+// aclRules returns rules of the listener's ACL from the config
+var aclRules = map[string]func(cfg *utils.Config) (acl.Rules, error){
+	"UDP": func(cfg *utils.Config) (acl.Rules, error) {
+		return acl.ParseRules(cfg.UDP_ACL_QUERY_ALLOW, cfg.UDP_ACL_QUERY_DENY, cfg.UDP_ACL_RECURSION_ALLOW, cfg.UDP_ACL_RECURSION_DENY)
+	},
+	"TCP": func(cfg *utils.Config) (acl.Rules, error) {
+		return acl.ParseRules(cfg.TCP_ACL_QUERY_ALLOW, cfg.TCP_ACL_QUERY_DENY, cfg.TCP_ACL_RECURSION_ALLOW, cfg.TCP_ACL_RECURSION_DENY)
+	},
+	"TLS": func(cfg *utils.Config) (acl.Rules, error) {
+		return acl.ParseRules(cfg.TLS_ACL_QUERY_ALLOW, cfg.TLS_ACL_QUERY_DENY, cfg.TLS_ACL_RECURSION_ALLOW, cfg.TLS_ACL_RECURSION_DENY)
+	},
+	"DoH": func(cfg *utils.Config) (acl.Rules, error) {
+		return acl.ParseRules(cfg.DOH_ACL_QUERY_ALLOW, cfg.DOH_ACL_QUERY_DENY, cfg.DOH_ACL_RECURSION_ALLOW, cfg.DOH_ACL_RECURSION_DENY)
+	},
+	"DoQ": func(cfg *utils.Config) (acl.Rules, error) {
+		return acl.ParseRules(cfg.DOQ_ACL_QUERY_ALLOW, cfg.DOQ_ACL_QUERY_DENY, cfg.DOQ_ACL_RECURSION_ALLOW, cfg.DOQ_ACL_RECURSION_DENY)
+	},
+}
+
+func newACL(name string, cfg *utils.Config) *acl.ACL {
+	rules, err := aclRules[name](cfg)
+	if err != nil {
+		panic(fmt.Errorf("error loading config: %s", err.Error()))
+	}
+
+	return acl.New(rules)
+}
@@ -5,12 +5,16 @@ import (
 	"strings"
 )
 
+// DNSBufferSize is the maximum size of a DNS message sent over UDP without EDNS (RFC 1035, section 4.2.1)
 const DNSBufferSize = 512
 
-var PosTooLargeErr = fmt.Errorf("pos must not be larger than %d", DNSBufferSize)
+// MaxDNSBufferSize is the maximum size of any DNS message
+const MaxDNSBufferSize = 65535
 
-func bufferOverflowErr(pos uint) error {
-	return fmt.Errorf("buffer overflow (tried to access %d byte of %d total)", pos, DNSBufferSize)
+var PosTooLargeErr = fmt.Errorf("pos must not be larger than buffer size")
+
+func bufferOverflowErr(pos uint, size uint) error {
+	return fmt.Errorf("buffer overflow (tried to access %d byte of %d total)", pos, size)
 }
 
 type BytePacketBuffer struct {
@@ -25,6 +29,17 @@ func NewBytePacketBuffer() *BytePacketBuffer {
 	}
 }
 
+func NewBytePacketBufferWithSize(size uint) *BytePacketBuffer {
+	if size > MaxDNSBufferSize {
+		size = MaxDNSBufferSize
+	}
+
+	return &BytePacketBuffer{
+		buffer: make([]byte, size),
+		pos:    0,
+	}
+}
+
 func BytePacketBufferFromRawBuffer(buf []byte) *BytePacketBuffer {
 	return &BytePacketBuffer{
 		buffer: buf,
@@ -32,6 +47,10 @@ func BytePacketBufferFromRawBuffer(buf []byte) *BytePacketBuffer {
 	}
 }
 
+func (v *BytePacketBuffer) GetSize() uint {
+	return uint(len(v.buffer))
+}
+
 func (v *BytePacketBuffer) GetPos() uint {
 	return v.pos
 }
@@ -41,7 +60,7 @@ func (v *BytePacketBuffer) GetBytes() []byte {
 }
 
 func (v *BytePacketBuffer) Seek(pos uint) error {
-	if pos > DNSBufferSize-1 {
+	if pos > v.GetSize() {
 		return PosTooLargeErr
 	}
 
@@ -51,16 +70,16 @@ func (v *BytePacketBuffer) Seek(pos uint) error {
 }
 
 func (v *BytePacketBuffer) ReadByteAt(pos uint) (byte, error) {
-	if pos > DNSBufferSize-1 {
-		return 0, PosTooLargeErr
+	if pos >= v.GetSize() {
+		return 0, bufferOverflowErr(pos+1, v.GetSize())
 	}
 
 	return v.buffer[pos], nil
 }
 
 func (v *BytePacketBuffer) ReadAtRange(pos uint, len uint) ([]byte, error) {
-	if pos+len > DNSBufferSize {
-		return nil, bufferOverflowErr(pos + len)
+	if pos+len > v.GetSize() {
+		return nil, bufferOverflowErr(pos+len, v.GetSize())
 	}
 
 	result := make([]byte, len)
@@ -77,13 +96,25 @@ func (v *BytePacketBuffer) ReadAtRange(pos uint, len uint) ([]byte, error) {
 	return result, nil
 }
 
-func (v *BytePacketBuffer) ReadByte() (byte, error) {
-	result := v.buffer[v.pos]
+func (v *BytePacketBuffer) ReadBytes(len uint) ([]byte, error) {
+	result, err := v.ReadAtRange(v.pos, len)
+	if err != nil {
+		return nil, err
+	}
+
+	v.pos += len
 
-	if v.pos+1 < DNSBufferSize {
-		v.pos += 1
+	return result, nil
+}
+
+func (v *BytePacketBuffer) ReadByte() (byte, error) {
+	result, err := v.ReadByteAt(v.pos)
+	if err != nil {
+		return 0, err
 	}
 
+	v.pos += 1
+
 	return result, nil
 }
 
@@ -184,7 +215,7 @@ func (v *BytePacketBuffer) ReadLabel() (string, error) {
 }
 
 func (v *BytePacketBuffer) SetByte(pos uint, byte byte) error {
-	if pos > DNSBufferSize-1 {
+	if pos >= v.GetSize() {
 		return PosTooLargeErr
 	}
 
@@ -208,8 +239,8 @@ func (v *BytePacketBuffer) SetUint16(pos uint, val uint16) error {
 }
 
 func (v *BytePacketBuffer) WriteByte(byte byte) error {
-	if v.pos >= DNSBufferSize-1 {
-		return bufferOverflowErr(v.pos + 1)
+	if v.pos >= v.GetSize() {
+		return bufferOverflowErr(v.pos+1, v.GetSize())
 	}
 
 	v.buffer[v.pos] = byte
@@ -246,8 +277,24 @@ func (v *BytePacketBuffer) WriteUint32(uint32 uint32) error {
 	return nil
 }
 
+func (v *BytePacketBuffer) WriteBytes(data []byte) error {
+	for _, b := range data {
+		err := v.WriteByte(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (v *BytePacketBuffer) WriteLabel(label string) error {
 	for _, label := range strings.Split(label, ".") {
+		// root domain (and trailing dot) is represented only by the terminating zero byte
+		if len(label) == 0 {
+			continue
+		}
+
 		if len(label) > 0x3f {
 			return fmt.Errorf("given label is too long")
 		}
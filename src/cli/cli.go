@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command runs a single subcommand with the arguments following its name
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"decode": {"print a message in the wire format as text or JSON", runDecode},
+	"encode": {"write a message described as text or JSON in the wire format", runEncode},
+	"pcap":   {"print DNS messages found in a pcap or pcapng capture, and the ones which could not be parsed", runPcap},
+	"query":  {"resolve a name and print the response, like dig", runQuery},
+	"trace":  {"resolve a name from the root, printing every referral on the way, like dig +trace", runTrace},
+}
+
+// IsCommand tells whether the first argument of the program names a subcommand, rather than starting the server
+func IsCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	_, ok := commands[args[0]]
+
+	return ok || args[0] == "help"
+}
+
+// Run runs the subcommand named by the first argument, returning the exit code of the program
+func Run(args []string) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	err := cmd.run(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dns-go %s: %s\n", args[0], err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: dns-go [command] [flags] [arguments]")
+	fmt.Fprintln(w, "\nWithout a command, the server is started. Commands:")
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].description)
+	}
+
+	fmt.Fprintln(w, "\nRun \"dns-go [command] -h\" for the command's flags.")
+}
+
+// parseFlags parses flags given anywhere among the arguments (not only before them, as flag package does),
+// returning the positional arguments
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"io"
+	"os"
+	"strings"
+)
+
+func runDecode(args []string) error {
+	flags := flag.NewFlagSet("decode", flag.ContinueOnError)
+	hexMessage := flags.String("hex", "", "message given as a hex string instead of a file (whitespace is ignored), \"-\" reads the hex string from stdin")
+	jsonOutput := flags.Bool("json", false, "print the message in the JSON format (RFC 8427)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dns-go decode [flags] [file]")
+		fmt.Fprintln(flags.Output(), "Prints the message in the wire format read from the file (stdin if not given or \"-\").")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) > 1 || (len(positional) == 1 && *hexMessage != "") {
+		flags.Usage()
+		return errors.New("message has to be given either in a single file or as a hex string")
+	}
+
+	var raw []byte
+
+	switch {
+	case *hexMessage == "-":
+		raw, err = readInput("-")
+		if err == nil {
+			raw, err = decodeHex(string(raw))
+		}
+	case *hexMessage != "":
+		raw, err = decodeHex(*hexMessage)
+	case len(positional) == 1:
+		raw, err = readInput(positional[0])
+	default:
+		raw, err = readInput("-")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(raw) > buffer.MaxDNSBufferSize {
+		return fmt.Errorf("message is too long (%d bytes)", len(raw))
+	}
+
+	packet, err := protocol.DnsPacketFromRawBuffer(raw)
+	if err != nil {
+		return fmt.Errorf("could not parse the message (%d bytes): %s", len(raw), err.Error())
+	}
+
+	if *jsonOutput {
+		return writeJSON(os.Stdout, packet)
+	}
+
+	writePresentation(os.Stdout, packet)
+	fmt.Printf("\n;; MSG SIZE: %d\n", len(raw))
+
+	return nil
+}
+
+// readInput reads the whole file, or stdin if the path is "-"
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(path)
+}
+
+func decodeHex(value string) ([]byte, error) {
+	result, err := hex.DecodeString(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex string: %s", err.Error())
+	}
+
+	return result, nil
+}
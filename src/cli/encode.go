@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"os"
+	"strings"
+)
+
+func runEncode(args []string) error {
+	flags := flag.NewFlagSet("encode", flag.ContinueOnError)
+	format := flags.String("format", "", "format of the description: text (as printed by decode) or json (RFC 8427); detected from the content if not set")
+	output := flags.String("o", "-", "file the message is written to, \"-\" for stdout")
+	hexOutput := flags.Bool("hex", false, "write the message as a hex string instead of the wire format")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dns-go encode [flags] [file]")
+		fmt.Fprintln(flags.Output(), "Writes the message described in the file (stdin if not given or \"-\") in the wire format.")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) > 1 {
+		flags.Usage()
+		return errors.New("description has to be given in a single file")
+	}
+
+	path := "-"
+	if len(positional) == 1 {
+		path = positional[0]
+	}
+
+	description, err := readInput(path)
+	if err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = "text"
+
+		if strings.HasPrefix(strings.TrimSpace(string(description)), "{") {
+			*format = "json"
+		}
+	}
+
+	var packet *protocol.DnsPacket
+
+	switch *format {
+	case "text":
+		packet, err = parseMessageText(string(description))
+	case "json":
+		packet = protocol.NewDnsPacket()
+		err = json.Unmarshal(description, packet)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid description: %s", err.Error())
+	}
+
+	raw, err := packet.ToRawBufferWithSize(buffer.MaxDNSBufferSize)
+	if err != nil {
+		return err
+	}
+
+	if *hexOutput {
+		raw = []byte(hex.EncodeToString(raw) + "\n")
+	}
+
+	if *output == "-" {
+		_, err = os.Stdout.Write(raw)
+		return err
+	}
+
+	return os.WriteFile(*output, raw, 0644)
+}
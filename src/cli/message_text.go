@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headerLine = regexp.MustCompile(`^;; ->>HEADER<<- opcode: (\w+), status: (\w+), id: (\d+)`)
+	ednsLine   = regexp.MustCompile(`^; EDNS: version: (\d+), flags:([^;]*); udp: (\d+)`)
+)
+
+/*
+ * parseMessageText reads the message from the text in the format written by writePresentation, so that the output
+ * of decode command can be edited and encoded again:
+ * - ";; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 1234" sets opcode, result code and ID (random if not given)
+ * - ";; flags: qr rd" sets the header flags
+ * - "; EDNS: version: 0, flags: do; udp: 1232" adds OPT record to the additional section
+ * - ";; QUESTION SECTION:" is followed by questions (";example.com. IN A"), and ";; ANSWER SECTION:",
+ *   ";; AUTHORITY SECTION:" and ";; ADDITIONAL SECTION:" by records in the presentation format
+ * Other lines starting with ";" are comments, and counts of the records are ignored, as they follow from the sections.
+ */
+func parseMessageText(text string) (*protocol.DnsPacket, error) {
+	packet := protocol.NewDnsPacket()
+	section := ""
+	var opt *dns_record.OPT
+
+	for idx, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+
+		var err error
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, ";; ->>HEADER<<-"):
+			err = parseHeaderLine(line, &packet.Header)
+		case strings.HasPrefix(line, ";; flags:"):
+			err = parseFlagsLine(line, &packet.Header)
+		case strings.HasPrefix(line, "; EDNS:"):
+			opt, err = parseEDNSLine(line)
+		case strings.HasPrefix(line, ";; ") && strings.HasSuffix(line, " SECTION:"):
+			section = strings.TrimSuffix(strings.TrimPrefix(line, ";; "), " SECTION:")
+			if section != "QUESTION" && section != "ANSWER" && section != "AUTHORITY" && section != "ADDITIONAL" {
+				err = fmt.Errorf("unknown section %s", section)
+			}
+		case section == "QUESTION" && strings.HasPrefix(line, ";") && !strings.HasPrefix(line, ";;"):
+			err = parseQuestionLine(line, packet)
+		case strings.HasPrefix(line, ";"):
+			continue
+		default:
+			err = parseRecordLine(line, section, packet)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", idx+1, err.Error())
+		}
+	}
+
+	// OPT goes to the additional section after all other records
+	if opt != nil {
+		packet.AddResource(opt)
+	}
+
+	return packet, nil
+}
+
+func parseHeaderLine(line string, header *protocol.DnsHeader) error {
+	match := headerLine.FindStringSubmatch(line)
+	if match == nil {
+		return fmt.Errorf("header must be given as \"opcode: <opcode>, status: <rcode>, id: <id>\"")
+	}
+
+	opcode, ok := parseMnemonic(match[1], func(value uint8) string {
+		opcode := common.OPCODE(value)
+		return opcode.String()
+	})
+	if !ok {
+		return fmt.Errorf("unknown opcode %q", match[1])
+	}
+
+	resultCode, ok := parseMnemonic(match[2], func(value uint8) string {
+		resultCode := common.ResultCode(value)
+		return resultCode.String()
+	})
+	if !ok {
+		return fmt.Errorf("unknown status %q", match[2])
+	}
+
+	id, err := strconv.ParseUint(match[3], 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid id %q", match[3])
+	}
+
+	header.OPCODE = common.OPCODE(opcode)
+	header.ResultCode = common.ResultCode(resultCode)
+	header.ID = uint16(id)
+
+	return nil
+}
+
+// unknownMnemonic is what OPCODE and ResultCode give as their mnemonic when they don't have one
+const unknownMnemonic = "UNKNOWN"
+
+// headerFieldString returns the mnemonic of the 4-bit header field, or its number if it has none,
+// so that parseMnemonic reads back the same value
+func headerFieldString(value uint8, mnemonic string) string {
+	if mnemonic == unknownMnemonic {
+		return strconv.Itoa(int(value))
+	}
+
+	return mnemonic
+}
+
+// parseMnemonic returns the 4-bit header field (opcode or result code) given its mnemonic or number
+func parseMnemonic(value string, mnemonic func(value uint8) string) (uint8, bool) {
+	number, err := strconv.ParseUint(value, 10, 4)
+	if err == nil {
+		return uint8(number), true
+	}
+
+	// "UNKNOWN" is shared by all the values without a mnemonic, so it doesn't tell which one is meant
+	if strings.ToUpper(value) == unknownMnemonic {
+		return 0, false
+	}
+
+	for candidate := uint8(0); candidate < 16; candidate++ {
+		if mnemonic(candidate) == strings.ToUpper(value) {
+			return candidate, true
+		}
+	}
+
+	return 0, false
+}
+
+func parseFlagsLine(line string, header *protocol.DnsHeader) error {
+	flags := strings.SplitN(strings.TrimPrefix(line, ";; flags:"), ";", 2)[0]
+
+	for _, flag := range strings.Fields(flags) {
+		switch strings.ToLower(flag) {
+		case "qr":
+			header.IsResponse = true
+		case "aa":
+			header.AuthoritativeAnswer = true
+		case "tc":
+			header.TruncatedMessage = true
+		case "rd":
+			header.RecursionDesired = true
+		case "ra":
+			header.RecursionAvailable = true
+		case "z":
+			header.DNSSECAvailable = true
+		case "ad":
+			header.AuthedData = true
+		case "cd":
+			header.CheckingDisabled = true
+		default:
+			return fmt.Errorf("unknown flag %q", flag)
+		}
+	}
+
+	return nil
+}
+
+func parseEDNSLine(line string) (*dns_record.OPT, error) {
+	match := ednsLine.FindStringSubmatch(line)
+	if match == nil {
+		return nil, fmt.Errorf("EDNS must be given as \"version: <version>, flags:[ do]; udp: <size>\"")
+	}
+
+	// only version 0 is defined (RFC 6891, section 6.1.3)
+	if match[1] != "0" {
+		return nil, fmt.Errorf("unsupported EDNS version %s", match[1])
+	}
+
+	dnssecOk := false
+	for _, flag := range strings.Fields(match[2]) {
+		if flag != "do" {
+			return nil, fmt.Errorf("unknown EDNS flag %q", flag)
+		}
+
+		dnssecOk = true
+	}
+
+	size, err := strconv.ParseUint(match[3], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UDP payload size %q", match[3])
+	}
+
+	return dns_record.NewOPT(uint16(size), dnssecOk), nil
+}
+
+// parseQuestionLine reads the question given as ";<name> [class] <type>"
+func parseQuestionLine(line string, packet *protocol.DnsPacket) error {
+	fields := strings.Fields(strings.TrimPrefix(line, ";"))
+	if len(fields) < 2 || len(fields) > 3 {
+		return fmt.Errorf("question must consist of name, optionally class, and type")
+	}
+
+	question := protocol.NewDnsQuestion()
+	question.Name = strings.TrimSuffix(fields[0], ".")
+
+	if len(fields) == 3 {
+		class, err := common.ParseClass(fields[1])
+		if err != nil {
+			return err
+		}
+
+		question.Class = class
+	}
+
+	qType, err := common.ParseQueryType(fields[len(fields)-1])
+	if err != nil {
+		return err
+	}
+
+	question.QueryType = qType
+	packet.AddQuestion(*question)
+
+	return nil
+}
+
+func parseRecordLine(line string, section string, packet *protocol.DnsPacket) error {
+	record, err := protocol.ParseRecord(line)
+	if err != nil {
+		return err
+	}
+
+	switch section {
+	case "ANSWER":
+		packet.AddAnswer(record)
+	case "AUTHORITY":
+		packet.AddAuthority(record)
+	case "ADDITIONAL":
+		packet.AddResource(record)
+	default:
+		return fmt.Errorf("record has to follow the answer, authority or additional section's heading")
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"io"
+	"strings"
+)
+
+// writeJSON prints the message in the JSON format (RFC 8427)
+func writeJSON(w io.Writer, packet *protocol.DnsPacket) error {
+	result, err := json.MarshalIndent(packet, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(result))
+
+	return err
+}
+
+// writePresentation prints the message the way dig does: the header, EDNS pseudo-section and sections of records
+// in the presentation format
+func writePresentation(w io.Writer, packet *protocol.DnsPacket) {
+	header := &packet.Header
+
+	opcode := headerFieldString(uint8(header.OPCODE), header.OPCODE.String())
+	status := headerFieldString(uint8(header.ResultCode), header.ResultCode.String())
+
+	fmt.Fprintf(w, ";; ->>HEADER<<- opcode: %s, status: %s, id: %d\n", opcode, status, header.ID)
+	fmt.Fprintf(w, ";; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n", headerFlags(header),
+		len(packet.Questions), len(packet.Answers), len(packet.Authorities), len(packet.Resources))
+
+	if opt := packet.GetOPT(); opt != nil {
+		flags := ""
+		if opt.IsDNSSECOk() {
+			flags = " do"
+		}
+
+		fmt.Fprintf(w, "\n;; OPT PSEUDOSECTION:\n; EDNS: version: %d, flags:%s; udp: %d\n", opt.GetVersion(), flags, opt.GetUDPPayloadSize())
+	}
+
+	if len(packet.Questions) > 0 {
+		fmt.Fprintln(w, "\n;; QUESTION SECTION:")
+
+		for _, question := range packet.Questions {
+			fmt.Fprintf(w, ";%s\t\t%s\t%s\n", fqdn(question.Name), classMnemonic(question.Class), question.QueryType.String())
+		}
+	}
+
+	writeSection(w, "ANSWER", packet.Answers)
+	writeSection(w, "AUTHORITY", packet.Authorities)
+	writeSection(w, "ADDITIONAL", packet.Resources)
+}
+
+func writeSection(w io.Writer, name string, records []protocol.DnsRecord) {
+	lines := make([]string, 0, len(records))
+
+	for _, record := range records {
+		// OPT is shown in its pseudo-section
+		if record.GetType() != common.OPT {
+			lines = append(lines, record.PresentationString())
+		}
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n;; %s SECTION:\n%s\n", name, strings.Join(lines, "\n"))
+}
+
+func headerFlags(header *protocol.DnsHeader) string {
+	flags := []struct {
+		name string
+		set  bool
+	}{
+		{"qr", header.IsResponse},
+		{"aa", header.AuthoritativeAnswer},
+		{"tc", header.TruncatedMessage},
+		{"rd", header.RecursionDesired},
+		{"ra", header.RecursionAvailable},
+		{"z", header.DNSSECAvailable},
+		{"ad", header.AuthedData},
+		{"cd", header.CheckingDisabled},
+	}
+
+	result := make([]string, 0, len(flags))
+
+	for _, flag := range flags {
+		if flag.set {
+			result = append(result, flag.name)
+		}
+	}
+
+	return strings.Join(result, " ")
+}
+
+// fqdn returns name in the fully qualified form used by the presentation format ("example.com.", "." for the root)
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// classMnemonic returns the mnemonic of the given class or its generic "CLASSnnn" form (RFC 3597, section 5) if it's unknown
+func classMnemonic(class common.Class) string {
+	if class == common.IN {
+		return class.String()
+	}
+
+	return fmt.Sprintf("CLASS%d", class)
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/pcap"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"os"
+	"time"
+)
+
+// pcapMessage is the message found in the capture, as printed with -json flag
+type pcapMessage struct {
+	Packet      int                 `json:"packet"`
+	Time        *time.Time          `json:"time,omitempty"`
+	Transport   string              `json:"transport"`
+	Source      string              `json:"source"`
+	Destination string              `json:"destination"`
+	Size        int                 `json:"size"`
+	Message     *protocol.DnsPacket `json:"message,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+func runPcap(args []string) error {
+	flags := flag.NewFlagSet("pcap", flag.ContinueOnError)
+	port := flags.Uint("port", 53, "port DNS messages are sent to or from")
+	jsonOutput := flags.Bool("json", false, "print each message as a JSON object in a separate line, with the message in the JSON format (RFC 8427)")
+	failuresOnly := flags.Bool("failures", false, "print only the messages which could not be parsed")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dns-go pcap [flags] [file]")
+		fmt.Fprintln(flags.Output(), "Prints DNS messages sent over UDP and TCP found in the pcap or pcapng capture (read from stdin if not given or \"-\").")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) > 1 {
+		flags.Usage()
+		return errors.New("capture has to be given in a single file")
+	}
+
+	if *port == 0 || *port > 0xFFFF {
+		return fmt.Errorf("invalid port %d", *port)
+	}
+
+	input := os.Stdin
+	if len(positional) == 1 && positional[0] != "-" {
+		input, err = os.Open(positional[0])
+		if err != nil {
+			return err
+		}
+
+		defer input.Close()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+
+	stats, err := pcap.ReadMessages(input, uint16(*port), func(message *pcap.Message) error {
+		if *failuresOnly && message.Err == nil {
+			return nil
+		}
+
+		if *jsonOutput {
+			return encoder.Encode(newPcapMessage(message))
+		}
+
+		_, err := fmt.Println(pcapMessageSummary(message))
+
+		return err
+	})
+
+	// stats don't mix with the messages printed as JSON
+	summary := os.Stdout
+	if *jsonOutput {
+		summary = os.Stderr
+	}
+
+	fmt.Fprintf(summary, ";; %d packets, %d DNS messages, %d parse failures, %d IP fragments and %d undecoded packets skipped\n",
+		stats.Packets, stats.Messages, stats.Failures, stats.Fragments, stats.Undecoded)
+
+	return err
+}
+
+func newPcapMessage(message *pcap.Message) *pcapMessage {
+	result := &pcapMessage{
+		Packet:      message.PacketNumber,
+		Transport:   message.Transport,
+		Source:      message.Source.String(),
+		Destination: message.Destination.String(),
+		Size:        len(message.Raw),
+		Message:     message.DNS,
+	}
+
+	if !message.Time.IsZero() {
+		result.Time = &message.Time
+	}
+
+	if message.Err != nil {
+		result.Error = message.Err.Error()
+	}
+
+	return result
+}
+
+// pcapMessageSummary describes the message in a single line: where it was found, its header and question
+func pcapMessageSummary(message *pcap.Message) string {
+	timestamp := "-"
+	if !message.Time.IsZero() {
+		timestamp = message.Time.Format(time.RFC3339Nano)
+	}
+
+	result := fmt.Sprintf("#%d %s %s %s -> %s ", message.PacketNumber, timestamp, message.Transport, message.Source.String(), message.Destination.String())
+
+	if message.Err != nil {
+		return result + fmt.Sprintf("PARSE FAILURE (%d bytes): %s", len(message.Raw), message.Err.Error())
+	}
+
+	packet := message.DNS
+	header := &packet.Header
+
+	kind := "query"
+	if header.IsResponse {
+		kind = "response"
+	}
+
+	result += fmt.Sprintf("%s id=%d %s %s", kind, header.ID, headerFieldString(uint8(header.OPCODE), header.OPCODE.String()),
+		headerFieldString(uint8(header.ResultCode), header.ResultCode.String()))
+
+	for _, question := range packet.Questions {
+		result += fmt.Sprintf(" %s %s %s", fqdn(question.Name), classMnemonic(question.Class), question.QueryType.String())
+	}
+
+	result += fmt.Sprintf(" [%s]", headerFlags(header))
+
+	if header.IsResponse {
+		result += fmt.Sprintf(" answers=%d authority=%d additional=%d", len(packet.Answers), len(packet.Authorities), len(packet.Resources))
+	}
+
+	return result + fmt.Sprintf(" size=%d", len(message.Raw))
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/dnssec"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"github.com/wiktor-mazur/dns-go/src/resolver"
+	"github.com/wiktor-mazur/dns-go/src/utils"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// queryTimeout is the maximum time of the whole resolution, which may take many lookups when done from the root
+const queryTimeout = 30 * time.Second
+
+type queryOptions struct {
+	server    string
+	transport string
+	rd        bool
+	cd        bool
+	do        bool
+	bufSize   uint
+	json      bool
+	timing    bool
+}
+
+func runQuery(args []string) error {
+	var options queryOptions
+
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	flags.StringVar(&options.server, "server", "", "name server to query: IP address, host:port or upstream URL (e.g. tls://1.1.1.1); if not set, the name is resolved recursively from the root")
+	flags.StringVar(&options.transport, "transport", "udp", "transport used to query the server given without a scheme: udp, tcp, tls or https")
+	flags.BoolVar(&options.rd, "rd", true, "set RD (recursion desired) bit")
+	flags.BoolVar(&options.cd, "cd", false, "set CD (checking disabled) bit")
+	flags.BoolVar(&options.do, "do", false, "set DO (DNSSEC OK) bit, requesting DNSSEC records")
+	flags.UintVar(&options.bufSize, "bufsize", 1232, "EDNS UDP payload size advertised in the query, 0 disables EDNS")
+	flags.BoolVar(&options.json, "json", false, "print the response in the JSON format (RFC 8427)")
+	flags.BoolVar(&options.timing, "timing", false, "print how long the query took")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dns-go query [flags] name [type]")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) < 1 || len(positional) > 2 {
+		flags.Usage()
+		return errors.New("name and optionally type have to be given")
+	}
+
+	qType := common.A
+	if len(positional) == 2 {
+		qType, err = common.ParseQueryType(positional[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.bufSize > 0xFFFF {
+		return fmt.Errorf("EDNS buffer size %d is too large", options.bufSize)
+	}
+
+	if options.do && options.bufSize == 0 {
+		return errors.New("DO bit can only be sent with EDNS")
+	}
+
+	query := newQuery(positional[0], qType, &options)
+
+	var response *protocol.DnsPacket
+	var server string
+	start := time.Now()
+
+	if options.server != "" {
+		response, server, err = queryServer(query, &options)
+	} else {
+		response, server, err = queryRecursively(query)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+
+	if options.json {
+		err = writeJSON(os.Stdout, response)
+		if err != nil {
+			return err
+		}
+
+		// JSON on stdout stays parsable
+		if options.timing {
+			fmt.Fprintf(os.Stderr, ";; Query time: %d msec\n", elapsed.Milliseconds())
+		}
+
+		return nil
+	}
+
+	fmt.Printf("; <<>> dns-go <<>> %s %s\n", positional[0], qType.String())
+	writePresentation(os.Stdout, response)
+	fmt.Println()
+
+	if options.timing {
+		fmt.Printf(";; Query time: %d msec\n", elapsed.Milliseconds())
+	}
+
+	fmt.Printf(";; SERVER: %s\n", server)
+	fmt.Printf(";; WHEN: %s\n", start.Format(time.RFC1123Z))
+
+	return nil
+}
+
+func newQuery(name string, qType common.QueryType, options *queryOptions) *protocol.DnsPacket {
+	query := protocol.NewDnsPacket()
+	query.Header.RecursionDesired = options.rd
+	query.Header.CheckingDisabled = options.cd
+
+	question := protocol.NewDnsQuestion()
+	question.Name = strings.TrimSuffix(name, ".")
+	question.QueryType = qType
+
+	query.AddQuestion(*question)
+
+	if options.bufSize > 0 {
+		query.AddResource(dns_record.NewOPT(uint16(options.bufSize), options.do))
+	}
+
+	return query
+}
+
+// queryServer sends the query as it is to the given server
+func queryServer(query *protocol.DnsPacket, options *queryOptions) (*protocol.DnsPacket, string, error) {
+	upstream, err := resolver.ParseUpstream(upstreamURL(options.server, options.transport))
+	if err != nil {
+		return nil, "", err
+	}
+
+	response, err := upstream.Exchange(context.Background(), query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return response, upstream.String(), nil
+}
+
+// upstreamURL returns the URL of the upstream given the server's address, unless it's already given as one
+func upstreamURL(server string, transport string) string {
+	if strings.Contains(server, "://") {
+		return server
+	}
+
+	// IPv6 address has to be enclosed in brackets to be the URL's host
+	if ip := net.ParseIP(server); ip != nil && ip.To4() == nil {
+		server = "[" + server + "]"
+	}
+
+	if transport == "https" {
+		return "https://" + server + "/dns-query"
+	}
+
+	return transport + "://" + server
+}
+
+// queryRecursively resolves the query from the root the way the server does, with DNSSEC validation as configured
+func queryRecursively(query *protocol.DnsPacket) (*protocol.DnsPacket, string, error) {
+	cfg, err := utils.LoadConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("error loading config: %s", err.Error())
+	}
+
+	resolverConfig, err := newResolverConfig(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nameResolver := resolver.New(resolverConfig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	response, err := nameResolver.ResolveQuery(ctx, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return response, "resolved recursively from root " + cfg.INTERNET_ROOT_SERVER, nil
+}
+
+// newResolverConfig returns config of the resolver doing lookups from the root, ignoring upstreams the server may forward
+// queries to
+func newResolverConfig(cfg *utils.Config) (resolver.Config, error) {
+	trustAnchors := make([]*dnssec.TrustAnchor, 0, len(cfg.DNSSEC_TRUST_ANCHORS))
+	for _, value := range cfg.DNSSEC_TRUST_ANCHORS {
+		anchor, err := dnssec.ParseTrustAnchor(value)
+		if err != nil {
+			return resolver.Config{}, fmt.Errorf("error loading config: %s", err.Error())
+		}
+
+		trustAnchors = append(trustAnchors, anchor)
+	}
+
+	return resolver.Config{
+		InternetRootServer: cfg.INTERNET_ROOT_SERVER,
+		DNSSECValidation:   cfg.DNSSEC_VALIDATION,
+		TrustAnchors:       trustAnchors,
+	}, nil
+}
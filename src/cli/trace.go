@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/resolver"
+	"github.com/wiktor-mazur/dns-go/src/utils"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+func runTrace(args []string) error {
+	flags := flag.NewFlagSet("trace", flag.ContinueOnError)
+	root := flags.String("root", "", "IP address of the root server the lookup starts from (INTERNET_ROOT_SERVER by default)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dns-go trace [flags] name [type]")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) < 1 || len(positional) > 2 {
+		flags.Usage()
+		return errors.New("name and optionally type have to be given")
+	}
+
+	qType := common.A
+	if len(positional) == 2 {
+		qType, err = common.ParseQueryType(positional[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg, err := utils.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %s", err.Error())
+	}
+
+	resolverConfig, err := newResolverConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	if *root != "" {
+		resolverConfig.InternetRootServer = *root
+	}
+
+	tracer := &lookupTracer{w: os.Stdout}
+	resolverConfig.OnLookupStep = tracer.step
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	response, err := resolver.New(resolverConfig).LookupRecursive(ctx, 0, strings.TrimSuffix(positional[0], "."), qType)
+	if err != nil {
+		return fmt.Errorf("lookup failed after %d queries: %s", tracer.queries, err.Error())
+	}
+
+	fmt.Printf(";; Final response, after %d queries in %d ms:\n", tracer.queries, time.Since(start).Milliseconds())
+	writePresentation(os.Stdout, response)
+
+	return nil
+}
+
+// lookupTracer prints the steps of the recursive lookup, with nested lookups of glueless name servers indented
+type lookupTracer struct {
+	w       io.Writer
+	queries int
+	depth   int
+}
+
+func (v *lookupTracer) step(step *resolver.LookupStep) {
+	v.queries++
+	indent := strings.Repeat("    ", step.Depth)
+
+	if step.Depth > v.depth {
+		fmt.Fprintf(v.w, "%s;; Resolving address of glueless name server %s\n\n", indent, fqdn(step.Name))
+	}
+
+	v.depth = step.Depth
+
+	fmt.Fprintf(v.w, "%s;; Querying %s (zone %s) for %s %s: %s, %d ms\n", indent, step.Nameserver, fqdn(step.Zone),
+		fqdn(step.Name), step.Type.String(), stepOutcome(step), step.RTT.Milliseconds())
+
+	if step.Response == nil {
+		fmt.Fprintln(v.w)
+		return
+	}
+
+	// NS set and glue of the referral, or the answer
+	for _, records := range [][]protocol.DnsRecord{step.Response.Answers, step.Response.Authorities, step.Response.Resources} {
+		for _, record := range records {
+			if record.GetType() != common.OPT {
+				fmt.Fprintf(v.w, "%s%s\n", indent, record.PresentationString())
+			}
+		}
+	}
+
+	fmt.Fprintln(v.w)
+}
+
+// stepOutcome describes the name server's response the way the recursive lookup treats it
+func stepOutcome(step *resolver.LookupStep) string {
+	if step.Err != nil {
+		return "error: " + step.Err.Error()
+	}
+
+	response := step.Response
+	resultCode := response.Header.ResultCode
+
+	switch {
+	case len(response.Answers) > 0 && resultCode == common.NOERROR:
+		return "answer"
+	case resultCode == common.NXDOMAIN:
+		return "NXDOMAIN"
+	case response.GetUnresolvedNS(step.Name) == nil:
+		return fmt.Sprintf("%s without answer or referral", resultCode.String())
+	case response.GetResolvedNS(step.Name) == nil:
+		return fmt.Sprintf("referral to %s without glue", fqdn(response.GetUnresolvedNS(step.Name).GetName()))
+	default:
+		return fmt.Sprintf("referral to %s", fqdn(response.GetUnresolvedNS(step.Name).GetName()))
+	}
+}
@@ -1,5 +1,11 @@
 package common
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type Class uint16
 
 /*
@@ -18,3 +24,21 @@ func (v *Class) String() string {
 		return "UNKNOWN"
 	}
 }
+
+// ParseClass returns the class given its mnemonic or the generic "CLASSnnn" form (RFC 3597, section 5)
+func ParseClass(value string) (Class, error) {
+	value = strings.ToUpper(value)
+
+	if value == "IN" {
+		return IN, nil
+	}
+
+	if strings.HasPrefix(value, "CLASS") {
+		code, err := strconv.ParseUint(strings.TrimPrefix(value, "CLASS"), 10, 16)
+		if err == nil {
+			return Class(code), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown class %q", value)
+}
@@ -0,0 +1,88 @@
+package common
+
+type DNSSECAlgorithm uint8
+
+const (
+	RSAMD5           DNSSECAlgorithm = 1
+	DSA              DNSSECAlgorithm = 3
+	RSASHA1          DNSSECAlgorithm = 5
+	DSANSEC3SHA1     DNSSECAlgorithm = 6
+	RSASHA1NSEC3SHA1 DNSSECAlgorithm = 7
+	RSASHA256        DNSSECAlgorithm = 8
+	RSASHA512        DNSSECAlgorithm = 10
+	ECCGOST          DNSSECAlgorithm = 12
+	ECDSAP256SHA256  DNSSECAlgorithm = 13
+	ECDSAP384SHA384  DNSSECAlgorithm = 14
+	ED25519          DNSSECAlgorithm = 15
+	ED448            DNSSECAlgorithm = 16
+)
+
+func (v *DNSSECAlgorithm) String() string {
+	switch *v {
+	case RSAMD5:
+		return "RSAMD5"
+	case DSA:
+		return "DSA"
+	case RSASHA1:
+		return "RSASHA1"
+	case DSANSEC3SHA1:
+		return "DSA-NSEC3-SHA1"
+	case RSASHA1NSEC3SHA1:
+		return "RSASHA1-NSEC3-SHA1"
+	case RSASHA256:
+		return "RSASHA256"
+	case RSASHA512:
+		return "RSASHA512"
+	case ECCGOST:
+		return "ECC-GOST"
+	case ECDSAP256SHA256:
+		return "ECDSAP256SHA256"
+	case ECDSAP384SHA384:
+		return "ECDSAP384SHA384"
+	case ED25519:
+		return "ED25519"
+	case ED448:
+		return "ED448"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type DigestType uint8
+
+const (
+	DIGEST_SHA1   DigestType = 1
+	DIGEST_SHA256 DigestType = 2
+	DIGEST_GOST   DigestType = 3
+	DIGEST_SHA384 DigestType = 4
+)
+
+func (v *DigestType) String() string {
+	switch *v {
+	case DIGEST_SHA1:
+		return "SHA-1"
+	case DIGEST_SHA256:
+		return "SHA-256"
+	case DIGEST_GOST:
+		return "GOST R 34.11-94"
+	case DIGEST_SHA384:
+		return "SHA-384"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type NSEC3HashAlgorithm uint8
+
+const (
+	NSEC3_HASH_SHA1 NSEC3HashAlgorithm = 1
+)
+
+func (v *NSEC3HashAlgorithm) String() string {
+	switch *v {
+	case NSEC3_HASH_SHA1:
+		return "SHA-1"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -1,31 +1,172 @@
 package common
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
 type QueryType uint16
 
 const (
 	A     QueryType = 1
 	NS    QueryType = 2
+	MD    QueryType = 3
+	MF    QueryType = 4
 	CNAME QueryType = 5
 	SOA   QueryType = 6
+	MB    QueryType = 7
+	MG    QueryType = 8
+	MR    QueryType = 9
+	PTR   QueryType = 12
+	MINFO QueryType = 14
 	MX    QueryType = 15
 	AAAA  QueryType = 28
+	OPT   QueryType = 41
+
+	// DNSSEC
+	DS         QueryType = 43
+	RRSIG      QueryType = 46
+	NSEC       QueryType = 47
+	DNSKEY     QueryType = 48
+	NSEC3      QueryType = 50
+	NSEC3PARAM QueryType = 51
 )
 
+// types from this range are reserved for private use (RFC 6895, section 3.1)
+const (
+	PrivateUseQueryTypeMin QueryType = 65280
+	PrivateUseQueryTypeMax QueryType = 65534
+)
+
+// RecordType is an entry of the record type registry, which reading, printing and parsing of records consult
+type RecordType struct {
+	Code     QueryType
+	Mnemonic string
+	// Factory creates records of the type. It's a protocol.RecordFactory, which can't be referenced from here,
+	// or nil for types whose records are kept in the generic form (RFC 3597).
+	Factory any
+}
+
+var recordTypesMu sync.RWMutex
+
+// recordTypes is filled with the built-in types by the protocol package, which implements their records
+var recordTypes = make(map[QueryType]RecordType)
+
+// isRecordFactory tells whether the factory of a registered type is a protocol.RecordFactory
+var isRecordFactory func(factory any) bool
+
+// SetRecordFactoryCheck sets the function telling which factories RegisterRecordType accepts. It's called by the protocol
+// package, which defines the type of factories, before it registers the built-in types.
+func SetRecordFactoryCheck(check func(factory any) bool) {
+	recordTypesMu.Lock()
+	defer recordTypesMu.Unlock()
+
+	isRecordFactory = check
+}
+
+// RegisterRecordType adds the type to the registry. Registering a type without factory again with the same mnemonic
+// is a no-op, while conflicting registrations and factories of other types than protocol.RecordFactory are rejected.
+func RegisterRecordType(recordType RecordType) error {
+	recordType.Mnemonic = strings.ToUpper(recordType.Mnemonic)
+
+	if recordType.Mnemonic == "" || strings.HasPrefix(recordType.Mnemonic, "TYPE") {
+		return fmt.Errorf("invalid mnemonic %q for type %d", recordType.Mnemonic, recordType.Code)
+	}
+
+	recordTypesMu.Lock()
+	defer recordTypesMu.Unlock()
+
+	if recordType.Factory != nil && (isRecordFactory == nil || !isRecordFactory(recordType.Factory)) {
+		return fmt.Errorf("factory of type %d is %T, not a record factory", recordType.Code, recordType.Factory)
+	}
+
+	if existing, ok := recordTypes[recordType.Code]; ok {
+		if existing.Mnemonic == recordType.Mnemonic && recordType.Factory == nil {
+			return nil
+		}
+
+		return fmt.Errorf("type %d is already registered as %s", recordType.Code, existing.Mnemonic)
+	}
+
+	for _, existing := range recordTypes {
+		if existing.Mnemonic == recordType.Mnemonic {
+			return fmt.Errorf("mnemonic %s is already registered for type %d", recordType.Mnemonic, existing.Code)
+		}
+	}
+
+	recordTypes[recordType.Code] = recordType
+
+	return nil
+}
+
+// RegisterQueryType adds the mnemonic of a type whose records are kept in the generic form, so it can be printed and parsed
+func RegisterQueryType(qType QueryType, mnemonic string) error {
+	return RegisterRecordType(RecordType{Code: qType, Mnemonic: mnemonic})
+}
+
+// LookupRecordType returns the registry entry of the type
+func LookupRecordType(qType QueryType) (RecordType, bool) {
+	recordTypesMu.RLock()
+	defer recordTypesMu.RUnlock()
+
+	result, ok := recordTypes[qType]
+
+	return result, ok
+}
+
+// ParseQueryType returns the type given its mnemonic or the generic "TYPEnnn" form (RFC 3597, section 5)
+func ParseQueryType(value string) (QueryType, error) {
+	value = strings.ToUpper(value)
+
+	recordTypesMu.RLock()
+	defer recordTypesMu.RUnlock()
+
+	for _, recordType := range recordTypes {
+		if recordType.Mnemonic == value {
+			return recordType.Code, nil
+		}
+	}
+
+	if strings.HasPrefix(value, "TYPE") {
+		code, err := strconv.ParseUint(strings.TrimPrefix(value, "TYPE"), 10, 16)
+		if err == nil {
+			return QueryType(code), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown type %q", value)
+}
+
+// IsKnown reports whether the type has a registered mnemonic
+func (v *QueryType) IsKnown() bool {
+	_, ok := LookupRecordType(*v)
+
+	return ok
+}
+
+// String returns the mnemonic of the type or its generic "TYPEnnn" form (RFC 3597, section 5) if it's not registered
 func (v *QueryType) String() string {
-	switch *v {
-	case A:
-		return "A"
-	case NS:
-		return "NS"
-	case CNAME:
-		return "CNAME"
-	case SOA:
-		return "SOA"
-	case MX:
-		return "MX"
-	case AAAA:
-		return "AAAA"
-	default:
-		return "UNKNOWN"
+	recordType, ok := LookupRecordType(*v)
+	if !ok {
+		return fmt.Sprintf("TYPE%d", *v)
 	}
+
+	return recordType.Mnemonic
+}
+
+func (v QueryType) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *QueryType) UnmarshalText(text []byte) error {
+	qType, err := ParseQueryType(string(text))
+	if err != nil {
+		return err
+	}
+
+	*v = qType
+
+	return nil
 }
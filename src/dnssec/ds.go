@@ -0,0 +1,143 @@
+package dnssec
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"strconv"
+	"strings"
+)
+
+// Delegation is implemented by DS records and trust anchors, both pointing to a key signing key of a zone
+type Delegation interface {
+	GetKeyTag() uint16
+	GetAlgorithm() common.DNSSECAlgorithm
+	GetDigestType() common.DigestType
+	GetDigest() []byte
+}
+
+// TrustAnchor is a DS of a zone that is trusted without validation (usually the root zone's key signing key)
+type TrustAnchor struct {
+	Zone       string
+	keyTag     uint16
+	algorithm  common.DNSSECAlgorithm
+	digestType common.DigestType
+	digest     []byte
+}
+
+// ParseTrustAnchor parses DS data in the presentation format, optionally preceded by the zone name,
+// e.g. ". 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"
+func ParseTrustAnchor(value string) (*TrustAnchor, error) {
+	fields := strings.Fields(value)
+	result := &TrustAnchor{}
+
+	if len(fields) == 5 {
+		result.Zone = CanonicalName(fields[0])
+		fields = fields[1:]
+	}
+
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid trust anchor %q: expected key tag, algorithm, digest type and digest", value)
+	}
+
+	keyTag, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust anchor key tag: %s", err.Error())
+	}
+
+	algorithm, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust anchor algorithm: %s", err.Error())
+	}
+
+	digestType, err := strconv.ParseUint(fields[2], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust anchor digest type: %s", err.Error())
+	}
+
+	digest, err := hex.DecodeString(fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust anchor digest: %s", err.Error())
+	}
+
+	result.keyTag = uint16(keyTag)
+	result.algorithm = common.DNSSECAlgorithm(algorithm)
+	result.digestType = common.DigestType(digestType)
+	result.digest = digest
+
+	return result, nil
+}
+
+func (v *TrustAnchor) GetKeyTag() uint16 {
+	return v.keyTag
+}
+
+func (v *TrustAnchor) GetAlgorithm() common.DNSSECAlgorithm {
+	return v.algorithm
+}
+
+func (v *TrustAnchor) GetDigestType() common.DigestType {
+	return v.digestType
+}
+
+func (v *TrustAnchor) GetDigest() []byte {
+	return v.digest
+}
+
+// IsDigestTypeSupported reports whether DS records with given digest type can be checked
+func IsDigestTypeSupported(digestType common.DigestType) bool {
+	switch digestType {
+	case common.DIGEST_SHA1, common.DIGEST_SHA256, common.DIGEST_SHA384:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsDelegationSupported reports whether both the digest type and the key algorithm of DS record are supported
+func IsDelegationSupported(delegation Delegation) bool {
+	return IsDigestTypeSupported(delegation.GetDigestType()) && IsAlgorithmSupported(delegation.GetAlgorithm())
+}
+
+// KeyDigest calculates the digest of the key, as stored in DS records (RFC 4034, section 5.1.4)
+func KeyDigest(key *dns_record.DNSKEY, digestType common.DigestType) ([]byte, error) {
+	owner, err := nameToWire(key.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	data := append(owner, key.GetRData()...)
+
+	switch digestType {
+	case common.DIGEST_SHA1:
+		digest := sha1.Sum(data)
+		return digest[:], nil
+	case common.DIGEST_SHA256:
+		digest := sha256.Sum256(data)
+		return digest[:], nil
+	case common.DIGEST_SHA384:
+		digest := sha512.Sum384(data)
+		return digest[:], nil
+	default:
+		return nil, fmt.Errorf("unsupported digest type %s", digestType.String())
+	}
+}
+
+// MatchesDelegation reports whether the key is the one the DS record (or trust anchor) points to
+func MatchesDelegation(key *dns_record.DNSKEY, delegation Delegation) bool {
+	if key.GetKeyTag() != delegation.GetKeyTag() || key.GetAlgorithm() != delegation.GetAlgorithm() {
+		return false
+	}
+
+	digest, err := KeyDigest(key, delegation.GetDigestType())
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(digest, delegation.GetDigest())
+}
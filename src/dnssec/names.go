@@ -0,0 +1,119 @@
+package dnssec
+
+import (
+	"bytes"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"strings"
+)
+
+// CanonicalName returns lowercase name without the trailing dot (RFC 4034, section 6.2)
+func CanonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func SplitLabels(name string) []string {
+	name = CanonicalName(name)
+
+	if name == "" {
+		return nil
+	}
+
+	return strings.Split(name, ".")
+}
+
+// LabelCount returns the number of labels of the name as counted by the RRSIG labels field (RFC 4034, section 3.1.3)
+func LabelCount(name string) int {
+	labels := SplitLabels(name)
+
+	if len(labels) > 0 && labels[0] == "*" {
+		return len(labels) - 1
+	}
+
+	return len(labels)
+}
+
+func EqualNames(a string, b string) bool {
+	return CanonicalName(a) == CanonicalName(b)
+}
+
+// IsSubdomain reports whether child is equal to or below parent in the DNS tree
+func IsSubdomain(child string, parent string) bool {
+	child = CanonicalName(child)
+	parent = CanonicalName(parent)
+
+	return parent == "" || child == parent || strings.HasSuffix(child, "."+parent)
+}
+
+// ParentName returns the name with its leftmost label removed
+func ParentName(name string) string {
+	labels := SplitLabels(name)
+
+	if len(labels) <= 1 {
+		return ""
+	}
+
+	return strings.Join(labels[1:], ".")
+}
+
+// AncestorName returns the rightmost labelCount labels of the name
+func AncestorName(name string, labelCount int) string {
+	labels := SplitLabels(name)
+
+	if labelCount >= len(labels) {
+		return strings.Join(labels, ".")
+	}
+
+	return strings.Join(labels[len(labels)-labelCount:], ".")
+}
+
+// CompareNames compares names in the canonical DNS order, label by label starting from the rightmost one (RFC 4034, section 6.1)
+func CompareNames(a string, b string) int {
+	aLabels := SplitLabels(a)
+	bLabels := SplitLabels(b)
+
+	for i := 1; i <= len(aLabels) && i <= len(bLabels); i++ {
+		result := bytes.Compare([]byte(aLabels[len(aLabels)-i]), []byte(bLabels[len(bLabels)-i]))
+
+		if result != 0 {
+			return result
+		}
+	}
+
+	return len(aLabels) - len(bLabels)
+}
+
+// commonAncestor returns the longest name both a and b are subdomains of
+func commonAncestor(a string, b string) string {
+	aLabels := SplitLabels(a)
+	bLabels := SplitLabels(b)
+	common := make([]string, 0)
+
+	for i := 1; i <= len(aLabels) && i <= len(bLabels); i++ {
+		if aLabels[len(aLabels)-i] != bLabels[len(bLabels)-i] {
+			break
+		}
+
+		common = append([]string{aLabels[len(aLabels)-i]}, common...)
+	}
+
+	return strings.Join(common, ".")
+}
+
+func wildcardName(name string) string {
+	if CanonicalName(name) == "" {
+		return "*"
+	}
+
+	return "*." + CanonicalName(name)
+}
+
+func nameToWire(name string) ([]byte, error) {
+	buf := buffer.NewBytePacketBufferWithSize(buffer.MaxDNSBufferSize)
+
+	err := buf.WriteLabel(CanonicalName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.GetBytes(), nil
+}
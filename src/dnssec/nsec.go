@@ -0,0 +1,126 @@
+package dnssec
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+)
+
+// nsecCovers reports whether the name falls strictly between owner and next domain of the NSEC record
+func nsecCovers(nsec *dns_record.NSEC, name string) bool {
+	owner := nsec.GetName()
+	next := nsec.GetNextDomain()
+
+	if CompareNames(owner, next) < 0 {
+		return CompareNames(owner, name) < 0 && CompareNames(name, next) < 0
+	}
+
+	// the last NSEC record in the zone points back to the apex
+	return CompareNames(owner, name) < 0 || CompareNames(name, next) < 0
+}
+
+// nsecClosestEncloser returns the longest existing ancestor of the name, as proven by the NSEC record covering it
+func nsecClosestEncloser(nsec *dns_record.NSEC, name string) string {
+	ownerAncestor := commonAncestor(name, nsec.GetName())
+	nextAncestor := commonAncestor(name, nsec.GetNextDomain())
+
+	if LabelCount(ownerAncestor) > LabelCount(nextAncestor) {
+		return ownerAncestor
+	}
+
+	return nextAncestor
+}
+
+// VerifyNSECNameError checks that NSEC records prove the name and the wildcard that could match it don't exist (RFC 4035, section 5.4)
+func VerifyNSECNameError(nsecs []*dns_record.NSEC, qName string) error {
+	var covering *dns_record.NSEC
+
+	for _, nsec := range nsecs {
+		if nsecCovers(nsec, qName) {
+			covering = nsec
+			break
+		}
+	}
+
+	if covering == nil {
+		return fmt.Errorf("no NSEC record proves that %s doesn't exist", qName)
+	}
+
+	wildcard := wildcardName(nsecClosestEncloser(covering, qName))
+
+	for _, nsec := range nsecs {
+		if nsecCovers(nsec, wildcard) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no NSEC record proves that %s doesn't exist", wildcard)
+}
+
+// VerifyNSECNoData checks that NSEC records prove the name exists, but has no records of the given type
+func VerifyNSECNoData(nsecs []*dns_record.NSEC, qName string, qType common.QueryType) error {
+	for _, nsec := range nsecs {
+		if !EqualNames(nsec.GetName(), qName) {
+			continue
+		}
+
+		if nsec.HasType(qType) || nsec.HasType(common.CNAME) {
+			return fmt.Errorf("NSEC record proves that %s %s exists", qName, qType.String())
+		}
+
+		// DS lives in the parent zone, so NSEC from the child's apex can't prove it doesn't exist
+		if qType == common.DS && nsec.HasType(common.SOA) && CanonicalName(qName) != "" {
+			return fmt.Errorf("NSEC record for DS comes from the child zone")
+		}
+
+		return nil
+	}
+
+	// the name might have been synthesized from a wildcard with no records of the given type
+	for _, nsec := range nsecs {
+		if !nsecCovers(nsec, qName) {
+			continue
+		}
+
+		wildcard := wildcardName(nsecClosestEncloser(nsec, qName))
+
+		for _, wildcardNSEC := range nsecs {
+			if EqualNames(wildcardNSEC.GetName(), wildcard) && !wildcardNSEC.HasType(qType) && !wildcardNSEC.HasType(common.CNAME) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("no NSEC record proves that %s %s doesn't exist", qName, qType.String())
+}
+
+// VerifyNSECUnsignedDelegation checks that NSEC records prove the name is a delegation with no DS records, so the zone
+// it leads to is insecure (RFC 4035, section 5.2)
+func VerifyNSECUnsignedDelegation(nsecs []*dns_record.NSEC, name string) error {
+	for _, nsec := range nsecs {
+		if !EqualNames(nsec.GetName(), name) {
+			continue
+		}
+
+		if !nsec.HasType(common.NS) {
+			return fmt.Errorf("NSEC record proves that %s is not a delegation", name)
+		}
+
+		return VerifyNSECNoData([]*dns_record.NSEC{nsec}, name, common.DS)
+	}
+
+	// wildcard can't be a delegation, so only the exact match counts
+	return fmt.Errorf("no NSEC record proves that %s is an unsigned delegation", name)
+}
+
+// VerifyNSECWildcardAnswer checks that NSEC records prove the answer had to be synthesized from a wildcard,
+// because there's no exact match for the name
+func VerifyNSECWildcardAnswer(nsecs []*dns_record.NSEC, qName string) error {
+	for _, nsec := range nsecs {
+		if nsecCovers(nsec, qName) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no NSEC record proves that %s doesn't exist", qName)
+}
@@ -0,0 +1,246 @@
+package dnssec
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"strings"
+)
+
+// MaxNSEC3Iterations is the limit above which NSEC3 records are treated as insecure (RFC 9276, section 3.2)
+const MaxNSEC3Iterations = 150
+
+var ErrNSEC3IterationsTooHigh = fmt.Errorf("NSEC3 iterations count exceeds %d", MaxNSEC3Iterations)
+
+var nsec3HashEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// HashName calculates NSEC3 hash of the name (RFC 5155, section 5)
+func HashName(name string, iterations uint16, salt []byte) ([]byte, error) {
+	wire, err := nameToWire(name)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha1.Sum(append(wire, salt...))
+
+	for i := uint16(0); i < iterations; i++ {
+		digest = sha1.Sum(append(digest[:], salt...))
+	}
+
+	return digest[:], nil
+}
+
+// nsec3 wraps NSEC3 record along with its decoded owner hash
+type nsec3 struct {
+	record    *dns_record.NSEC3
+	ownerHash []byte
+}
+
+func newNSEC3Set(records []*dns_record.NSEC3) ([]nsec3, error) {
+	result := make([]nsec3, 0, len(records))
+
+	for _, record := range records {
+		if record.GetHashAlgorithm() != common.NSEC3_HASH_SHA1 {
+			continue
+		}
+
+		if record.GetIterations() > MaxNSEC3Iterations {
+			return nil, ErrNSEC3IterationsTooHigh
+		}
+
+		labels := SplitLabels(record.GetName())
+		if len(labels) == 0 {
+			continue
+		}
+
+		ownerHash, err := nsec3HashEncoding.DecodeString(strings.ToUpper(labels[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid NSEC3 owner name %s", record.GetName())
+		}
+
+		result = append(result, nsec3{record: record, ownerHash: ownerHash})
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no usable NSEC3 records")
+	}
+
+	return result, nil
+}
+
+func (v *nsec3) hash(name string) []byte {
+	hash, err := HashName(name, v.record.GetIterations(), v.record.GetSalt())
+	if err != nil {
+		return nil
+	}
+
+	return hash
+}
+
+func (v *nsec3) matches(name string) bool {
+	return IsSubdomain(name, ParentName(v.record.GetName())) && bytes.Equal(v.hash(name), v.ownerHash)
+}
+
+func (v *nsec3) covers(name string) bool {
+	if !IsSubdomain(name, ParentName(v.record.GetName())) {
+		return false
+	}
+
+	hash := v.hash(name)
+	next := v.record.GetNextHashedOwner()
+
+	if bytes.Compare(v.ownerHash, next) < 0 {
+		return bytes.Compare(v.ownerHash, hash) < 0 && bytes.Compare(hash, next) < 0
+	}
+
+	// the last NSEC3 record in the zone points back to the first one
+	return bytes.Compare(v.ownerHash, hash) < 0 || bytes.Compare(hash, next) < 0
+}
+
+func findMatchingNSEC3(set []nsec3, name string) *nsec3 {
+	for idx := range set {
+		if set[idx].matches(name) {
+			return &set[idx]
+		}
+	}
+
+	return nil
+}
+
+func findCoveringNSEC3(set []nsec3, name string) *nsec3 {
+	for idx := range set {
+		if set[idx].covers(name) {
+			return &set[idx]
+		}
+	}
+
+	return nil
+}
+
+// closestEncloserProof finds the closest existing ancestor of the name and NSEC3 covering the next closer name (RFC 5155, section 8.3)
+func closestEncloserProof(set []nsec3, qName string) (string, *nsec3, error) {
+	nextCloser := ""
+
+	for name := CanonicalName(qName); ; name = ParentName(name) {
+		if findMatchingNSEC3(set, name) != nil {
+			if nextCloser == "" {
+				return "", nil, fmt.Errorf("NSEC3 record proves that %s exists", qName)
+			}
+
+			covering := findCoveringNSEC3(set, nextCloser)
+			if covering == nil {
+				return "", nil, fmt.Errorf("no NSEC3 record covers the next closer name %s", nextCloser)
+			}
+
+			return name, covering, nil
+		}
+
+		if name == "" {
+			return "", nil, fmt.Errorf("no NSEC3 record proves the closest encloser of %s", qName)
+		}
+
+		nextCloser = name
+	}
+}
+
+// VerifyNSEC3NameError checks that NSEC3 records prove the name doesn't exist (RFC 5155, section 8.4).
+// Returned flag is true if the proof relies on an opt-out range, in which case the name may exist in an unsigned delegation.
+func VerifyNSEC3NameError(records []*dns_record.NSEC3, qName string) (bool, error) {
+	set, err := newNSEC3Set(records)
+	if err != nil {
+		return false, err
+	}
+
+	closestEncloser, nextCloser, err := closestEncloserProof(set, qName)
+	if err != nil {
+		return false, err
+	}
+
+	if findCoveringNSEC3(set, wildcardName(closestEncloser)) == nil {
+		return false, fmt.Errorf("no NSEC3 record proves that %s doesn't exist", wildcardName(closestEncloser))
+	}
+
+	return nextCloser.record.IsOptOut(), nil
+}
+
+// VerifyNSEC3NoData checks that NSEC3 records prove there are no records of given type at the name (RFC 5155, sections 8.5 - 8.7).
+// Returned flag is true if the proof relies on an opt-out range, which means an unsigned delegation.
+func VerifyNSEC3NoData(records []*dns_record.NSEC3, qName string, qType common.QueryType) (bool, error) {
+	set, err := newNSEC3Set(records)
+	if err != nil {
+		return false, err
+	}
+
+	matching := findMatchingNSEC3(set, qName)
+	if matching != nil {
+		if matching.record.HasType(qType) || matching.record.HasType(common.CNAME) {
+			return false, fmt.Errorf("NSEC3 record proves that %s %s exists", qName, qType.String())
+		}
+
+		// DS lives in the parent zone, so NSEC3 from the child's apex can't prove it doesn't exist
+		if qType == common.DS && matching.record.HasType(common.SOA) && CanonicalName(qName) != "" {
+			return false, fmt.Errorf("NSEC3 record for DS comes from the child zone")
+		}
+
+		return false, nil
+	}
+
+	closestEncloser, nextCloser, err := closestEncloserProof(set, qName)
+	if err != nil {
+		return false, err
+	}
+
+	// insecure delegation covered by an opt-out range
+	if qType == common.DS {
+		if !nextCloser.record.IsOptOut() {
+			return false, fmt.Errorf("NSEC3 record covering %s doesn't have opt-out flag set", qName)
+		}
+
+		return true, nil
+	}
+
+	// the name might have been synthesized from a wildcard with no records of the given type
+	wildcard := findMatchingNSEC3(set, wildcardName(closestEncloser))
+	if wildcard != nil && !wildcard.record.HasType(qType) && !wildcard.record.HasType(common.CNAME) {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("no NSEC3 record proves that %s %s doesn't exist", qName, qType.String())
+}
+
+// VerifyNSEC3UnsignedDelegation checks that NSEC3 records prove the name is a delegation with no DS records, or that
+// it's covered by an opt-out range (RFC 5155, section 8.9). Returned flag is true in the latter case.
+func VerifyNSEC3UnsignedDelegation(records []*dns_record.NSEC3, name string) (bool, error) {
+	set, err := newNSEC3Set(records)
+	if err != nil {
+		return false, err
+	}
+
+	matching := findMatchingNSEC3(set, name)
+	if matching != nil && !matching.record.HasType(common.NS) {
+		return false, fmt.Errorf("NSEC3 record proves that %s is not a delegation", name)
+	}
+
+	return VerifyNSEC3NoData(records, name, common.DS)
+}
+
+// VerifyNSEC3WildcardAnswer checks that NSEC3 records prove there's no exact match for the name synthesized from a wildcard,
+// given the closest encloser derived from the RRSIG labels field (RFC 5155, section 8.8)
+func VerifyNSEC3WildcardAnswer(records []*dns_record.NSEC3, qName string, closestEncloser string) error {
+	set, err := newNSEC3Set(records)
+	if err != nil {
+		return err
+	}
+
+	labels := SplitLabels(qName)
+	nextCloser := AncestorName(qName, LabelCount(closestEncloser)+1)
+
+	if len(labels) <= LabelCount(closestEncloser) || findCoveringNSEC3(set, nextCloser) == nil {
+		return fmt.Errorf("no NSEC3 record covers the next closer name %s", nextCloser)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package dnssec
+
+import (
+	"bytes"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"sort"
+)
+
+// RRSet groups records sharing owner name, type and class, along with the signatures covering them
+type RRSet struct {
+	Name       string
+	Type       common.QueryType
+	Class      common.Class
+	Records    []protocol.DnsRecord
+	Signatures []*dns_record.RRSIG
+}
+
+// GetTTL returns the lowest TTL among records of the set
+func (v *RRSet) GetTTL() uint32 {
+	var result uint32
+
+	for idx, record := range v.Records {
+		if idx == 0 || record.GetTTL() < result {
+			result = record.GetTTL()
+		}
+	}
+
+	return result
+}
+
+// GroupRRSets splits records of a message section into RRsets and attaches RRSIG records to the sets they cover
+func GroupRRSets(records []protocol.DnsRecord) []*RRSet {
+	result := make([]*RRSet, 0)
+
+	find := func(name string, qType common.QueryType) *RRSet {
+		for _, set := range result {
+			if set.Type == qType && EqualNames(set.Name, name) {
+				return set
+			}
+		}
+
+		return nil
+	}
+
+	for _, record := range records {
+		if record.GetType() == common.RRSIG || record.GetType() == common.OPT {
+			continue
+		}
+
+		set := find(record.GetName(), record.GetType())
+		if set == nil {
+			set = &RRSet{Name: record.GetName(), Type: record.GetType(), Class: record.GetClass()}
+			result = append(result, set)
+		}
+
+		set.Records = append(set.Records, record)
+	}
+
+	for _, record := range records {
+		rrsig, ok := record.(*dns_record.RRSIG)
+		if !ok {
+			continue
+		}
+
+		set := find(rrsig.GetName(), rrsig.GetTypeCovered())
+		if set != nil {
+			set.Signatures = append(set.Signatures, rrsig)
+		}
+	}
+
+	return result
+}
+
+// FindRRSet returns the set with given owner name and type, if present
+func FindRRSet(sets []*RRSet, name string, qType common.QueryType) *RRSet {
+	for _, set := range sets {
+		if set.Type == qType && EqualNames(set.Name, name) {
+			return set
+		}
+	}
+
+	return nil
+}
+
+// signedData builds the data covered by the signature: RRSIG fields followed by the RRset in the canonical form (RFC 4034, section 3.1.8.1)
+func signedData(rrsig *dns_record.RRSIG, set *RRSet) ([]byte, error) {
+	buf := buffer.NewBytePacketBufferWithSize(buffer.MaxDNSBufferSize)
+
+	err := rrsig.WriteSignedData(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// records synthesized from a wildcard are signed with the wildcard owner name (RFC 4035, section 5.3.2)
+	owner := CanonicalName(set.Name)
+	if LabelCount(owner) > int(rrsig.GetLabels()) {
+		owner = wildcardName(AncestorName(owner, int(rrsig.GetLabels())))
+	}
+
+	rDatas := make([][]byte, 0, len(set.Records))
+
+	for _, record := range set.Records {
+		rData, err := dns_record.CanonicalRData(record)
+		if err != nil {
+			return nil, err
+		}
+
+		rDatas = append(rDatas, rData)
+	}
+
+	sort.Slice(rDatas, func(i, j int) bool { return bytes.Compare(rDatas[i], rDatas[j]) < 0 })
+
+	for idx, rData := range rDatas {
+		// duplicate records are not allowed in the canonical RRset
+		if idx > 0 && bytes.Equal(rData, rDatas[idx-1]) {
+			continue
+		}
+
+		err = buf.WriteLabel(owner)
+		if err != nil {
+			return nil, err
+		}
+
+		err = buf.WriteUint16(uint16(set.Type))
+		if err != nil {
+			return nil, err
+		}
+
+		err = buf.WriteUint16(uint16(set.Class))
+		if err != nil {
+			return nil, err
+		}
+
+		err = buf.WriteUint32(rrsig.GetOriginalTTL())
+		if err != nil {
+			return nil, err
+		}
+
+		err = buf.WriteUint16(uint16(len(rData)))
+		if err != nil {
+			return nil, err
+		}
+
+		err = buf.WriteBytes(rData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.GetBytes(), nil
+}
@@ -0,0 +1,25 @@
+package dnssec
+
+type Status uint8
+
+const (
+	Indeterminate Status = 0 // validation was not performed
+	Insecure      Status = 1 // data comes from a zone that is provably not signed
+	Secure        Status = 2 // data was validated with the chain of trust from the trust anchor
+	Bogus         Status = 3 // data should have been signed, but validation failed
+)
+
+func (v *Status) String() string {
+	switch *v {
+	case Indeterminate:
+		return "INDETERMINATE"
+	case Insecure:
+		return "INSECURE"
+	case Secure:
+		return "SECURE"
+	case Bogus:
+		return "BOGUS"
+	default:
+		return "UNKNOWN"
+	}
+}
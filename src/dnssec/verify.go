@@ -0,0 +1,202 @@
+package dnssec
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// IsAlgorithmSupported reports whether signatures made with given algorithm can be verified
+func IsAlgorithmSupported(algorithm common.DNSSECAlgorithm) bool {
+	switch algorithm {
+	case common.RSASHA1, common.RSASHA1NSEC3SHA1, common.RSASHA256, common.RSASHA512,
+		common.ECDSAP256SHA256, common.ECDSAP384SHA384, common.ED25519:
+		return true
+	default:
+		return false
+	}
+}
+
+// VerifyRRSet checks whether any of the set's signatures is currently valid and was made by one of given zone keys,
+// returning the first valid signature
+func VerifyRRSet(set *RRSet, keys []*dns_record.DNSKEY, now time.Time) (*dns_record.RRSIG, error) {
+	if len(set.Signatures) == 0 {
+		return nil, fmt.Errorf("%s %s is not signed", set.Name, set.Type.String())
+	}
+
+	errs := make([]string, 0)
+
+	for _, rrsig := range set.Signatures {
+		err := verifyRRSIG(rrsig, set, keys, now)
+		if err == nil {
+			return rrsig, nil
+		}
+
+		errs = append(errs, err.Error())
+	}
+
+	return nil, fmt.Errorf("no valid signature for %s %s (%s)", set.Name, set.Type.String(), strings.Join(errs, "; "))
+}
+
+func verifyRRSIG(rrsig *dns_record.RRSIG, set *RRSet, keys []*dns_record.DNSKEY, now time.Time) error {
+	if rrsig.GetTypeCovered() != set.Type || !EqualNames(rrsig.GetName(), set.Name) {
+		return fmt.Errorf("RRSIG doesn't cover the set")
+	}
+
+	if !IsSubdomain(set.Name, rrsig.GetSignerName()) {
+		return fmt.Errorf("signer %s is not authoritative for %s", rrsig.GetSignerName(), set.Name)
+	}
+
+	if int(rrsig.GetLabels()) > LabelCount(set.Name) {
+		return fmt.Errorf("RRSIG labels field is larger than the owner name label count")
+	}
+
+	algorithm := rrsig.GetAlgorithm()
+	if !IsAlgorithmSupported(algorithm) {
+		return fmt.Errorf("unsupported algorithm %s", algorithm.String())
+	}
+
+	// inception and expiration use serial number arithmetic (RFC 4034, section 3.1.5)
+	timestamp := uint32(now.Unix())
+	if int32(rrsig.GetInception()-timestamp) > 0 {
+		return fmt.Errorf("signature is not valid yet")
+	}
+
+	if int32(rrsig.GetExpiration()-timestamp) < 0 {
+		return fmt.Errorf("signature has expired")
+	}
+
+	data, err := signedData(rrsig, set)
+	if err != nil {
+		return err
+	}
+
+	keyFound := false
+
+	for _, key := range keys {
+		if key.GetKeyTag() != rrsig.GetKeyTag() || key.GetAlgorithm() != rrsig.GetAlgorithm() {
+			continue
+		}
+
+		if !key.IsZoneKey() || key.IsRevoked() || key.GetProtocol() != dns_record.DNSKEYProtocol {
+			continue
+		}
+
+		if !EqualNames(key.GetName(), rrsig.GetSignerName()) {
+			continue
+		}
+
+		keyFound = true
+
+		if verifySignature(key, data, rrsig.GetSignature()) == nil {
+			return nil
+		}
+	}
+
+	if !keyFound {
+		return fmt.Errorf("no DNSKEY with tag %d found for signer %s", rrsig.GetKeyTag(), rrsig.GetSignerName())
+	}
+
+	return fmt.Errorf("signature made with key %d is invalid", rrsig.GetKeyTag())
+}
+
+func verifySignature(key *dns_record.DNSKEY, data []byte, signature []byte) error {
+	algorithm := key.GetAlgorithm()
+
+	switch algorithm {
+	case common.RSASHA1, common.RSASHA1NSEC3SHA1:
+		return verifyRSA(key.GetPublicKey(), crypto.SHA1, data, signature)
+	case common.RSASHA256:
+		return verifyRSA(key.GetPublicKey(), crypto.SHA256, data, signature)
+	case common.RSASHA512:
+		return verifyRSA(key.GetPublicKey(), crypto.SHA512, data, signature)
+	case common.ECDSAP256SHA256:
+		return verifyECDSA(key.GetPublicKey(), elliptic.P256(), crypto.SHA256, data, signature)
+	case common.ECDSAP384SHA384:
+		return verifyECDSA(key.GetPublicKey(), elliptic.P384(), crypto.SHA384, data, signature)
+	case common.ED25519:
+		if len(key.GetPublicKey()) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid Ed25519 public key")
+		}
+
+		if !ed25519.Verify(key.GetPublicKey(), data, signature) {
+			return fmt.Errorf("invalid Ed25519 signature")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %s", algorithm.String())
+	}
+}
+
+// verifyRSA verifies PKCS #1 v1.5 signature, with the public key encoded as described in RFC 3110, section 2
+func verifyRSA(publicKey []byte, hash crypto.Hash, data []byte, signature []byte) error {
+	if len(publicKey) < 3 {
+		return fmt.Errorf("invalid RSA public key")
+	}
+
+	exponentLength := int(publicKey[0])
+	offset := 1
+
+	if exponentLength == 0 {
+		exponentLength = int(publicKey[1])<<8 | int(publicKey[2])
+		offset = 3
+	}
+
+	if exponentLength > 4 || offset+exponentLength >= len(publicKey) {
+		return fmt.Errorf("invalid RSA public key")
+	}
+
+	exponent := 0
+	for _, b := range publicKey[offset : offset+exponentLength] {
+		exponent = exponent<<8 | int(b)
+	}
+
+	key := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(publicKey[offset+exponentLength:]),
+		E: exponent,
+	}
+
+	hasher := hash.New()
+	hasher.Write(data)
+
+	return rsa.VerifyPKCS1v15(key, hash, hasher.Sum(nil), signature)
+}
+
+// verifyECDSA verifies signature encoded as r|s, with the public key encoded as x|y (RFC 6605, section 4)
+func verifyECDSA(publicKey []byte, curve elliptic.Curve, hash crypto.Hash, data []byte, signature []byte) error {
+	size := curve.Params().BitSize / 8
+
+	if len(publicKey) != 2*size || len(signature) != 2*size {
+		return fmt.Errorf("invalid ECDSA public key or signature size")
+	}
+
+	key := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(publicKey[:size]),
+		Y:     new(big.Int).SetBytes(publicKey[size:]),
+	}
+
+	hasher := hash.New()
+	hasher.Write(data)
+
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+
+	if !ecdsa.Verify(key, hasher.Sum(nil), r, s) {
+		return fmt.Errorf("invalid ECDSA signature")
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package dnstap
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// reconnectInterval is the time between attempts to connect to the socket after the connection is lost
+	reconnectInterval = 5 * time.Second
+	// handshakeTimeout limits the time of Frame Streams handshake with the receiver
+	handshakeTimeout = 5 * time.Second
+	// writeTimeout limits the time of each write to the receiver, which is reconnected if it stops reading
+	writeTimeout = 5 * time.Second
+	// closeTimeout limits the time Close waits for the stream to be finished, before the connection is closed anyway
+	closeTimeout = 10 * time.Second
+)
+
+type Config struct {
+	Socket     string // path of the Unix socket of the receiver, e.g. dnstap-read or fstrm_capture
+	File       string // path of the file frames are written to (replacing its content), used if the socket is not set
+	Identity   string
+	Version    string
+	BufferSize int // number of messages waiting to be written; when the buffer is full, messages are dropped
+}
+
+// Tap writes dnstap messages in the background, so that logging never slows down the queries
+type Tap struct {
+	cfg      Config
+	frames   chan []byte
+	file     *os.File
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	dropped  atomic.Uint64
+	connMu   sync.Mutex
+	conn     net.Conn // current connection to the receiver, if there's one
+}
+
+func New(cfg Config) (*Tap, error) {
+	if cfg.Socket == "" && cfg.File == "" {
+		return nil, errors.New("dnstap socket or file has to be set")
+	}
+
+	result := &Tap{
+		cfg:    cfg,
+		frames: make(chan []byte, cfg.BufferSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if cfg.Socket == "" {
+		// readers stop at the end of the first stream, so the file is truncated rather than getting another one appended
+		file, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		result.file = file
+	}
+
+	go result.run()
+
+	return result, nil
+}
+
+// Log queues the message to be written, dropping it if the buffer is full
+func (v *Tap) Log(message *Message) {
+	frame := message.marshal([]byte(v.cfg.Identity), []byte(v.cfg.Version))
+
+	select {
+	case v.frames <- frame:
+	default:
+		v.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of messages dropped because the buffer was full or the receiver wasn't connected
+func (v *Tap) Dropped() uint64 {
+	return v.dropped.Load()
+}
+
+// Close writes the messages remaining in the buffer and ends the stream, waiting for the receiver to acknowledge it.
+// If that doesn't happen within closeTimeout, the connection or file is closed with the stream unfinished.
+func (v *Tap) Close() error {
+	v.stopOnce.Do(func() {
+		close(v.stop)
+	})
+
+	select {
+	case <-v.done:
+	case <-time.After(closeTimeout):
+		v.connMu.Lock()
+		if v.conn != nil {
+			v.conn.Close()
+		}
+		v.connMu.Unlock()
+
+		if v.file != nil {
+			v.file.Close()
+		}
+
+		// pending writes fail on the closed connection or file, so the stream ends right away
+		<-v.done
+
+		return errors.New("dnstap stream could not be finished in time")
+	}
+
+	if v.file != nil {
+		return v.file.Close()
+	}
+
+	return nil
+}
+
+func (v *Tap) run() {
+	defer close(v.done)
+
+	if v.file != nil {
+		err := v.writeStream(v.file, nil)
+		if err != nil {
+			slog.Error("Could not write dnstap file, dnstap output is stopped", "file", v.cfg.File, "error", err)
+			v.discard()
+		}
+
+		return
+	}
+
+	for {
+		err := v.connect()
+		if err == nil {
+			return
+		}
+
+		slog.Warn("dnstap connection failed", "socket", v.cfg.Socket, "error", err)
+
+		select {
+		case <-v.stop:
+			return
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
+// connect performs bidirectional Frame Streams handshake with the receiver and writes the messages until the tap is closed
+func (v *Tap) connect() error {
+	conn, err := net.Dial("unix", v.cfg.Socket)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	v.setConn(conn)
+	defer v.setConn(nil)
+
+	err = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err != nil {
+		return err
+	}
+
+	err = writeControlFrame(conn, controlReady, true)
+	if err != nil {
+		return err
+	}
+
+	err = readControlFrame(conn, controlAccept)
+	if err != nil {
+		return err
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("dnstap connected", "socket", v.cfg.Socket)
+
+	return v.writeStream(&deadlineWriter{conn: conn}, func() error {
+		err := conn.SetDeadline(time.Now().Add(handshakeTimeout))
+		if err != nil {
+			return err
+		}
+
+		return readControlFrame(conn, controlFinish)
+	})
+}
+
+func (v *Tap) setConn(conn net.Conn) {
+	v.connMu.Lock()
+	defer v.connMu.Unlock()
+
+	v.conn = conn
+}
+
+// deadlineWriter sets the write deadline of the connection before each write, so that a receiver which stopped reading
+// makes the write fail (and the tap reconnect) instead of blocking it forever
+type deadlineWriter struct {
+	conn net.Conn
+}
+
+func (v *deadlineWriter) Write(p []byte) (int, error) {
+	err := v.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err != nil {
+		return 0, err
+	}
+
+	return v.conn.Write(p)
+}
+
+// writeStream writes the stream of frames until the tap is closed, then finishes it with the STOP frame.
+// Frames are buffered and flushed whenever there are no more messages waiting.
+func (v *Tap) writeStream(w io.Writer, finish func() error) error {
+	buf := bufio.NewWriter(w)
+
+	err := writeControlFrame(buf, controlStart, true)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case frame := <-v.frames:
+			err = writeDataFrame(buf, frame)
+			if err != nil {
+				return err
+			}
+
+			if len(v.frames) == 0 {
+				err = buf.Flush()
+				if err != nil {
+					return err
+				}
+			}
+		case <-v.stop:
+			return v.finishStream(buf, finish)
+		}
+	}
+}
+
+// finishStream writes the frames remaining in the buffer and the STOP frame
+func (v *Tap) finishStream(buf *bufio.Writer, finish func() error) error {
+	// the tap is the only reader of the channel, so the frames counted can't be taken in the meantime
+	for len(v.frames) > 0 {
+		err := writeDataFrame(buf, <-v.frames)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := writeControlFrame(buf, controlStop, false)
+	if err != nil {
+		return err
+	}
+
+	err = buf.Flush()
+	if err != nil {
+		return err
+	}
+
+	if finish != nil {
+		return finish()
+	}
+
+	return nil
+}
+
+// discard drops the messages until the tap is closed, so that Log never blocks
+func (v *Tap) discard() {
+	for {
+		select {
+		case <-v.frames:
+			v.dropped.Add(1)
+		case <-v.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package dnstap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Frame Streams (https://github.com/farsightsec/fstrm) wraps dnstap messages into length-prefixed frames
+
+const contentType = "protobuf:dnstap.Dnstap"
+
+// maxControlFrameSize limits control frames read from the receiver, which only carry a few content types
+const maxControlFrameSize = 512
+
+type controlType uint32
+
+const (
+	controlAccept controlType = 0x01
+	controlStart  controlType = 0x02
+	controlStop   controlType = 0x03
+	controlReady  controlType = 0x04
+	controlFinish controlType = 0x05
+)
+
+const controlFieldContentType = 0x01
+
+// writeDataFrame writes the frame carrying a single dnstap message
+func writeDataFrame(w io.Writer, data []byte) error {
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(data)), uint32(len(data)))
+	frame = append(frame, data...)
+
+	_, err := w.Write(frame)
+
+	return err
+}
+
+// writeControlFrame writes the control frame, which starts with zero length that tells it apart from data frames
+func writeControlFrame(w io.Writer, control controlType, withContentType bool) error {
+	payload := binary.BigEndian.AppendUint32(nil, uint32(control))
+	if withContentType {
+		payload = binary.BigEndian.AppendUint32(payload, controlFieldContentType)
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(contentType)))
+		payload = append(payload, contentType...)
+	}
+
+	frame := binary.BigEndian.AppendUint32(nil, 0)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	frame = append(frame, payload...)
+
+	_, err := w.Write(frame)
+
+	return err
+}
+
+// readControlFrame reads the control frame sent by the receiver and checks that it's of the expected type
+func readControlFrame(r io.Reader, expected controlType) error {
+	header := make([]byte, 12)
+
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return err
+	}
+
+	if binary.BigEndian.Uint32(header[0:4]) != 0 {
+		return errors.New("expected control frame, got data frame")
+	}
+
+	length := binary.BigEndian.Uint32(header[4:8])
+	if length < 4 || length > maxControlFrameSize {
+		return fmt.Errorf("invalid control frame length %d", length)
+	}
+
+	control := controlType(binary.BigEndian.Uint32(header[8:12]))
+	if control != expected {
+		return fmt.Errorf("expected control frame of type %d, got %d", expected, control)
+	}
+
+	// content types accepted by the receiver are not checked, dnstap is the only one that can be offered
+	_, err = io.CopyN(io.Discard, r, int64(length-4))
+
+	return err
+}
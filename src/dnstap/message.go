@@ -0,0 +1,181 @@
+package dnstap
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"net"
+	"strconv"
+	"time"
+)
+
+// MessageType tells what kind of DNS message is logged (dnstap.proto, Message.Type)
+type MessageType uint64
+
+const (
+	RESOLVER_QUERY     MessageType = 3
+	RESOLVER_RESPONSE  MessageType = 4
+	CLIENT_QUERY       MessageType = 5
+	CLIENT_RESPONSE    MessageType = 6
+	FORWARDER_QUERY    MessageType = 7
+	FORWARDER_RESPONSE MessageType = 8
+)
+
+// SocketProtocol tells the transport the message was exchanged with (dnstap.proto, SocketProtocol)
+type SocketProtocol uint64
+
+const (
+	UDP SocketProtocol = 1
+	TCP SocketProtocol = 2
+	DOT SocketProtocol = 3
+	DOH SocketProtocol = 4
+	DOQ SocketProtocol = 7
+)
+
+const (
+	socketFamilyINET  = 1
+	socketFamilyINET6 = 2
+)
+
+// dnstapTypeMessage is the type of the outer Dnstap message carrying a Message
+const dnstapTypeMessage = 1
+
+// Message describes the DNS message exchanged by dns-go; addresses and times which are not known are left out
+type Message struct {
+	Type            MessageType
+	Protocol        SocketProtocol
+	QueryAddr       net.Addr // address the query was sent from
+	ResponseAddr    net.Addr // address the query was sent to
+	QueryTime       time.Time
+	ResponseTime    time.Time
+	QueryMessage    []byte
+	ResponseMessage []byte
+}
+
+// marshal encodes the message wrapped in the Dnstap message in protobuf wire format
+func (v *Message) marshal(identity []byte, version []byte) []byte {
+	var msg []byte
+
+	msg = appendVarint(msg, 1, uint64(v.Type))
+
+	queryIP, queryPort := splitAddr(v.QueryAddr)
+	responseIP, responsePort := splitAddr(v.ResponseAddr)
+
+	if family := socketFamily(queryIP, responseIP); family != 0 {
+		msg = appendVarint(msg, 2, family)
+	}
+
+	if v.Protocol != 0 {
+		msg = appendVarint(msg, 3, uint64(v.Protocol))
+	}
+
+	if queryIP != nil {
+		msg = appendBytes(msg, 4, ipBytes(queryIP))
+	}
+
+	if responseIP != nil {
+		msg = appendBytes(msg, 5, ipBytes(responseIP))
+	}
+
+	if queryIP != nil {
+		msg = appendVarint(msg, 6, uint64(queryPort))
+	}
+
+	if responseIP != nil {
+		msg = appendVarint(msg, 7, uint64(responsePort))
+	}
+
+	if !v.QueryTime.IsZero() {
+		msg = appendVarint(msg, 8, uint64(v.QueryTime.Unix()))
+		msg = appendFixed32(msg, 9, uint32(v.QueryTime.Nanosecond()))
+	}
+
+	if v.QueryMessage != nil {
+		msg = appendBytes(msg, 10, v.QueryMessage)
+	}
+
+	if !v.ResponseTime.IsZero() {
+		msg = appendVarint(msg, 12, uint64(v.ResponseTime.Unix()))
+		msg = appendFixed32(msg, 13, uint32(v.ResponseTime.Nanosecond()))
+	}
+
+	if v.ResponseMessage != nil {
+		msg = appendBytes(msg, 14, v.ResponseMessage)
+	}
+
+	var result []byte
+
+	if len(identity) > 0 {
+		result = appendBytes(result, 1, identity)
+	}
+
+	if len(version) > 0 {
+		result = appendBytes(result, 2, version)
+	}
+
+	result = appendBytes(result, 14, msg)
+	result = appendVarint(result, 15, dnstapTypeMessage)
+
+	return result
+}
+
+func appendVarint(b []byte, field protowire.Number, value uint64) []byte {
+	b = protowire.AppendTag(b, field, protowire.VarintType)
+
+	return protowire.AppendVarint(b, value)
+}
+
+func appendFixed32(b []byte, field protowire.Number, value uint32) []byte {
+	b = protowire.AppendTag(b, field, protowire.Fixed32Type)
+
+	return protowire.AppendFixed32(b, value)
+}
+
+func appendBytes(b []byte, field protowire.Number, value []byte) []byte {
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+
+	return protowire.AppendBytes(b, value)
+}
+
+func splitAddr(addr net.Addr) (net.IP, int) {
+	switch value := addr.(type) {
+	case nil:
+		return nil, 0
+	case *net.UDPAddr:
+		return value.IP, value.Port
+	case *net.TCPAddr:
+		return value.IP, value.Port
+	}
+
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, 0
+	}
+
+	portNumber, _ := strconv.Atoi(port)
+
+	return net.ParseIP(host), portNumber
+}
+
+func socketFamily(ips ...net.IP) uint64 {
+	for _, ip := range ips {
+		if ip == nil {
+			continue
+		}
+
+		if ip.To4() != nil {
+			return socketFamilyINET
+		}
+
+		return socketFamilyINET6
+	}
+
+	return 0
+}
+
+// ipBytes returns the address in 4 bytes for IPv4 and 16 bytes for IPv6, as dnstap readers expect
+func ipBytes(ip net.IP) []byte {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4
+	}
+
+	return ip.To16()
+}
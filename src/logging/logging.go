@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type Format string
+
+const (
+	TEXT Format = "text"
+	JSON Format = "json"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case TEXT, JSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q", value)
+	}
+}
+
+// ParseLevel parses the level by name (debug, info, warn, error), optionally with an offset, e.g. "debug-4"
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+
+	err := level.UnmarshalText([]byte(value))
+	if err != nil {
+		return level, fmt.Errorf("unknown log level %q", value)
+	}
+
+	return level, nil
+}
+
+// New returns the logger writing records of at least the given level to the writer
+func New(w io.Writer, format Format, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+
+	if format == JSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts))
+}
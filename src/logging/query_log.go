@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// CacheStatus tells whether the response was served from cache
+type CacheStatus string
+
+const (
+	CacheHit  CacheStatus = "hit"
+	CacheMiss CacheStatus = "miss"
+	CacheNone CacheStatus = "none" // response was made without consulting the resolver, e.g. an error or a refusal
+)
+
+// QueryLogEntry describes a single query handled by the server
+type QueryLogEntry struct {
+	Client    net.Addr
+	Transport string
+	ID        uint16
+	Name      string
+	Type      string
+	Class     string
+	RCode     string
+	Answers   int
+	Latency   time.Duration
+	Cache     CacheStatus
+}
+
+// QueryLog records handled queries, separately from the regular logs. Only a sample of queries can be recorded,
+// so that busy servers don't flood the log pipeline.
+type QueryLog struct {
+	logger     *slog.Logger
+	sampleRate float64
+}
+
+// NewQueryLog returns the query log recording the given fraction of queries (1 records all of them)
+func NewQueryLog(logger *slog.Logger, sampleRate float64) *QueryLog {
+	return &QueryLog{logger: logger, sampleRate: sampleRate}
+}
+
+func (v *QueryLog) Log(entry QueryLogEntry) {
+	if v.sampleRate < 1 && rand.Float64() >= v.sampleRate {
+		return
+	}
+
+	v.logger.LogAttrs(context.Background(), slog.LevelInfo, "query",
+		slog.String("client", addrString(entry.Client)),
+		slog.String("transport", entry.Transport),
+		slog.Int("id", int(entry.ID)),
+		slog.String("name", entry.Name),
+		slog.String("type", entry.Type),
+		slog.String("class", entry.Class),
+		slog.String("rcode", entry.RCode),
+		slog.Int("answers", entry.Answers),
+		slog.Duration("latency", entry.Latency),
+		slog.String("cache", string(entry.Cache)),
+	)
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+
+	return addr.String()
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "dnsgo"
+
+// registry holds dns-go metrics together with Go runtime and process metrics
+var registry = prometheus.NewRegistry()
+
+var (
+	Queries = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queries_total",
+		Help:      "Queries received from clients, by transport, query type and result code of the response.",
+	}, []string{"transport", "type", "rcode"}))
+
+	QueryDuration = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "query_duration_seconds",
+		Help:      "Time of handling client queries, from receiving the query to having the response ready.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"transport"}))
+
+	QueriesInFlight = register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queries_in_flight",
+		Help:      "Client queries currently being handled.",
+	}, []string{"transport"}))
+
+	UpstreamQueries = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_queries_total",
+		Help:      "Queries sent to upstreams (when forwarding) or name servers (during recursive resolution, labelled by IP address for the root and TLDs, \"recursive\" below them), by result code or error.",
+	}, []string{"nameserver", "rcode"}))
+
+	UpstreamQueryDuration = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_query_duration_seconds",
+		Help:      "Round trip time of queries sent to name servers or upstreams, by transport.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"transport"}))
+
+	CacheHits = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_hits_total",
+		Help:      "Lookups of resolver's caches which found a valid entry.",
+	}, []string{"cache"}))
+
+	CacheMisses = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Lookups of resolver's caches which didn't find a valid entry.",
+	}, []string{"cache"}))
+
+	CacheEvictions = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_evictions_total",
+		Help:      "Entries removed from resolver's caches to make room for new ones.",
+	}, []string{"cache"}))
+)
+
+func init() {
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+func register[T prometheus.Collector](collector T) T {
+	registry.MustRegister(collector)
+
+	return collector
+}
+
+// RegisterCounterFunc exposes a counter maintained elsewhere, whose value is read when metrics are scraped
+func RegisterCounterFunc(name string, help string, value func() float64) {
+	registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, value))
+}
+
+// Handler serves the metrics in Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,202 @@
+package pcap
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"io"
+	"net/netip"
+	"time"
+)
+
+// Message is a DNS message found in the capture
+type Message struct {
+	PacketNumber int // number of the packet carrying the message (or its last part), 0 if it's incomplete at the capture's end
+	Time         time.Time
+	Transport    string // "udp" or "tcp"
+	Source       netip.AddrPort
+	Destination  netip.AddrPort
+	Raw          []byte
+	DNS          *protocol.DnsPacket // nil if the message could not be parsed
+	Err          error
+}
+
+// Stats summarize the capture
+type Stats struct {
+	Packets   int
+	Messages  int // DNS messages, including the ones which could not be parsed
+	Failures  int // DNS messages which could not be parsed or were incomplete
+	Fragments int // fragments of IP packets, which are not reassembled
+	Undecoded int // packets of unsupported link types or protocols, or malformed ones
+}
+
+// Extractor finds DNS messages in the captured packets, sent over UDP or TCP to or from the given port
+type Extractor struct {
+	port    uint16
+	streams map[flow]*tcpStream
+	stats   Stats
+}
+
+func NewExtractor(port uint16) *Extractor {
+	return &Extractor{port: port, streams: make(map[flow]*tcpStream)}
+}
+
+// Extract returns DNS messages the packet carries, which over TCP are the ones it completes
+func (v *Extractor) Extract(packet *Packet) []*Message {
+	v.stats.Packets++
+
+	seg, err := decodePacket(packet)
+	if errors.Is(err, errFragment) {
+		v.stats.Fragments++
+		return nil
+	}
+
+	if err != nil {
+		v.stats.Undecoded++
+		return nil
+	}
+
+	if seg.source.Port() != v.port && seg.destination.Port() != v.port {
+		return nil
+	}
+
+	if seg.protocol == protocolUDP {
+		return []*Message{v.newMessage(packet, "udp", seg, seg.payload, nil)}
+	}
+
+	key := flow{seg.source, seg.destination}
+
+	stream, ok := v.streams[key]
+	if !ok {
+		stream = newTCPStream()
+		v.streams[key] = stream
+	}
+
+	if seg.flags&tcpFlagSYN != 0 {
+		stream.syn(seg.seq)
+	}
+
+	result := make([]*Message, 0)
+
+	if stream.add(seg.seq, seg.payload) {
+		// messages can't be found in the rest of the stream, so it's reported once and skipped until the next connection
+		err = errors.New("TCP segments are missing, the rest of the stream is skipped")
+		result = append(result, v.newMessage(packet, "tcp", seg, stream.data, err))
+		stream.data = nil
+	}
+
+	if !stream.broken {
+		for _, raw := range stream.messages() {
+			result = append(result, v.newMessage(packet, "tcp", seg, raw, nil))
+		}
+	}
+
+	if seg.flags&(tcpFlagFIN|tcpFlagRST) != 0 {
+		result = append(result, v.closeStream(packet, seg, key)...)
+	}
+
+	return result
+}
+
+// closeStream reports the incomplete message left in the stream when the connection is closed
+func (v *Extractor) closeStream(packet *Packet, seg *segment, key flow) []*Message {
+	stream := v.streams[key]
+	delete(v.streams, key)
+
+	if len(stream.data) == 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("incomplete TCP message, %d bytes left when the connection was closed", len(stream.data))
+
+	return []*Message{v.newMessage(packet, "tcp", seg, stream.data, err)}
+}
+
+// Flush reports incomplete messages left in TCP streams after the last packet of the capture
+func (v *Extractor) Flush() []*Message {
+	result := make([]*Message, 0)
+
+	for key, stream := range v.streams {
+		if len(stream.data) > 0 {
+			err := fmt.Errorf("incomplete TCP message, %d bytes left at the end of the capture", len(stream.data))
+			seg := &segment{source: key.source, destination: key.destination}
+
+			result = append(result, v.newMessage(&Packet{}, "tcp", seg, stream.data, err))
+		}
+	}
+
+	v.streams = make(map[flow]*tcpStream)
+
+	return result
+}
+
+func (v *Extractor) Stats() Stats {
+	return v.stats
+}
+
+// newMessage parses the raw message, unless it's already known to be broken
+func (v *Extractor) newMessage(packet *Packet, transport string, seg *segment, raw []byte, err error) *Message {
+	result := &Message{
+		PacketNumber: packet.Number,
+		Time:         packet.Time,
+		Transport:    transport,
+		Source:       seg.source,
+		Destination:  seg.destination,
+		Raw:          raw,
+		Err:          err,
+	}
+
+	if result.Err == nil {
+		dnsPacket, err := protocol.DnsPacketFromRawBuffer(raw)
+		if err != nil {
+			result.Err = err
+		} else {
+			result.DNS = dnsPacket
+		}
+	}
+
+	v.stats.Messages++
+	if result.Err != nil {
+		v.stats.Failures++
+	}
+
+	return result
+}
+
+// ReadMessages passes DNS messages found in the capture to the handler, returning the capture's stats.
+// Reading stops at the first error returned by the handler.
+func ReadMessages(r io.Reader, port uint16, handle func(message *Message) error) (Stats, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	extractor := NewExtractor(port)
+
+	for {
+		packet, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return extractor.Stats(), err
+		}
+
+		for _, message := range extractor.Extract(packet) {
+			err = handle(message)
+			if err != nil {
+				return extractor.Stats(), err
+			}
+		}
+	}
+
+	for _, message := range extractor.Flush() {
+		err = handle(message)
+		if err != nil {
+			return extractor.Stats(), err
+		}
+	}
+
+	return extractor.Stats(), nil
+}
@@ -0,0 +1,213 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+const (
+	etherTypeIPv4  = 0x0800
+	etherTypeIPv6  = 0x86DD
+	etherTypeVLAN  = 0x8100
+	etherTypeQinQ  = 0x88A8
+	protocolTCP    = 6
+	protocolUDP    = 17
+	tcpFlagFIN     = 0x01
+	tcpFlagSYN     = 0x02
+	tcpFlagRST     = 0x04
+	ipv4FlagMF     = 0x2000
+	ipv4OffsetMask = 0x1FFF
+)
+
+// errFragment is returned for fragments of IP packets, which are not reassembled
+var errFragment = errors.New("IP fragment")
+
+// segment is the transport layer's payload of the packet, with its addresses
+type segment struct {
+	protocol    uint8
+	source      netip.AddrPort
+	destination netip.AddrPort
+	seq         uint32 // TCP only
+	flags       uint8  // TCP only
+	payload     []byte
+}
+
+// decodePacket strips link, network and transport layer headers of the packet, returning its UDP or TCP segment
+func decodePacket(packet *Packet) (*segment, error) {
+	ipPacket, err := linkPayload(packet.LinkType, packet.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ipPacket) == 0 {
+		return nil, errors.New("empty IP packet")
+	}
+
+	switch ipPacket[0] >> 4 {
+	case 4:
+		return decodeIPv4(ipPacket)
+	case 6:
+		return decodeIPv6(ipPacket)
+	default:
+		return nil, fmt.Errorf("unsupported IP version %d", ipPacket[0]>>4)
+	}
+}
+
+// linkPayload returns the IP packet carried by the frame, whose IP version is told by its first byte
+func linkPayload(linkType LinkType, data []byte) ([]byte, error) {
+	switch linkType {
+	case LINKTYPE_ETHERNET:
+		if len(data) < 14 {
+			return nil, errors.New("truncated Ethernet header")
+		}
+
+		etherType := binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+
+		// 802.1Q (and 802.1ad) tags are skipped
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(data) < 4 {
+				return nil, errors.New("truncated VLAN tag")
+			}
+
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return nil, fmt.Errorf("unsupported EtherType 0x%04X", etherType)
+		}
+
+		return data, nil
+	case LINKTYPE_NULL, LINKTYPE_LOOP:
+		// address family is given in the host's byte order, and its values for IPv6 differ between systems
+		if len(data) < 4 {
+			return nil, errors.New("truncated loopback header")
+		}
+
+		return data[4:], nil
+	case LINKTYPE_RAW, LINKTYPE_IPV4, LINKTYPE_IPV6:
+		return data, nil
+	case LINKTYPE_LINUX_SLL:
+		if len(data) < 16 {
+			return nil, errors.New("truncated Linux cooked capture header")
+		}
+
+		return linuxCookedPayload(binary.BigEndian.Uint16(data[14:16]), data[16:])
+	case LINKTYPE_LINUX_SLL2:
+		if len(data) < 20 {
+			return nil, errors.New("truncated Linux cooked capture header")
+		}
+
+		return linuxCookedPayload(binary.BigEndian.Uint16(data[0:2]), data[20:])
+	default:
+		return nil, fmt.Errorf("unsupported link type %d", linkType)
+	}
+}
+
+func linuxCookedPayload(protocol uint16, data []byte) ([]byte, error) {
+	if protocol != etherTypeIPv4 && protocol != etherTypeIPv6 {
+		return nil, fmt.Errorf("unsupported protocol 0x%04X", protocol)
+	}
+
+	return data, nil
+}
+
+func decodeIPv4(data []byte) (*segment, error) {
+	if len(data) < 20 {
+		return nil, errors.New("truncated IPv4 header")
+	}
+
+	headerLength := int(data[0]&0x0F) * 4
+	totalLength := int(binary.BigEndian.Uint16(data[2:4]))
+
+	if headerLength < 20 || totalLength < headerLength || len(data) < headerLength {
+		return nil, errors.New("invalid IPv4 header")
+	}
+
+	fragmentation := binary.BigEndian.Uint16(data[6:8])
+	if fragmentation&ipv4FlagMF != 0 || fragmentation&ipv4OffsetMask != 0 {
+		return nil, errFragment
+	}
+
+	// Ethernet frames may be padded, and packets may be truncated by the capture's snap length
+	payload := data[headerLength:min(totalLength, len(data))]
+
+	source := netip.AddrFrom4([4]byte(data[12:16]))
+	destination := netip.AddrFrom4([4]byte(data[16:20]))
+
+	return decodeTransport(data[9], source, destination, payload)
+}
+
+func decodeIPv6(data []byte) (*segment, error) {
+	if len(data) < 40 {
+		return nil, errors.New("truncated IPv6 header")
+	}
+
+	payloadLength := int(binary.BigEndian.Uint16(data[4:6]))
+	nextHeader := data[6]
+	payload := data[40:min(40+payloadLength, len(data))]
+
+	// extension headers are skipped until the transport layer (RFC 8200, section 4)
+	for {
+		switch nextHeader {
+		case 0, 43, 60: // hop-by-hop options, routing, destination options
+			if len(payload) < 8 || len(payload) < (int(payload[1])+1)*8 {
+				return nil, errors.New("truncated IPv6 extension header")
+			}
+
+			nextHeader = payload[0]
+			payload = payload[(int(payload[1])+1)*8:]
+		case 44:
+			return nil, errFragment
+		default:
+			source := netip.AddrFrom16([16]byte(data[8:24]))
+			destination := netip.AddrFrom16([16]byte(data[24:40]))
+
+			return decodeTransport(nextHeader, source, destination, payload)
+		}
+	}
+}
+
+func decodeTransport(protocol uint8, source netip.Addr, destination netip.Addr, data []byte) (*segment, error) {
+	switch protocol {
+	case protocolUDP:
+		if len(data) < 8 {
+			return nil, errors.New("truncated UDP header")
+		}
+
+		length := int(binary.BigEndian.Uint16(data[4:6]))
+		if length < 8 {
+			return nil, errors.New("invalid UDP length")
+		}
+
+		return &segment{
+			protocol:    protocolUDP,
+			source:      netip.AddrPortFrom(source, binary.BigEndian.Uint16(data[0:2])),
+			destination: netip.AddrPortFrom(destination, binary.BigEndian.Uint16(data[2:4])),
+			payload:     data[8:min(length, len(data))],
+		}, nil
+	case protocolTCP:
+		if len(data) < 20 {
+			return nil, errors.New("truncated TCP header")
+		}
+
+		headerLength := int(data[12]>>4) * 4
+		if headerLength < 20 || len(data) < headerLength {
+			return nil, errors.New("invalid TCP header")
+		}
+
+		return &segment{
+			protocol:    protocolTCP,
+			source:      netip.AddrPortFrom(source, binary.BigEndian.Uint16(data[0:2])),
+			destination: netip.AddrPortFrom(destination, binary.BigEndian.Uint16(data[2:4])),
+			seq:         binary.BigEndian.Uint32(data[4:8]),
+			flags:       data[13],
+			payload:     data[headerLength:],
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported IP protocol %d", protocol)
+	}
+}
@@ -0,0 +1,146 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+var (
+	testIPv6Source      = netip.MustParseAddr("2001:db8::1")
+	testIPv6Destination = netip.MustParseAddr("2001:db8::53")
+)
+
+func udpDatagram(sourcePort uint16, destinationPort uint16, payload []byte) []byte {
+	result := binary.BigEndian.AppendUint16(nil, sourcePort)
+	result = binary.BigEndian.AppendUint16(result, destinationPort)
+	result = binary.BigEndian.AppendUint16(result, uint16(8+len(payload)))
+	result = append(result, 0x00, 0x00) // checksum
+
+	return append(result, payload...)
+}
+
+// ipv6Packet returns the IPv6 packet whose payload (with extension headers) starts with the given header
+func ipv6Packet(nextHeader uint8, payload []byte) []byte {
+	result := []byte{0x60, 0x00, 0x00, 0x00}
+	result = binary.BigEndian.AppendUint16(result, uint16(len(payload)))
+	result = append(result, nextHeader, 64)
+	result = append(result, testIPv6Source.AsSlice()...)
+	result = append(result, testIPv6Destination.AsSlice()...)
+
+	return append(result, payload...)
+}
+
+func TestIPv6ExtensionHeaders(t *testing.T) {
+	datagram := udpDatagram(5353, 53, []byte("query"))
+
+	tests := []struct {
+		name       string
+		nextHeader uint8
+		payload    []byte
+		err        error
+	}{
+		{
+			name:       "no extension headers",
+			nextHeader: protocolUDP,
+			payload:    datagram,
+		},
+		{
+			name:       "hop-by-hop options",
+			nextHeader: 0,
+			payload:    append([]byte{protocolUDP, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x00}, datagram...),
+		},
+		{
+			name:       "hop-by-hop, routing and destination options",
+			nextHeader: 0,
+			payload: bytes.Join([][]byte{
+				{43, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x00},
+				// routing header of 24 bytes
+				{60, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				make([]byte, 16),
+				{protocolUDP, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x00},
+				datagram,
+			}, nil),
+		},
+		{
+			name:       "fragment",
+			nextHeader: 0,
+			payload: bytes.Join([][]byte{
+				{44, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x00},
+				{protocolUDP, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01},
+				datagram,
+			}, nil),
+			err: errFragment,
+		},
+		{
+			name:       "truncated extension header",
+			nextHeader: 60,
+			payload:    []byte{protocolUDP, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			err:        errors.New("truncated IPv6 extension header"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seg, err := decodePacket(&Packet{LinkType: LINKTYPE_IPV6, Data: ipv6Packet(test.nextHeader, test.payload)})
+
+			if test.err != nil {
+				if err == nil || err.Error() != test.err.Error() {
+					t.Errorf("expected error %q, got %v", test.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if seg.protocol != protocolUDP || !bytes.Equal(seg.payload, []byte("query")) {
+				t.Errorf("unexpected segment %+v", seg)
+			}
+
+			if seg.source != netip.AddrPortFrom(testIPv6Source, 5353) || seg.destination != netip.AddrPortFrom(testIPv6Destination, 53) {
+				t.Errorf("unexpected addresses %s -> %s", seg.source, seg.destination)
+			}
+		})
+	}
+}
+
+func TestIPv4OverVLAN(t *testing.T) {
+	datagram := udpDatagram(53, 5353, []byte("response"))
+
+	ipv4 := []byte{
+		0x45, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 64, protocolUDP, 0x00, 0x00,
+		192, 0, 2, 53, 192, 0, 2, 1,
+	}
+	binary.BigEndian.PutUint16(ipv4[2:4], uint16(len(ipv4)+len(datagram)))
+
+	frame := bytes.Join([][]byte{
+		make([]byte, 12),         // MAC addresses
+		{0x81, 0x00, 0x00, 0x0A}, // 802.1Q tag of VLAN 10
+		{0x08, 0x00},
+		ipv4,
+		datagram,
+		make([]byte, 6), // Ethernet padding
+	}, nil)
+
+	seg, err := decodePacket(&Packet{LinkType: LINKTYPE_ETHERNET, Data: frame})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(seg.payload, []byte("response")) || seg.source != netip.MustParseAddrPort("192.0.2.53:53") {
+		t.Errorf("unexpected segment %+v", seg)
+	}
+
+	// more fragments flag
+	ipv4[6] = 0x20
+
+	_, err = decodePacket(&Packet{LinkType: LINKTYPE_RAW, Data: append(ipv4, datagram...)})
+	if !errors.Is(err, errFragment) {
+		t.Errorf("expected fragment to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,355 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// maxPacketSize limits the captured length of a packet (and size of pcapng blocks), so that a corrupted file can't
+// make the reader allocate arbitrary amounts of memory
+const maxPacketSize = 256 * 1024
+
+// LinkType is the type of the link layer header the captured packets start with (http://www.tcpdump.org/linktypes.html)
+type LinkType uint32
+
+const (
+	LINKTYPE_NULL       LinkType = 0
+	LINKTYPE_ETHERNET   LinkType = 1
+	LINKTYPE_RAW        LinkType = 101
+	LINKTYPE_LOOP       LinkType = 108
+	LINKTYPE_LINUX_SLL  LinkType = 113
+	LINKTYPE_IPV4       LinkType = 228
+	LINKTYPE_IPV6       LinkType = 229
+	LINKTYPE_LINUX_SLL2 LinkType = 276
+)
+
+// Packet is a single packet read from the capture
+type Packet struct {
+	Number   int // position in the capture, starting from 1
+	Time     time.Time
+	LinkType LinkType
+	Data     []byte
+}
+
+// Reader reads packets from a capture in the classic pcap or pcapng format, detected from the first bytes of the file
+type Reader struct {
+	r          *bufio.Reader
+	next       func() (*Packet, error)
+	number     int
+	byteOrder  binary.ByteOrder
+	linkType   LinkType      // pcap: link type of all packets
+	resolution time.Duration // pcap: unit of the fractional part of timestamps
+	interfaces []pcapngInterface
+}
+
+// pcapngInterface describes the interface packets were captured on, as given in the Interface Description Block
+type pcapngInterface struct {
+	linkType LinkType
+	// timestamps are given in units of 1/unitsPerSecond of a second
+	unitsPerSecond uint64
+}
+
+const (
+	pcapMagicMicroseconds = 0xA1B2C3D4
+	pcapMagicNanoseconds  = 0xA1B23C4D
+	pcapngBlockSHB        = 0x0A0D0D0A
+	pcapngBlockIDB        = 1
+	pcapngBlockPB         = 2 // obsolete Packet Block
+	pcapngBlockSPB        = 3
+	pcapngBlockEPB        = 6
+	pcapngByteOrderMagic  = 0x1A2B3C4D
+	pcapngOptionTSResol   = 9
+	pcapngOptionEnd       = 0
+)
+
+func NewReader(r io.Reader) (*Reader, error) {
+	result := &Reader{r: bufio.NewReader(r)}
+
+	magic, err := result.r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the capture's header: %s", err.Error())
+	}
+
+	switch {
+	case binary.BigEndian.Uint32(magic) == pcapngBlockSHB:
+		result.next = result.nextPcapng
+	case binary.BigEndian.Uint32(magic) == pcapMagicMicroseconds || binary.BigEndian.Uint32(magic) == pcapMagicNanoseconds:
+		result.byteOrder = binary.BigEndian
+		err = result.readPcapHeader()
+	case binary.LittleEndian.Uint32(magic) == pcapMagicMicroseconds || binary.LittleEndian.Uint32(magic) == pcapMagicNanoseconds:
+		result.byteOrder = binary.LittleEndian
+		err = result.readPcapHeader()
+	default:
+		return nil, errors.New("file is neither a pcap nor a pcapng capture")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Next returns the next packet of the capture, or io.EOF after the last one
+func (v *Reader) Next() (*Packet, error) {
+	packet, err := v.next()
+	if err != nil {
+		return nil, err
+	}
+
+	v.number++
+	packet.Number = v.number
+
+	return packet, nil
+}
+
+func (v *Reader) readFull(size int) ([]byte, error) {
+	result := make([]byte, size)
+
+	_, err := io.ReadFull(v.r, result)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, errors.New("capture is truncated")
+	}
+
+	return result, err
+}
+
+// readPcapHeader reads the global header of the classic pcap format
+func (v *Reader) readPcapHeader() error {
+	header, err := v.readFull(24)
+	if err != nil {
+		return err
+	}
+
+	v.resolution = time.Microsecond
+	if v.byteOrder.Uint32(header[0:4]) == pcapMagicNanoseconds {
+		v.resolution = time.Nanosecond
+	}
+
+	// upper bits of the link type field carry FCS information
+	v.linkType = LinkType(v.byteOrder.Uint32(header[20:24]) & 0x03FFFFFF)
+	v.next = v.nextPcap
+
+	return nil
+}
+
+func (v *Reader) nextPcap() (*Packet, error) {
+	header, err := v.readFull(16)
+	if err != nil {
+		return nil, err
+	}
+
+	capturedLength := v.byteOrder.Uint32(header[8:12])
+	if capturedLength > maxPacketSize {
+		return nil, fmt.Errorf("packet %d is too large (%d bytes)", v.number+1, capturedLength)
+	}
+
+	data, err := v.readFull(int(capturedLength))
+	if err != nil {
+		return nil, err
+	}
+
+	seconds := int64(v.byteOrder.Uint32(header[0:4]))
+	fraction := int64(v.byteOrder.Uint32(header[4:8]))
+
+	return &Packet{
+		Time:     time.Unix(seconds, fraction*int64(v.resolution)).UTC(),
+		LinkType: v.linkType,
+		Data:     data,
+	}, nil
+}
+
+// nextPcapng reads blocks until the next one containing a packet, handling section headers and interface descriptions
+// on the way
+func (v *Reader) nextPcapng() (*Packet, error) {
+	for {
+		blockType, body, err := v.readBlock()
+		if err != nil {
+			return nil, err
+		}
+
+		switch blockType {
+		case pcapngBlockSHB:
+			// interfaces are described again in each section
+			v.interfaces = v.interfaces[:0]
+		case pcapngBlockIDB:
+			err = v.readInterface(body)
+		case pcapngBlockEPB, pcapngBlockPB:
+			return v.readPacketBlock(blockType, body)
+		case pcapngBlockSPB:
+			return v.readSimplePacketBlock(body)
+		}
+
+		// other blocks (name resolution, statistics, ...) are skipped
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readBlock returns the type and body of the next pcapng block; section header's byte order applies to all blocks
+// of the section
+func (v *Reader) readBlock() (uint32, []byte, error) {
+	header, err := v.readFull(8)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if binary.BigEndian.Uint32(header[0:4]) == pcapngBlockSHB {
+		byteOrderMagic, err := v.r.Peek(4)
+		if err != nil {
+			return 0, nil, errors.New("capture is truncated")
+		}
+
+		switch {
+		case binary.BigEndian.Uint32(byteOrderMagic) == pcapngByteOrderMagic:
+			v.byteOrder = binary.BigEndian
+		case binary.LittleEndian.Uint32(byteOrderMagic) == pcapngByteOrderMagic:
+			v.byteOrder = binary.LittleEndian
+		default:
+			return 0, nil, errors.New("invalid byte order of pcapng section")
+		}
+	}
+
+	if v.byteOrder == nil {
+		return 0, nil, errors.New("pcapng capture has to start with a section header")
+	}
+
+	blockType := v.byteOrder.Uint32(header[0:4])
+	totalLength := v.byteOrder.Uint32(header[4:8])
+
+	// block consists of type, length, body padded to 32 bits and the length repeated
+	if totalLength < 12 || totalLength%4 != 0 || totalLength > maxPacketSize {
+		return 0, nil, fmt.Errorf("invalid pcapng block length %d", totalLength)
+	}
+
+	rest, err := v.readFull(int(totalLength) - 8)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return blockType, rest[:len(rest)-4], nil
+}
+
+func (v *Reader) readInterface(body []byte) error {
+	if len(body) < 8 {
+		return errors.New("invalid pcapng interface description")
+	}
+
+	result := pcapngInterface{
+		linkType:       LinkType(v.byteOrder.Uint16(body[0:2])),
+		unitsPerSecond: 1000000,
+	}
+
+	// options follow link type, reserved field and snap length
+	options := body[8:]
+
+	for len(options) >= 4 {
+		code := v.byteOrder.Uint16(options[0:2])
+		length := int(v.byteOrder.Uint16(options[2:4]))
+		paddedLength := (length + 3) &^ 3
+
+		if code == pcapngOptionEnd || len(options) < 4+paddedLength {
+			break
+		}
+
+		if code == pcapngOptionTSResol && length == 1 {
+			unitsPerSecond, err := timestampUnitsPerSecond(options[4])
+			if err != nil {
+				return err
+			}
+
+			result.unitsPerSecond = unitsPerSecond
+		}
+
+		options = options[4+paddedLength:]
+	}
+
+	if result.unitsPerSecond == 0 {
+		return errors.New("invalid pcapng timestamp resolution")
+	}
+
+	v.interfaces = append(v.interfaces, result)
+
+	return nil
+}
+
+// timestampUnitsPerSecond returns the number of units of the timestamp resolution in a second, given the if_tsresol
+// option: resolution is 10^-n or, with the most significant bit set, 2^-n seconds
+func timestampUnitsPerSecond(resolution byte) (uint64, error) {
+	base, exponent := uint64(10), resolution
+	if resolution&0x80 != 0 {
+		base, exponent = 2, resolution&0x7F
+	}
+
+	result := uint64(1)
+
+	for i := byte(0); i < exponent; i++ {
+		if result > math.MaxUint64/base {
+			return 0, fmt.Errorf("unsupported pcapng timestamp resolution 0x%02X", resolution)
+		}
+
+		result *= base
+	}
+
+	return result, nil
+}
+
+// readPacketBlock reads Enhanced Packet Block, or the obsolete Packet Block, which differs only in the interface ID
+// being 16-bit and followed by the drops count
+func (v *Reader) readPacketBlock(blockType uint32, body []byte) (*Packet, error) {
+	if len(body) < 20 {
+		return nil, errors.New("invalid pcapng packet block")
+	}
+
+	interfaceID := v.byteOrder.Uint32(body[0:4])
+	if blockType == pcapngBlockPB {
+		interfaceID = uint32(v.byteOrder.Uint16(body[0:2]))
+	}
+
+	if interfaceID >= uint32(len(v.interfaces)) {
+		return nil, fmt.Errorf("packet refers to undescribed interface %d", interfaceID)
+	}
+
+	captureInterface := v.interfaces[interfaceID]
+	timestamp := uint64(v.byteOrder.Uint32(body[4:8]))<<32 | uint64(v.byteOrder.Uint32(body[8:12]))
+	capturedLength := v.byteOrder.Uint32(body[12:16])
+
+	if capturedLength > uint32(len(body)-20) {
+		return nil, errors.New("invalid captured length of pcapng packet")
+	}
+
+	seconds := timestamp / captureInterface.unitsPerSecond
+	fraction := timestamp % captureInterface.unitsPerSecond
+
+	// the fraction is converted to nanoseconds with 128-bit intermediate product, as it overflows 64 bits
+	// for resolutions finer than about 50 picoseconds; the quotient fits, since the fraction is less than a second
+	high, low := bits.Mul64(fraction, uint64(time.Second))
+	nanoseconds, _ := bits.Div64(high, low, captureInterface.unitsPerSecond)
+
+	return &Packet{
+		Time:     time.Unix(int64(seconds), int64(nanoseconds)).UTC(),
+		LinkType: captureInterface.linkType,
+		Data:     body[20 : 20+capturedLength],
+	}, nil
+}
+
+// readSimplePacketBlock reads Simple Packet Block, which has no timestamp and always comes from the first interface
+func (v *Reader) readSimplePacketBlock(body []byte) (*Packet, error) {
+	if len(body) < 4 || len(v.interfaces) == 0 {
+		return nil, errors.New("invalid pcapng simple packet block")
+	}
+
+	originalLength := v.byteOrder.Uint32(body[0:4])
+
+	return &Packet{
+		LinkType: v.interfaces[0].linkType,
+		Data:     body[4 : 4+min(originalLength, uint32(len(body)-4))],
+	}, nil
+}
@@ -0,0 +1,257 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// pcapngBlock frames the little-endian pcapng block, padding its body to 32 bits
+func pcapngBlock(blockType uint32, body []byte) []byte {
+	padded := append(body, make([]byte, (4-len(body)%4)%4)...)
+	totalLength := uint32(12 + len(padded))
+
+	result := binary.LittleEndian.AppendUint32(nil, blockType)
+	result = binary.LittleEndian.AppendUint32(result, totalLength)
+	result = append(result, padded...)
+
+	return binary.LittleEndian.AppendUint32(result, totalLength)
+}
+
+var pcapngSectionHeader = pcapngBlock(pcapngBlockSHB, []byte{
+	0x4D, 0x3C, 0x2B, 0x1A, // byte order magic
+	0x01, 0x00, 0x00, 0x00, // version 1.0
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // section length not given
+})
+
+// pcapngInterfaceBlock describes an Ethernet interface, with the given options
+func pcapngInterfaceBlock(options ...byte) []byte {
+	body := []byte{
+		0x01, 0x00, // LINKTYPE_ETHERNET
+		0x00, 0x00, // reserved
+		0x00, 0x00, 0x04, 0x00, // snap length
+	}
+
+	return pcapngBlock(pcapngBlockIDB, append(body, options...))
+}
+
+func pcapngEnhancedPacketBlock(interfaceID uint32, timestamp uint64, data []byte) []byte {
+	body := binary.LittleEndian.AppendUint32(nil, interfaceID)
+	body = binary.LittleEndian.AppendUint32(body, uint32(timestamp>>32))
+	body = binary.LittleEndian.AppendUint32(body, uint32(timestamp))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(data)))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(data)))
+
+	return pcapngBlock(pcapngBlockEPB, append(body, data...))
+}
+
+func readAll(t *testing.T, capture []byte) []*Packet {
+	t.Helper()
+
+	reader, err := NewReader(bytes.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make([]*Packet, 0)
+
+	for {
+		packet, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return result
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result = append(result, packet)
+	}
+}
+
+func TestPcap(t *testing.T) {
+	tests := []struct {
+		name    string
+		capture []byte
+		time    time.Time
+	}{
+		{
+			name: "little-endian with microseconds",
+			capture: []byte{
+				0xD4, 0xC3, 0xB2, 0xA1, 0x02, 0x00, 0x04, 0x00, // magic, version 2.4
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // time zone, accuracy
+				0x00, 0x00, 0x04, 0x00, 0x71, 0x00, 0x00, 0x00, // snap length, LINKTYPE_LINUX_SLL
+				0x00, 0xE1, 0xF5, 0x05, 0x40, 0xE2, 0x01, 0x00, // 100000000 s, 123456 us
+				0x04, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, // captured and original length
+				0xDE, 0xAD, 0xBE, 0xEF,
+			},
+			time: time.Unix(100000000, 123456000),
+		},
+		{
+			name: "big-endian with nanoseconds",
+			capture: []byte{
+				0xA1, 0xB2, 0x3C, 0x4D, 0x00, 0x02, 0x00, 0x04,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x71,
+				0x05, 0xF5, 0xE1, 0x00, 0x07, 0x5B, 0xCD, 0x15, // 100000000 s, 123456789 ns
+				0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x04,
+				0xDE, 0xAD, 0xBE, 0xEF,
+			},
+			time: time.Unix(100000000, 123456789),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			packets := readAll(t, test.capture)
+
+			if len(packets) != 1 {
+				t.Fatalf("expected 1 packet, got %d", len(packets))
+			}
+
+			packet := packets[0]
+
+			if packet.Number != 1 || packet.LinkType != LINKTYPE_LINUX_SLL || !bytes.Equal(packet.Data, []byte{0xDE, 0xAD, 0xBE, 0xEF}) {
+				t.Errorf("unexpected packet %+v", packet)
+			}
+
+			if !packet.Time.Equal(test.time) {
+				t.Errorf("expected time %s, got %s", test.time, packet.Time)
+			}
+		})
+	}
+}
+
+func TestTruncatedPcap(t *testing.T) {
+	capture := []byte{
+		0xD4, 0xC3, 0xB2, 0xA1, 0x02, 0x00, 0x04, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x04, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00,
+		0xDE, 0xAD,
+	}
+
+	reader, err := NewReader(bytes.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Next()
+	if err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("expected truncated packet to be an error, got %v", err)
+	}
+}
+
+func TestPcapngTimestampResolution(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   []byte
+		timestamp uint64
+		time      time.Time
+	}{
+		{
+			name:      "default microseconds",
+			timestamp: 1500000,
+			time:      time.Unix(1, 500000000),
+		},
+		{
+			name: "nanoseconds after another option",
+			options: []byte{
+				0x02, 0x00, 0x04, 0x00, 'e', 't', 'h', '0', // if_name
+				0x09, 0x00, 0x01, 0x00, 0x09, 0x00, 0x00, 0x00, // if_tsresol 10^-9, padded
+				0x00, 0x00, 0x00, 0x00, // opt_endofopt
+			},
+			timestamp: 1500000001,
+			time:      time.Unix(1, 500000001),
+		},
+		{
+			name:      "picoseconds",
+			options:   []byte{0x09, 0x00, 0x01, 0x00, 0x0C, 0x00, 0x00, 0x00},
+			timestamp: 1700000000000000000,
+			time:      time.Unix(1700000, 0),
+		},
+		{
+			name:      "fraction of picoseconds",
+			options:   []byte{0x09, 0x00, 0x01, 0x00, 0x0C, 0x00, 0x00, 0x00},
+			timestamp: 1999999999999,
+			time:      time.Unix(1, 999999999),
+		},
+		{
+			name:      "power of two",
+			options:   []byte{0x09, 0x00, 0x01, 0x00, 0x8A, 0x00, 0x00, 0x00}, // 2^-10
+			timestamp: 3*1024 + 256,
+			time:      time.Unix(3, 250000000),
+		},
+		{
+			name:      "options after the end are ignored",
+			options:   []byte{0x00, 0x00, 0x00, 0x00, 0x09, 0x00, 0x01, 0x00, 0x09, 0x00, 0x00, 0x00},
+			timestamp: 1500000,
+			time:      time.Unix(1, 500000000),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			capture := append([]byte{}, pcapngSectionHeader...)
+			capture = append(capture, pcapngInterfaceBlock(test.options...)...)
+			capture = append(capture, pcapngEnhancedPacketBlock(0, test.timestamp, []byte{0xDE, 0xAD, 0xBE})...)
+
+			packets := readAll(t, capture)
+
+			if len(packets) != 1 {
+				t.Fatalf("expected 1 packet, got %d", len(packets))
+			}
+
+			if !packets[0].Time.Equal(test.time) {
+				t.Errorf("expected time %s, got %s", test.time, packets[0].Time)
+			}
+
+			if packets[0].LinkType != LINKTYPE_ETHERNET || !bytes.Equal(packets[0].Data, []byte{0xDE, 0xAD, 0xBE}) {
+				t.Errorf("unexpected packet %+v", packets[0])
+			}
+		})
+	}
+}
+
+func TestPcapngUnsupportedTimestampResolution(t *testing.T) {
+	capture := append([]byte{}, pcapngSectionHeader...)
+	capture = append(capture, pcapngInterfaceBlock(0x09, 0x00, 0x01, 0x00, 0x14, 0x00, 0x00, 0x00)...) // 10^-20
+
+	reader, err := NewReader(bytes.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Next()
+	if err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("expected resolution overflowing 64 bits to be an error, got %v", err)
+	}
+}
+
+func TestPcapngInterfacesOfSections(t *testing.T) {
+	capture := append([]byte{}, pcapngSectionHeader...)
+	capture = append(capture, pcapngInterfaceBlock()...)
+	capture = append(capture, pcapngEnhancedPacketBlock(0, 0, []byte{0x01})...)
+	capture = append(capture, pcapngSectionHeader...)
+	capture = append(capture, pcapngEnhancedPacketBlock(0, 0, []byte{0x02})...)
+
+	reader, err := NewReader(bytes.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second section doesn't describe its interfaces
+	_, err = reader.Next()
+	if err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("expected packet of undescribed interface to be an error, got %v", err)
+	}
+}
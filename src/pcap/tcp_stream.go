@@ -0,0 +1,121 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// maxPendingBytes limits the data of out-of-order segments waiting for the missing ones, after which the segments
+// are considered lost
+const maxPendingBytes = 256 * 1024
+
+// flow identifies one direction of the TCP connection
+type flow struct {
+	source      netip.AddrPort
+	destination netip.AddrPort
+}
+
+// tcpStream reassembles data sent in one direction of the TCP connection, which carries DNS messages prefixed
+// with their length (RFC 1035, section 4.2.2)
+type tcpStream struct {
+	started      bool
+	broken       bool // segments were lost, so messages can't be found in the rest of the stream
+	nextSeq      uint32
+	pending      map[uint32][]byte
+	pendingBytes int
+	data         []byte // received data not consumed by complete messages yet
+}
+
+func newTCPStream() *tcpStream {
+	return &tcpStream{pending: make(map[uint32][]byte)}
+}
+
+// syn starts the stream after the connection's initial sequence number
+func (v *tcpStream) syn(seq uint32) {
+	*v = *newTCPStream()
+	v.started = true
+	v.nextSeq = seq + 1
+}
+
+// add puts the segment's payload into the stream, returning true if it turned out that segments have been lost
+func (v *tcpStream) add(seq uint32, payload []byte) bool {
+	if v.broken || len(payload) == 0 {
+		return false
+	}
+
+	// the capture may start in the middle of the connection
+	if !v.started {
+		v.started = true
+		v.nextSeq = seq
+	}
+
+	// sequence numbers wrap around, so they're compared by their distance
+	distance := int32(seq - v.nextSeq)
+
+	switch {
+	case distance > 0:
+		if _, ok := v.pending[seq]; !ok {
+			v.pending[seq] = payload
+			v.pendingBytes += len(payload)
+		}
+
+		if v.pendingBytes > maxPendingBytes {
+			v.broken = true
+			v.pending = nil
+			return true
+		}
+
+		return false
+	case int(-distance) >= len(payload):
+		// retransmission of data already received
+		return false
+	default:
+		v.append(payload[-distance:])
+	}
+
+	// segments which came out of order may continue the stream now
+	for {
+		progressed := false
+
+		for pendingSeq, pendingPayload := range v.pending {
+			distance := int32(pendingSeq - v.nextSeq)
+			if distance > 0 {
+				continue
+			}
+
+			delete(v.pending, pendingSeq)
+			v.pendingBytes -= len(pendingPayload)
+
+			if int(-distance) < len(pendingPayload) {
+				v.append(pendingPayload[-distance:])
+				progressed = true
+			}
+		}
+
+		if !progressed {
+			return false
+		}
+	}
+}
+
+func (v *tcpStream) append(payload []byte) {
+	v.data = append(v.data, payload...)
+	v.nextSeq += uint32(len(payload))
+}
+
+// messages returns complete messages received since the last call
+func (v *tcpStream) messages() [][]byte {
+	result := make([][]byte, 0)
+
+	for len(v.data) >= 2 {
+		length := int(binary.BigEndian.Uint16(v.data[0:2]))
+		if len(v.data) < 2+length {
+			break
+		}
+
+		result = append(result, v.data[2:2+length])
+		v.data = v.data[2+length:]
+	}
+
+	return result
+}
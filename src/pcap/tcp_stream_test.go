@@ -0,0 +1,117 @@
+package pcap
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testStreamData carries two DNS messages prefixed with their length, of 5 and 3 bytes
+var testStreamData = []byte{0x00, 0x05, 'h', 'e', 'l', 'l', 'o', 0x00, 0x03, 'd', 'n', 's'}
+
+type testSegment struct {
+	offset int // relative to the initial sequence number + 1
+	end    int
+}
+
+func TestTCPStreamReassembly(t *testing.T) {
+	tests := []struct {
+		name     string
+		isn      uint32
+		segments []testSegment
+	}{
+		{
+			name:     "in order",
+			isn:      1000,
+			segments: []testSegment{{0, 4}, {4, 9}, {9, 12}},
+		},
+		{
+			name:     "out of order",
+			isn:      1000,
+			segments: []testSegment{{9, 12}, {4, 9}, {0, 4}},
+		},
+		{
+			name:     "retransmitted",
+			isn:      1000,
+			segments: []testSegment{{0, 4}, {0, 4}, {4, 9}, {0, 9}, {9, 12}, {4, 12}},
+		},
+		{
+			name:     "overlapping retransmission with new data",
+			isn:      1000,
+			segments: []testSegment{{0, 4}, {2, 9}, {7, 12}},
+		},
+		{
+			name:     "out of order overlapping",
+			isn:      1000,
+			segments: []testSegment{{6, 12}, {2, 8}, {0, 3}},
+		},
+		{
+			name:     "wrapped sequence number",
+			isn:      0xFFFFFFFA,
+			segments: []testSegment{{0, 4}, {4, 9}, {9, 12}},
+		},
+		{
+			name:     "wrapped sequence number out of order",
+			isn:      0xFFFFFFFA,
+			segments: []testSegment{{9, 12}, {4, 9}, {0, 4}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := newTCPStream()
+			stream.syn(test.isn)
+
+			messages := make([][]byte, 0)
+
+			for _, seg := range test.segments {
+				if stream.add(test.isn+1+uint32(seg.offset), testStreamData[seg.offset:seg.end]) {
+					t.Fatalf("segments reported as lost")
+				}
+
+				messages = append(messages, stream.messages()...)
+			}
+
+			if len(messages) != 2 || !bytes.Equal(messages[0], []byte("hello")) || !bytes.Equal(messages[1], []byte("dns")) {
+				t.Errorf("unexpected messages %q", messages)
+			}
+
+			if stream.nextSeq != test.isn+1+uint32(len(testStreamData)) || len(stream.pending) != 0 || stream.pendingBytes != 0 {
+				t.Errorf("unexpected state of the stream after all segments: %+v", stream)
+			}
+		})
+	}
+}
+
+func TestTCPStreamStartedMidConnection(t *testing.T) {
+	stream := newTCPStream()
+
+	stream.add(5000, testStreamData[:7])
+	stream.add(5007, testStreamData[7:])
+
+	if messages := stream.messages(); len(messages) != 2 {
+		t.Errorf("expected 2 messages, got %q", messages)
+	}
+}
+
+func TestTCPStreamLostSegment(t *testing.T) {
+	stream := newTCPStream()
+	stream.syn(0)
+
+	stream.add(1, testStreamData[:2])
+
+	// the segment after the gap is never followed by the missing one
+	payload := make([]byte, 1024)
+	lost := false
+
+	for seq := uint32(100); !lost && seq < 100+2*maxPendingBytes; seq += uint32(len(payload)) {
+		lost = stream.add(seq, payload)
+	}
+
+	if !lost || !stream.broken {
+		t.Fatalf("expected the stream to be broken once too much data is pending")
+	}
+
+	if stream.add(3, testStreamData[2:]) || len(stream.messages()) != 0 {
+		t.Errorf("expected the broken stream to ignore next segments")
+	}
+}
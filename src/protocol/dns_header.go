@@ -9,6 +9,9 @@ import (
 	"strings"
 )
 
+// DnsHeaderSize is the size of the header in the wire format
+const DnsHeaderSize = 12
+
 type DnsHeader struct {
 	ID                  uint16
 	IsResponse          bool
@@ -88,7 +88,12 @@ func (v *DnsPacket) ToBuffer() (*buffer.BytePacketBuffer, error) {
 }
 
 func (v *DnsPacket) ToRawBuffer() ([]byte, error) {
-	buf := buffer.NewBytePacketBuffer()
+	return v.ToRawBufferWithSize(buffer.DNSBufferSize)
+}
+
+// ToRawBufferWithSize serializes the packet, allowing it to be larger than 512 bytes (e.g. when EDNS is in use)
+func (v *DnsPacket) ToRawBufferWithSize(size uint) ([]byte, error) {
+	buf := buffer.NewBytePacketBufferWithSize(size)
 
 	err := v.Write(buf)
 	if err != nil {
@@ -98,6 +103,92 @@ func (v *DnsPacket) ToRawBuffer() ([]byte, error) {
 	return buf.GetBytes(), nil
 }
 
+// ToRawBufferTruncated serializes the packet, dropping whole RRsets from the end of the message if it doesn't fit in size bytes.
+// TC flag is set (also in the packet's header) if any answer or authority records had to be dropped (RFC 2181, section 9).
+func (v *DnsPacket) ToRawBufferTruncated(size uint) ([]byte, error) {
+	result, err := v.ToRawBufferWithSize(size)
+	if err == nil {
+		return result, nil
+	}
+
+	truncated := *v
+	truncated.Answers = append([]DnsRecord{}, v.Answers...)
+	truncated.Authorities = append([]DnsRecord{}, v.Authorities...)
+	truncated.Resources = append([]DnsRecord{}, v.Resources...)
+
+	for truncated.dropLastRRSet() {
+		result, err = truncated.ToRawBufferWithSize(size)
+		if err == nil {
+			v.Header.TruncatedMessage = truncated.Header.TruncatedMessage
+
+			return result, nil
+		}
+	}
+
+	return nil, err
+}
+
+// dropLastRRSet removes the last RRset from the message, starting with the additional section (but keeping OPT record)
+func (v *DnsPacket) dropLastRRSet() bool {
+	resources := make([]DnsRecord, 0, len(v.Resources))
+	var opt DnsRecord
+
+	for _, record := range v.Resources {
+		if record.GetType() == common.OPT {
+			opt = record
+		} else {
+			resources = append(resources, record)
+		}
+	}
+
+	v.Resources = resources
+
+	switch {
+	case len(resources) > 0:
+		v.Resources = dropLastRRSet(resources)
+	case len(v.Authorities) > 0:
+		v.Authorities = dropLastRRSet(v.Authorities)
+		v.Header.TruncatedMessage = true
+	case len(v.Answers) > 0:
+		v.Answers = dropLastRRSet(v.Answers)
+		v.Header.TruncatedMessage = true
+	default:
+		return false
+	}
+
+	if opt != nil {
+		v.Resources = append(v.Resources, opt)
+	}
+
+	return true
+}
+
+// dropLastRRSet removes records sharing the name and type of the last record (including the signatures covering them)
+func dropLastRRSet(records []DnsRecord) []DnsRecord {
+	last := records[len(records)-1]
+	name, qType := last.GetName(), rrsetType(last)
+
+	result := make([]DnsRecord, 0, len(records))
+
+	for _, record := range records {
+		if !strings.EqualFold(record.GetName(), name) || rrsetType(record) != qType {
+			result = append(result, record)
+		}
+	}
+
+	return result
+}
+
+// rrsetType returns the type of the RRset the record belongs to, which for signatures is the type they cover
+func rrsetType(record DnsRecord) common.QueryType {
+	rrsig, ok := record.(*dns_record.RRSIG)
+	if ok {
+		return rrsig.GetTypeCovered()
+	}
+
+	return record.GetType()
+}
+
 func (v *DnsPacket) Write(buf *buffer.BytePacketBuffer) error {
 	v.Header.QuestionsCount = uint16(len(v.Questions))
 	v.Header.AnswersCount = uint16(len(v.Answers))
@@ -220,6 +311,21 @@ func (v *DnsPacket) GetUnresolvedNS(qName string) *dns_record.NS {
 	return ns[0]
 }
 
+// GetOPT returns EDNS pseudo-record from the additional section, if there is one
+func (v *DnsPacket) GetOPT() *dns_record.OPT {
+	for _, record := range v.Resources {
+		if record.GetType() == common.OPT {
+			opt, ok := record.(*dns_record.OPT)
+
+			if ok {
+				return opt
+			}
+		}
+	}
+
+	return nil
+}
+
 func (v *DnsPacket) GetFirstARecord() *dns_record.A {
 	for _, record := range v.Answers {
 		if record.GetType() == common.A {
@@ -3,7 +3,9 @@ package dns_record
 import (
 	"fmt"
 	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
 	"github.com/wiktor-mazur/dns-go/src/utils"
+	"net"
 	"strings"
 )
 
@@ -12,6 +14,23 @@ type A struct {
 	ip utils.IPv4
 }
 
+func NewA(name string, ttl uint32, ip net.IP) (*A, error) {
+	abstract, err := newAbstractRecord(name, common.A, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	ipv4 := ip.To4()
+	if ipv4 == nil {
+		return nil, fmt.Errorf("%s is not an IPv4 address", ip.String())
+	}
+
+	result := &A{AbstractDnsRecord: abstract}
+	result.ip = utils.IPv4{Octets: []byte(ipv4)}
+
+	return result, nil
+}
+
 func (v *A) GetIP() utils.IPv4 {
 	return v.ip
 }
@@ -63,7 +82,7 @@ func (v *A) WriteData(buf *buffer.BytePacketBuffer) error {
 func (v *A) String() string {
 	r := new(strings.Builder)
 
-	fmt.Fprintf(r, v.AbstractDnsRecord.String())
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
 	fmt.Fprintf(r, "IPv4: %s", v.ip.String())
 
 	return r.String()
@@ -72,3 +91,38 @@ func (v *A) String() string {
 func (v *A) CompactString() string {
 	return fmt.Sprintf("A { Domain: %s, IP: %s, TTL: %d }", v.Name, v.ip.String(), v.TTL)
 }
+
+func (v *A) PresentationString() string {
+	return v.presentationPreamble() + v.ip.String()
+}
+
+func (v *A) ParseData(fields []string) error {
+	if len(fields) != 1 {
+		return fmt.Errorf("A record data must be an IPv4 address")
+	}
+
+	ip, err := parseIP(fields[0])
+	if err != nil {
+		return err
+	}
+
+	record, err := NewA(v.Name, 0, ip)
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *A) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *A) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.A
+
+	return UnmarshalRecordJSON(data, v)
+}
@@ -3,7 +3,9 @@ package dns_record
 import (
 	"fmt"
 	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
 	"github.com/wiktor-mazur/dns-go/src/utils"
+	"net"
 	"strings"
 )
 
@@ -12,6 +14,26 @@ type AAAA struct {
 	ip utils.IPv6
 }
 
+func NewAAAA(name string, ttl uint32, ip net.IP) (*AAAA, error) {
+	abstract, err := newAbstractRecord(name, common.AAAA, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ip) != net.IPv6len || ip.To4() != nil {
+		return nil, fmt.Errorf("%s is not an IPv6 address", ip.String())
+	}
+
+	result := &AAAA{AbstractDnsRecord: abstract}
+	result.ip = utils.IPv6{Data: []byte(ip)}
+
+	return result, nil
+}
+
+func (v *AAAA) GetIP() utils.IPv6 {
+	return v.ip
+}
+
 func (v *AAAA) ReadData(buf *buffer.BytePacketBuffer) error {
 	if v.DataLength != uint16(16) {
 		return fmt.Errorf("invalid data length in AAAA record")
@@ -59,7 +81,7 @@ func (v *AAAA) WriteData(buf *buffer.BytePacketBuffer) error {
 func (v *AAAA) String() string {
 	r := new(strings.Builder)
 
-	fmt.Fprintf(r, v.AbstractDnsRecord.String())
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
 	fmt.Fprintf(r, "IPv6: %s", v.ip.String())
 
 	return r.String()
@@ -68,3 +90,38 @@ func (v *AAAA) String() string {
 func (v *AAAA) CompactString() string {
 	return fmt.Sprintf("A { Domain: %s, IP: %s, TTL: %d }", v.Name, v.ip.String(), v.TTL)
 }
+
+func (v *AAAA) PresentationString() string {
+	return v.presentationPreamble() + net.IP(v.ip.Data).String()
+}
+
+func (v *AAAA) ParseData(fields []string) error {
+	if len(fields) != 1 {
+		return fmt.Errorf("AAAA record data must be an IPv6 address")
+	}
+
+	ip, err := parseIP(fields[0])
+	if err != nil {
+		return err
+	}
+
+	record, err := NewAAAA(v.Name, 0, ip)
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *AAAA) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *AAAA) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.AAAA
+
+	return UnmarshalRecordJSON(data, v)
+}
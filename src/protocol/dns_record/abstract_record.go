@@ -23,6 +23,21 @@ func NewAbstractRecord() AbstractDnsRecord {
 	return result
 }
 
+// NewUnknownRecord creates a record of a type not known to dns-go, with data given in the wire format (RFC 3597)
+func NewUnknownRecord(name string, qType common.QueryType, ttl uint32, rData []byte) (*AbstractDnsRecord, error) {
+	result, err := newAbstractRecord(name, qType, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	err = result.SetRData(rData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 func (v *AbstractDnsRecord) GetName() string {
 	return v.Name
 }
@@ -31,6 +46,14 @@ func (v *AbstractDnsRecord) GetType() common.QueryType {
 	return v.QueryType
 }
 
+func (v *AbstractDnsRecord) GetClass() common.Class {
+	return v.Class
+}
+
+func (v *AbstractDnsRecord) GetTTL() uint32 {
+	return v.TTL
+}
+
 func (v *AbstractDnsRecord) ReadPreamble(buf *buffer.BytePacketBuffer) error {
 	name, err := buf.ReadLabel()
 	if err != nil {
@@ -66,22 +89,93 @@ func (v *AbstractDnsRecord) ReadPreamble(buf *buffer.BytePacketBuffer) error {
 	return nil
 }
 
+// ReadData reads data of a record of type not known to dns-go, keeping it as is, so it can be passed through (RFC 3597)
 func (v *AbstractDnsRecord) ReadData(buf *buffer.BytePacketBuffer) error {
-	dataRead := uint16(0)
+	if namesCount, ok := compressibleTypes[v.QueryType]; ok {
+		return v.readCompressibleData(buf, namesCount)
+	}
 
-	for dataRead < v.DataLength {
-		curByte, err := buf.ReadByte()
+	data, err := buf.ReadBytes(uint(v.DataLength))
+	if err != nil {
+		return err
+	}
+
+	v.data = data
+
+	return nil
+}
+
+/*
+ * Names in data of the well-known types from RFC 1035 may be compressed, which means they point to other parts of
+ * the original message, so they have to be decompressed before the data can be copied to another message (RFC 3597, section 4).
+ * Types implemented by dns-go (NS, CNAME, SOA, MX) are not listed, as their names are decompressed while being read.
+ */
+var compressibleTypes = map[common.QueryType]int{
+	common.MD:    1,
+	common.MF:    1,
+	common.MB:    1,
+	common.MG:    1,
+	common.MR:    1,
+	common.PTR:   1,
+	common.MINFO: 2,
+}
+
+func (v *AbstractDnsRecord) readCompressibleData(buf *buffer.BytePacketBuffer, namesCount int) error {
+	dataStart := buf.GetPos()
+	data := make([]byte, 0)
+
+	for i := 0; i < namesCount; i++ {
+		name, err := buf.ReadLabel()
 		if err != nil {
 			return err
 		}
 
-		v.data = append(v.data, curByte)
-		dataRead += 1
+		wire, err := nameToWire(name)
+		if err != nil {
+			return err
+		}
+
+		data = append(data, wire...)
+	}
+
+	if buf.GetPos()-dataStart != uint(v.DataLength) {
+		return fmt.Errorf("invalid data length in %s record", v.QueryType.String())
 	}
 
+	v.data = data
+	v.DataLength = uint16(len(data))
+
+	return nil
+}
+
+// GetRData returns record's data in the wire format
+func (v *AbstractDnsRecord) GetRData() []byte {
+	return v.data
+}
+
+// SetRData sets record's data in the wire format, which is written as is
+func (v *AbstractDnsRecord) SetRData(data []byte) error {
+	if len(data) > 0xFFFF {
+		return fmt.Errorf("record data is too long (%d bytes)", len(data))
+	}
+
+	v.data = data
+	v.DataLength = uint16(len(data))
+
 	return nil
 }
 
+// readRemainingData reads the rest of record's data, given the position in buffer at which the data started
+func (v *AbstractDnsRecord) readRemainingData(buf *buffer.BytePacketBuffer, dataStart uint) ([]byte, error) {
+	dataRead := buf.GetPos() - dataStart
+
+	if dataRead > uint(v.DataLength) {
+		return nil, fmt.Errorf("%s record data exceeds declared length", v.QueryType.String())
+	}
+
+	return buf.ReadBytes(uint(v.DataLength) - dataRead)
+}
+
 func (v *AbstractDnsRecord) WritePreamble(buf *buffer.BytePacketBuffer) error {
 	err := buf.WriteLabel(v.Name)
 	if err != nil {
@@ -112,11 +206,9 @@ func (v *AbstractDnsRecord) WriteData(buf *buffer.BytePacketBuffer) error {
 		return err
 	}
 
-	for i := uint16(0); i < v.DataLength; i++ {
-		err = buf.WriteByte(v.data[i])
-		if err != nil {
-			return err
-		}
+	err = buf.WriteBytes(v.data)
+	if err != nil {
+		return err
 	}
 
 	return nil
@@ -135,5 +227,10 @@ func (v *AbstractDnsRecord) String() string {
 }
 
 func (v *AbstractDnsRecord) CompactString() string {
-	return fmt.Sprintf("DnsRecord { Name: %s, Type: %d, Class: %s, TTL: %d }", v.Name, v.QueryType, v.Class.String(), v.TTL)
+	return fmt.Sprintf("DnsRecord { Name: %s, Type: %d, Class: %s, TTL: %d, Data: %s }", v.Name, v.QueryType, v.Class.String(), v.TTL, genericRDataPresentation(v.data))
+}
+
+// PresentationString returns the record in the generic presentation format for unknown types (RFC 3597, section 5)
+func (v *AbstractDnsRecord) PresentationString() string {
+	return v.presentationPreamble() + genericRDataPresentation(v.data)
 }
@@ -0,0 +1,48 @@
+package dns_record
+
+import (
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"strings"
+)
+
+type dataWriter interface {
+	WriteData(buf *buffer.BytePacketBuffer) error
+}
+
+// CanonicalRData returns data of the record in the canonical form used by DNSSEC (RFC 4034, section 6.2),
+// which means lowercase domain names embedded in the data of the record types that have them
+func CanonicalRData(record dataWriter) ([]byte, error) {
+	switch r := record.(type) {
+	case *NS:
+		canonical := *r
+		canonical.host = strings.ToLower(r.host)
+		record = &canonical
+	case *CNAME:
+		canonical := *r
+		canonical.host = strings.ToLower(r.host)
+		record = &canonical
+	case *MX:
+		canonical := *r
+		canonical.host = strings.ToLower(r.host)
+		record = &canonical
+	case *SOA:
+		canonical := *r
+		canonical.mName = strings.ToLower(r.mName)
+		canonical.rName = strings.ToLower(r.rName)
+		record = &canonical
+	case *RRSIG:
+		canonical := *r
+		canonical.signerName = strings.ToLower(r.signerName)
+		record = &canonical
+	}
+
+	buf := buffer.NewBytePacketBufferWithSize(buffer.MaxDNSBufferSize)
+
+	err := record.WriteData(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip the data length prefix
+	return buf.GetBytes()[2:], nil
+}
@@ -3,6 +3,7 @@ package dns_record
 import (
 	"fmt"
 	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
 	"strings"
 )
 
@@ -11,6 +12,24 @@ type CNAME struct {
 	host string
 }
 
+func NewCNAME(name string, ttl uint32, host string) (*CNAME, error) {
+	abstract, err := newAbstractRecord(name, common.CNAME, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err = validateHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CNAME{AbstractDnsRecord: abstract, host: host}, nil
+}
+
+func (v *CNAME) GetHost() string {
+	return v.host
+}
+
 func (v *CNAME) ReadData(buf *buffer.BytePacketBuffer) error {
 	host, err := buf.ReadLabel()
 	if err != nil {
@@ -36,7 +55,7 @@ func (v *CNAME) WriteData(buf *buffer.BytePacketBuffer) error {
 func (v *CNAME) String() string {
 	r := new(strings.Builder)
 
-	fmt.Fprintf(r, v.AbstractDnsRecord.String())
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
 	fmt.Fprintf(r, "Host: %s", v.host)
 
 	return r.String()
@@ -45,3 +64,33 @@ func (v *CNAME) String() string {
 func (v *CNAME) CompactString() string {
 	return fmt.Sprintf("CNAME { Domain: %s, Host: %s, TTL: %d }", v.Name, v.host, v.TTL)
 }
+
+func (v *CNAME) PresentationString() string {
+	return v.presentationPreamble() + fqdn(v.host)
+}
+
+func (v *CNAME) ParseData(fields []string) error {
+	if len(fields) != 1 {
+		return fmt.Errorf("CNAME record data must be a host name")
+	}
+
+	record, err := NewCNAME(v.Name, 0, fields[0])
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *CNAME) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *CNAME) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.CNAME
+
+	return UnmarshalRecordJSON(data, v)
+}
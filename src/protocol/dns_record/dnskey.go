@@ -0,0 +1,213 @@
+package dns_record
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"strings"
+)
+
+const (
+	DNSKEYFlagZoneKey          uint16 = 0x0100
+	DNSKEYFlagRevoked          uint16 = 0x0080
+	DNSKEYFlagSecureEntryPoint uint16 = 0x0001
+	DNSKEYProtocol             uint8  = 3
+)
+
+type DNSKEY struct {
+	AbstractDnsRecord
+	flags     uint16
+	protocol  uint8
+	algorithm common.DNSSECAlgorithm
+	publicKey []byte
+}
+
+func NewDNSKEY(name string, ttl uint32, flags uint16, algorithm common.DNSSECAlgorithm, publicKey []byte) (*DNSKEY, error) {
+	abstract, err := newAbstractRecord(name, common.DNSKEY, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(publicKey) == 0 {
+		return nil, fmt.Errorf("DNSKEY public key must not be empty")
+	}
+
+	return &DNSKEY{
+		AbstractDnsRecord: abstract,
+		flags:             flags,
+		protocol:          DNSKEYProtocol,
+		algorithm:         algorithm,
+		publicKey:         publicKey,
+	}, nil
+}
+
+func (v *DNSKEY) GetFlags() uint16 {
+	return v.flags
+}
+
+func (v *DNSKEY) GetProtocol() uint8 {
+	return v.protocol
+}
+
+func (v *DNSKEY) GetAlgorithm() common.DNSSECAlgorithm {
+	return v.algorithm
+}
+
+func (v *DNSKEY) GetPublicKey() []byte {
+	return v.publicKey
+}
+
+func (v *DNSKEY) IsZoneKey() bool {
+	return v.flags&DNSKEYFlagZoneKey != 0
+}
+
+func (v *DNSKEY) IsRevoked() bool {
+	return v.flags&DNSKEYFlagRevoked != 0
+}
+
+func (v *DNSKEY) IsSecureEntryPoint() bool {
+	return v.flags&DNSKEYFlagSecureEntryPoint != 0
+}
+
+// GetRData returns record's data in the wire format
+func (v *DNSKEY) GetRData() []byte {
+	result := []byte{byte(v.flags >> 8), byte(v.flags), v.protocol, byte(v.algorithm)}
+
+	return append(result, v.publicKey...)
+}
+
+// GetKeyTag calculates the key tag used by RRSIG and DS records to identify the key (RFC 4034, appendix B)
+func (v *DNSKEY) GetKeyTag() uint16 {
+	rData := v.GetRData()
+
+	// RSA/MD5 keys use the most significant 16 bits of the least significant 24 bits of the modulus
+	if v.algorithm == common.RSAMD5 {
+		if len(rData) < 3 {
+			return 0
+		}
+
+		return uint16(rData[len(rData)-3])<<8 | uint16(rData[len(rData)-2])
+	}
+
+	acc := uint32(0)
+
+	for i, b := range rData {
+		if i&1 == 0 {
+			acc += uint32(b) << 8
+		} else {
+			acc += uint32(b)
+		}
+	}
+
+	acc += acc >> 16 & 0xFFFF
+
+	return uint16(acc & 0xFFFF)
+}
+
+func (v *DNSKEY) ReadData(buf *buffer.BytePacketBuffer) error {
+	if v.DataLength < uint16(4) {
+		return fmt.Errorf("invalid data length in DNSKEY record")
+	}
+
+	dataStart := buf.GetPos()
+
+	flags, err := buf.ReadUint16()
+	if err != nil {
+		return err
+	}
+
+	protocol, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	algorithm, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := v.readRemainingData(buf, dataStart)
+	if err != nil {
+		return err
+	}
+
+	v.flags = flags
+	v.protocol = protocol
+	v.algorithm = common.DNSSECAlgorithm(algorithm)
+	v.publicKey = publicKey
+
+	return nil
+}
+
+func (v *DNSKEY) WriteData(buf *buffer.BytePacketBuffer) error {
+	err := buf.PrependDataLength(func() error {
+		return buf.WriteBytes(v.GetRData())
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *DNSKEY) String() string {
+	r := new(strings.Builder)
+
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
+	fmt.Fprintf(r, "Flags: %d\n", v.flags)
+	fmt.Fprintf(r, "Protocol: %d\n", v.protocol)
+	fmt.Fprintf(r, "Algorithm: %d (%s)\n", v.algorithm, v.algorithm.String())
+	fmt.Fprintf(r, "Key tag: %d\n", v.GetKeyTag())
+	fmt.Fprintf(r, "Public key: %s", base64.StdEncoding.EncodeToString(v.publicKey))
+
+	return r.String()
+}
+
+func (v *DNSKEY) CompactString() string {
+	return fmt.Sprintf("DNSKEY { Domain: %s, Flags: %d, Algorithm: %s, KeyTag: %d, TTL: %d }", v.Name, v.flags, v.algorithm.String(), v.GetKeyTag(), v.TTL)
+}
+
+func (v *DNSKEY) PresentationString() string {
+	return v.presentationPreamble() + fmt.Sprintf("%d %d %d %s", v.flags, v.protocol, v.algorithm, base64.StdEncoding.EncodeToString(v.publicKey))
+}
+
+func (v *DNSKEY) ParseData(fields []string) error {
+	if len(fields) < 4 {
+		return fmt.Errorf("DNSKEY record data must consist of flags, protocol, algorithm and public key")
+	}
+
+	flags, protocol, algorithm, err := parseDNSSECFields(fields[:3], "DNSKEY flags", "DNSKEY protocol", "DNSKEY algorithm")
+	if err != nil {
+		return err
+	}
+
+	if protocol != 3 {
+		return fmt.Errorf("DNSKEY protocol must be 3, got %d", protocol)
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(strings.Join(fields[3:], ""))
+	if err != nil {
+		return fmt.Errorf("invalid DNSKEY public key: %s", err.Error())
+	}
+
+	record, err := NewDNSKEY(v.Name, 0, flags, common.DNSSECAlgorithm(algorithm), publicKey)
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *DNSKEY) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *DNSKEY) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.DNSKEY
+
+	return UnmarshalRecordJSON(data, v)
+}
@@ -0,0 +1,181 @@
+package dns_record
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"strings"
+)
+
+var digestLengths = map[common.DigestType]int{
+	common.DIGEST_SHA1:   20,
+	common.DIGEST_SHA256: 32,
+	common.DIGEST_GOST:   32,
+	common.DIGEST_SHA384: 48,
+}
+
+type DS struct {
+	AbstractDnsRecord
+	keyTag     uint16
+	algorithm  common.DNSSECAlgorithm
+	digestType common.DigestType
+	digest     []byte
+}
+
+func NewDS(name string, ttl uint32, keyTag uint16, algorithm common.DNSSECAlgorithm, digestType common.DigestType, digest []byte) (*DS, error) {
+	abstract, err := newAbstractRecord(name, common.DS, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	expectedLength, ok := digestLengths[digestType]
+	if ok && len(digest) != expectedLength {
+		return nil, fmt.Errorf("%s digest must be %d bytes long, got %d", digestType.String(), expectedLength, len(digest))
+	}
+
+	if len(digest) == 0 {
+		return nil, fmt.Errorf("DS digest must not be empty")
+	}
+
+	return &DS{
+		AbstractDnsRecord: abstract,
+		keyTag:            keyTag,
+		algorithm:         algorithm,
+		digestType:        digestType,
+		digest:            digest,
+	}, nil
+}
+
+func (v *DS) GetKeyTag() uint16 {
+	return v.keyTag
+}
+
+func (v *DS) GetAlgorithm() common.DNSSECAlgorithm {
+	return v.algorithm
+}
+
+func (v *DS) GetDigestType() common.DigestType {
+	return v.digestType
+}
+
+func (v *DS) GetDigest() []byte {
+	return v.digest
+}
+
+func (v *DS) ReadData(buf *buffer.BytePacketBuffer) error {
+	if v.DataLength < uint16(4) {
+		return fmt.Errorf("invalid data length in DS record")
+	}
+
+	dataStart := buf.GetPos()
+
+	keyTag, err := buf.ReadUint16()
+	if err != nil {
+		return err
+	}
+
+	algorithm, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	digestType, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	digest, err := v.readRemainingData(buf, dataStart)
+	if err != nil {
+		return err
+	}
+
+	v.keyTag = keyTag
+	v.algorithm = common.DNSSECAlgorithm(algorithm)
+	v.digestType = common.DigestType(digestType)
+	v.digest = digest
+
+	return nil
+}
+
+func (v *DS) WriteData(buf *buffer.BytePacketBuffer) error {
+	err := buf.PrependDataLength(func() error {
+		err := buf.WriteUint16(v.keyTag)
+		if err != nil {
+			return err
+		}
+
+		err = buf.WriteByte(byte(v.algorithm))
+		if err != nil {
+			return err
+		}
+
+		err = buf.WriteByte(byte(v.digestType))
+		if err != nil {
+			return err
+		}
+
+		return buf.WriteBytes(v.digest)
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *DS) String() string {
+	r := new(strings.Builder)
+
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
+	fmt.Fprintf(r, "Key tag: %d\n", v.keyTag)
+	fmt.Fprintf(r, "Algorithm: %d (%s)\n", v.algorithm, v.algorithm.String())
+	fmt.Fprintf(r, "Digest type: %d (%s)\n", v.digestType, v.digestType.String())
+	fmt.Fprintf(r, "Digest: %s", strings.ToUpper(hex.EncodeToString(v.digest)))
+
+	return r.String()
+}
+
+func (v *DS) CompactString() string {
+	return fmt.Sprintf("DS { Domain: %s, KeyTag: %d, Algorithm: %s, DigestType: %s, TTL: %d }", v.Name, v.keyTag, v.algorithm.String(), v.digestType.String(), v.TTL)
+}
+
+func (v *DS) PresentationString() string {
+	return v.presentationPreamble() + fmt.Sprintf("%d %d %d %s", v.keyTag, v.algorithm, v.digestType, strings.ToUpper(hex.EncodeToString(v.digest)))
+}
+
+func (v *DS) ParseData(fields []string) error {
+	if len(fields) < 4 {
+		return fmt.Errorf("DS record data must consist of key tag, algorithm, digest type and digest")
+	}
+
+	keyTag, algorithm, digestType, err := parseDNSSECFields(fields[:3], "DS key tag", "DS algorithm", "DS digest type")
+	if err != nil {
+		return err
+	}
+
+	digest, err := hex.DecodeString(strings.Join(fields[3:], ""))
+	if err != nil {
+		return fmt.Errorf("invalid DS digest: %s", err.Error())
+	}
+
+	record, err := NewDS(v.Name, 0, keyTag, common.DNSSECAlgorithm(algorithm), common.DigestType(digestType), digest)
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *DS) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *DS) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.DS
+
+	return UnmarshalRecordJSON(data, v)
+}
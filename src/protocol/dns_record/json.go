@@ -0,0 +1,209 @@
+package dns_record
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/json_object"
+	"strings"
+)
+
+/*
+ * Records are represented in JSON following RFC 8427: they carry their data both in the wire format ("RDATAHEX",
+ * which is what's read back, so that nothing is lost) and, for types known to dns-go, in the presentation format
+ * ("rdata" followed by the type mnemonic, e.g. "rdataA"). Record types implement json.Marshaler and json.Unmarshaler
+ * with MarshalRecordJSON and UnmarshalRecordJSON; the methods of AbstractDnsRecord handle the generic form.
+ */
+
+// jsonRecord is a record written as JSON
+type jsonRecord interface {
+	GetName() string
+	GetType() common.QueryType
+	GetClass() common.Class
+	GetTTL() uint32
+	WriteData(buf *buffer.BytePacketBuffer) error
+	PresentationString() string
+}
+
+// jsonDataReader is a record read from JSON, whose preamble is set before its data is read
+type jsonDataReader interface {
+	ReadData(buf *buffer.BytePacketBuffer) error
+	abstract() *AbstractDnsRecord
+}
+
+// MarshalRecordJSON returns the record as RFC 8427 JSON object
+func MarshalRecordJSON(record jsonRecord) ([]byte, error) {
+	object, err := recordJSONObject(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.MarshalJSON()
+}
+
+// recordJSONObject returns members of RFC 8427 JSON object describing the record
+func recordJSONObject(record jsonRecord) (json_object.Object, error) {
+	rData, err := recordRData(record)
+	if err != nil {
+		return nil, err
+	}
+
+	qType := record.GetType()
+	class := record.GetClass()
+
+	result := json_object.Object{}.
+		Add("NAME", record.GetName()).
+		Add("TYPE", uint16(qType)).
+		Add("TYPEname", qType.String()).
+		Add("CLASS", uint16(class))
+
+	// CLASS of OPT record is the requestor's payload size, which has no mnemonic
+	if class == common.IN {
+		result = result.Add("CLASSname", class.String())
+	}
+
+	result = result.
+		Add("TTL", record.GetTTL()).
+		Add("RDLENGTH", len(rData)).
+		Add("RDATAHEX", strings.ToUpper(hex.EncodeToString(rData)))
+
+	// data in the generic form (records of unknown types, OPT) tells nothing more than RDATAHEX
+	if _, unknown := record.(*AbstractDnsRecord); !unknown && qType.IsKnown() {
+		if presentation := rDataPresentation(record); !strings.HasPrefix(presentation, `\#`) {
+			result = result.Add("rdata"+qType.String(), presentation)
+		}
+	}
+
+	return result, nil
+}
+
+// UnmarshalRecordJSON reads RFC 8427 JSON object into the record. If the record's type is already set, the object has
+// to describe a record of that type.
+func UnmarshalRecordJSON(data []byte, record jsonDataReader) error {
+	abstract, rData, err := ReadRecordJSON(data)
+	if err != nil {
+		return err
+	}
+
+	target := record.abstract()
+	if target.QueryType != 0 && target.QueryType != abstract.QueryType {
+		return fmt.Errorf("expected %s record, got %s", target.QueryType.String(), abstract.QueryType.String())
+	}
+
+	*target = abstract
+
+	buf := buffer.BytePacketBufferFromRawBuffer(rData)
+
+	err = record.ReadData(buf)
+	if err != nil {
+		return err
+	}
+
+	if buf.GetPos() != uint(len(rData)) {
+		return fmt.Errorf("invalid data length in %s record", abstract.QueryType.String())
+	}
+
+	return nil
+}
+
+// ReadRecordJSON reads the preamble of the record described by RFC 8427 JSON object, and its data in the wire
+// format (RDATAHEX), so that the record can be created by its type
+func ReadRecordJSON(data []byte) (AbstractDnsRecord, []byte, error) {
+	result := NewAbstractRecord()
+
+	var members json_object.Members
+
+	err := json.Unmarshal(data, &members)
+	if err != nil {
+		return result, nil, err
+	}
+
+	name, err := members.String("NAME")
+	if err != nil {
+		return result, nil, err
+	}
+
+	qType, err := members.QueryType("TYPE")
+	if err != nil {
+		return result, nil, err
+	}
+
+	class, err := members.Class("CLASS")
+	if err != nil {
+		return result, nil, err
+	}
+
+	ttl, err := members.Uint("TTL", 32)
+	if err != nil {
+		return result, nil, err
+	}
+
+	rDataHex, err := members.String("RDATAHEX")
+	if err != nil {
+		return result, nil, err
+	}
+
+	rData, err := hex.DecodeString(rDataHex)
+	if err != nil {
+		return result, nil, fmt.Errorf("invalid RDATAHEX member: %s", err.Error())
+	}
+
+	if len(rData) > buffer.MaxDNSBufferSize {
+		return result, nil, fmt.Errorf("record data is too long (%d bytes)", len(rData))
+	}
+
+	if members.Has("RDLENGTH") {
+		rdLength, err := members.Uint("RDLENGTH", 16)
+		if err != nil {
+			return result, nil, err
+		}
+
+		if int(rdLength) != len(rData) {
+			return result, nil, fmt.Errorf("RDLENGTH is %d, but RDATAHEX has %d bytes", rdLength, len(rData))
+		}
+	}
+
+	result.Name = strings.TrimSuffix(name, ".")
+	result.QueryType = qType
+	result.Class = class
+	result.TTL = uint32(ttl)
+	result.DataLength = uint16(len(rData))
+
+	return result, rData, nil
+}
+
+// recordRData returns record's data in the wire format. Names are never compressed when serializing,
+// so the data doesn't depend on the rest of the message.
+func recordRData(record dataWriter) ([]byte, error) {
+	buf := buffer.NewBytePacketBufferWithSize(buffer.MaxDNSBufferSize)
+
+	err := record.WriteData(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// data is preceded by its length
+	return buf.GetBytes()[2:], nil
+}
+
+// rDataPresentation returns record's data in the presentation format, which follows the owner, TTL, class and type
+func rDataPresentation(record jsonRecord) string {
+	fields := strings.SplitN(record.PresentationString(), "\t", 5)
+
+	return fields[len(fields)-1]
+}
+
+func (v *AbstractDnsRecord) abstract() *AbstractDnsRecord {
+	return v
+}
+
+// MarshalJSON writes the record in the generic form, which is what records of types not known to dns-go are kept in
+func (v *AbstractDnsRecord) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *AbstractDnsRecord) UnmarshalJSON(data []byte) error {
+	return UnmarshalRecordJSON(data, v)
+}
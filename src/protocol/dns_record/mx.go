@@ -3,6 +3,7 @@ package dns_record
 import (
 	"fmt"
 	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
 	"strings"
 )
 
@@ -12,6 +13,28 @@ type MX struct {
 	host     string
 }
 
+func NewMX(name string, ttl uint32, priority uint16, host string) (*MX, error) {
+	abstract, err := newAbstractRecord(name, common.MX, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err = validateHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MX{AbstractDnsRecord: abstract, priority: priority, host: host}, nil
+}
+
+func (v *MX) GetPriority() uint16 {
+	return v.priority
+}
+
+func (v *MX) GetHost() string {
+	return v.host
+}
+
 func (v *MX) ReadData(buf *buffer.BytePacketBuffer) error {
 	priority, err := buf.ReadUint16()
 	if err != nil {
@@ -53,7 +76,7 @@ func (v *MX) WriteData(buf *buffer.BytePacketBuffer) error {
 func (v *MX) String() string {
 	r := new(strings.Builder)
 
-	fmt.Fprintf(r, v.AbstractDnsRecord.String())
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
 	fmt.Fprintf(r, "Priority: %d\n", v.priority)
 	fmt.Fprintf(r, "Host: %s", v.host)
 
@@ -63,3 +86,38 @@ func (v *MX) String() string {
 func (v *MX) CompactString() string {
 	return fmt.Sprintf("MX { Domain: %s, Priority: %d, Host: %s, TTL: %d }", v.Name, v.priority, v.host, v.TTL)
 }
+
+func (v *MX) PresentationString() string {
+	return v.presentationPreamble() + fmt.Sprintf("%d %s", v.priority, fqdn(v.host))
+}
+
+func (v *MX) ParseData(fields []string) error {
+	if len(fields) != 2 {
+		return fmt.Errorf("MX record data must consist of preference and host name")
+	}
+
+	priority, err := parseUintField(fields[0], 16, "MX preference")
+	if err != nil {
+		return err
+	}
+
+	record, err := NewMX(v.Name, 0, uint16(priority), fields[1])
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *MX) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *MX) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.MX
+
+	return UnmarshalRecordJSON(data, v)
+}
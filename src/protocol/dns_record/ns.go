@@ -3,6 +3,7 @@ package dns_record
 import (
 	"fmt"
 	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
 	"strings"
 )
 
@@ -15,6 +16,20 @@ func (v *NS) GetHost() string {
 	return v.host
 }
 
+func NewNS(name string, ttl uint32, host string) (*NS, error) {
+	abstract, err := newAbstractRecord(name, common.NS, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err = validateHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NS{AbstractDnsRecord: abstract, host: host}, nil
+}
+
 func (v *NS) ReadData(buf *buffer.BytePacketBuffer) error {
 	host, err := buf.ReadLabel()
 	if err != nil {
@@ -40,7 +55,7 @@ func (v *NS) WriteData(buf *buffer.BytePacketBuffer) error {
 func (v *NS) String() string {
 	r := new(strings.Builder)
 
-	fmt.Fprintf(r, v.AbstractDnsRecord.String())
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
 	fmt.Fprintf(r, "Host: %s", v.host)
 
 	return r.String()
@@ -49,3 +64,33 @@ func (v *NS) String() string {
 func (v *NS) CompactString() string {
 	return fmt.Sprintf("NS { Domain: %s, Host: %s, TTL: %d }", v.Name, v.host, v.TTL)
 }
+
+func (v *NS) PresentationString() string {
+	return v.presentationPreamble() + fqdn(v.host)
+}
+
+func (v *NS) ParseData(fields []string) error {
+	if len(fields) != 1 {
+		return fmt.Errorf("NS record data must be a host name")
+	}
+
+	record, err := NewNS(v.Name, 0, fields[0])
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *NS) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *NS) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.NS
+
+	return UnmarshalRecordJSON(data, v)
+}
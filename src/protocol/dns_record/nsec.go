@@ -0,0 +1,129 @@
+package dns_record
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"strings"
+)
+
+type NSEC struct {
+	AbstractDnsRecord
+	nextDomain string
+	types      []common.QueryType
+}
+
+func NewNSEC(name string, ttl uint32, nextDomain string, types []common.QueryType) (*NSEC, error) {
+	abstract, err := newAbstractRecord(name, common.NSEC, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	nextDomain, err = validateHost(nextDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NSEC{AbstractDnsRecord: abstract, nextDomain: nextDomain, types: types}, nil
+}
+
+func (v *NSEC) GetNextDomain() string {
+	return v.nextDomain
+}
+
+func (v *NSEC) GetTypes() []common.QueryType {
+	return v.types
+}
+
+func (v *NSEC) HasType(qType common.QueryType) bool {
+	return hasType(v.types, qType)
+}
+
+func (v *NSEC) ReadData(buf *buffer.BytePacketBuffer) error {
+	dataStart := buf.GetPos()
+
+	nextDomain, err := buf.ReadLabel()
+	if err != nil {
+		return err
+	}
+
+	bitmap, err := v.readRemainingData(buf, dataStart)
+	if err != nil {
+		return err
+	}
+
+	types, err := decodeTypeBitmap(bitmap)
+	if err != nil {
+		return err
+	}
+
+	v.nextDomain = nextDomain
+	v.types = types
+
+	return nil
+}
+
+func (v *NSEC) WriteData(buf *buffer.BytePacketBuffer) error {
+	err := buf.PrependDataLength(func() error {
+		err := buf.WriteLabel(v.nextDomain)
+		if err != nil {
+			return err
+		}
+
+		return buf.WriteBytes(encodeTypeBitmap(v.types))
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *NSEC) String() string {
+	r := new(strings.Builder)
+
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
+	fmt.Fprintf(r, "Next domain: %s\n", v.nextDomain)
+	fmt.Fprintf(r, "Types: %s", typesPresentation(v.types))
+
+	return r.String()
+}
+
+func (v *NSEC) CompactString() string {
+	return fmt.Sprintf("NSEC { Domain: %s, NextDomain: %s, Types: [%s], TTL: %d }", v.Name, v.nextDomain, typesPresentation(v.types), v.TTL)
+}
+
+func (v *NSEC) PresentationString() string {
+	return v.presentationPreamble() + fmt.Sprintf("%s %s", fqdn(v.nextDomain), typesPresentation(v.types))
+}
+
+func (v *NSEC) ParseData(fields []string) error {
+	if len(fields) < 1 {
+		return fmt.Errorf("NSEC record data must consist of next domain name and types")
+	}
+
+	types, err := parseTypes(fields[1:])
+	if err != nil {
+		return err
+	}
+
+	record, err := NewNSEC(v.Name, 0, fields[0], types)
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *NSEC) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *NSEC) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.NSEC
+
+	return UnmarshalRecordJSON(data, v)
+}
@@ -0,0 +1,281 @@
+package dns_record
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"strings"
+)
+
+const NSEC3FlagOptOut uint8 = 0x01
+
+// base32hex without padding, as used for hashed owner names (RFC 5155, section 3.3)
+var nsec3HashEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+type NSEC3 struct {
+	AbstractDnsRecord
+	hashAlgorithm   common.NSEC3HashAlgorithm
+	flags           uint8
+	iterations      uint16
+	salt            []byte
+	nextHashedOwner []byte
+	types           []common.QueryType
+}
+
+func NewNSEC3(
+	name string, ttl uint32, hashAlgorithm common.NSEC3HashAlgorithm, flags uint8, iterations uint16,
+	salt []byte, nextHashedOwner []byte, types []common.QueryType,
+) (*NSEC3, error) {
+	abstract, err := newAbstractRecord(name, common.NSEC3, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(salt) > 255 {
+		return nil, fmt.Errorf("NSEC3 salt is too long (%d bytes)", len(salt))
+	}
+
+	if len(nextHashedOwner) == 0 || len(nextHashedOwner) > 255 {
+		return nil, fmt.Errorf("invalid NSEC3 next hashed owner length (%d bytes)", len(nextHashedOwner))
+	}
+
+	return &NSEC3{
+		AbstractDnsRecord: abstract,
+		hashAlgorithm:     hashAlgorithm,
+		flags:             flags,
+		iterations:        iterations,
+		salt:              salt,
+		nextHashedOwner:   nextHashedOwner,
+		types:             types,
+	}, nil
+}
+
+func (v *NSEC3) GetHashAlgorithm() common.NSEC3HashAlgorithm {
+	return v.hashAlgorithm
+}
+
+func (v *NSEC3) GetFlags() uint8 {
+	return v.flags
+}
+
+func (v *NSEC3) IsOptOut() bool {
+	return v.flags&NSEC3FlagOptOut != 0
+}
+
+func (v *NSEC3) GetIterations() uint16 {
+	return v.iterations
+}
+
+func (v *NSEC3) GetSalt() []byte {
+	return v.salt
+}
+
+func (v *NSEC3) GetNextHashedOwner() []byte {
+	return v.nextHashedOwner
+}
+
+func (v *NSEC3) GetTypes() []common.QueryType {
+	return v.types
+}
+
+func (v *NSEC3) HasType(qType common.QueryType) bool {
+	return hasType(v.types, qType)
+}
+
+func (v *NSEC3) ReadData(buf *buffer.BytePacketBuffer) error {
+	dataStart := buf.GetPos()
+
+	hashAlgorithm, flags, iterations, salt, err := readNSEC3Params(buf)
+	if err != nil {
+		return err
+	}
+
+	hashLength, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	nextHashedOwner, err := buf.ReadBytes(uint(hashLength))
+	if err != nil {
+		return err
+	}
+
+	bitmap, err := v.readRemainingData(buf, dataStart)
+	if err != nil {
+		return err
+	}
+
+	types, err := decodeTypeBitmap(bitmap)
+	if err != nil {
+		return err
+	}
+
+	v.hashAlgorithm = hashAlgorithm
+	v.flags = flags
+	v.iterations = iterations
+	v.salt = salt
+	v.nextHashedOwner = nextHashedOwner
+	v.types = types
+
+	return nil
+}
+
+func (v *NSEC3) WriteData(buf *buffer.BytePacketBuffer) error {
+	err := buf.PrependDataLength(func() error {
+		err := writeNSEC3Params(buf, v.hashAlgorithm, v.flags, v.iterations, v.salt)
+		if err != nil {
+			return err
+		}
+
+		err = buf.WriteByte(byte(len(v.nextHashedOwner)))
+		if err != nil {
+			return err
+		}
+
+		err = buf.WriteBytes(v.nextHashedOwner)
+		if err != nil {
+			return err
+		}
+
+		return buf.WriteBytes(encodeTypeBitmap(v.types))
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *NSEC3) String() string {
+	r := new(strings.Builder)
+
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
+	fmt.Fprintf(r, "Hash algorithm: %d (%s)\n", v.hashAlgorithm, v.hashAlgorithm.String())
+	fmt.Fprintf(r, "Flags: %d\n", v.flags)
+	fmt.Fprintf(r, "Iterations: %d\n", v.iterations)
+	fmt.Fprintf(r, "Salt: %s\n", saltPresentation(v.salt))
+	fmt.Fprintf(r, "Next hashed owner: %s\n", strings.ToLower(nsec3HashEncoding.EncodeToString(v.nextHashedOwner)))
+	fmt.Fprintf(r, "Types: %s", typesPresentation(v.types))
+
+	return r.String()
+}
+
+func (v *NSEC3) CompactString() string {
+	return fmt.Sprintf("NSEC3 { Domain: %s, NextHashedOwner: %s, Types: [%s], TTL: %d }", v.Name, strings.ToLower(nsec3HashEncoding.EncodeToString(v.nextHashedOwner)), typesPresentation(v.types), v.TTL)
+}
+
+func (v *NSEC3) PresentationString() string {
+	return v.presentationPreamble() + fmt.Sprintf(
+		"%d %d %d %s %s %s",
+		v.hashAlgorithm, v.flags, v.iterations, saltPresentation(v.salt),
+		strings.ToLower(nsec3HashEncoding.EncodeToString(v.nextHashedOwner)), typesPresentation(v.types),
+	)
+}
+
+// readNSEC3Params reads the fields shared by NSEC3 and NSEC3PARAM records
+func readNSEC3Params(buf *buffer.BytePacketBuffer) (common.NSEC3HashAlgorithm, uint8, uint16, []byte, error) {
+	hashAlgorithm, err := buf.ReadByte()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	flags, err := buf.ReadByte()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	iterations, err := buf.ReadUint16()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	saltLength, err := buf.ReadByte()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	salt, err := buf.ReadBytes(uint(saltLength))
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	return common.NSEC3HashAlgorithm(hashAlgorithm), flags, iterations, salt, nil
+}
+
+// writeNSEC3Params writes the fields shared by NSEC3 and NSEC3PARAM records
+func writeNSEC3Params(buf *buffer.BytePacketBuffer, hashAlgorithm common.NSEC3HashAlgorithm, flags uint8, iterations uint16, salt []byte) error {
+	err := buf.WriteByte(byte(hashAlgorithm))
+	if err != nil {
+		return err
+	}
+
+	err = buf.WriteByte(flags)
+	if err != nil {
+		return err
+	}
+
+	err = buf.WriteUint16(iterations)
+	if err != nil {
+		return err
+	}
+
+	err = buf.WriteByte(byte(len(salt)))
+	if err != nil {
+		return err
+	}
+
+	return buf.WriteBytes(salt)
+}
+
+// saltPresentation returns salt as a hex string or "-" if it's empty (RFC 5155, section 3.3)
+func saltPresentation(salt []byte) string {
+	if len(salt) == 0 {
+		return "-"
+	}
+
+	return strings.ToUpper(hex.EncodeToString(salt))
+}
+
+func (v *NSEC3) ParseData(fields []string) error {
+	if len(fields) < 5 {
+		return fmt.Errorf("NSEC3 record data must consist of hash algorithm, flags, iterations, salt, " +
+			"next hashed owner name and types")
+	}
+
+	hashAlgorithm, flags, iterations, salt, err := parseNSEC3Params(fields[:4])
+	if err != nil {
+		return err
+	}
+
+	nextHashedOwner, err := nsec3HashEncoding.DecodeString(strings.ToUpper(fields[4]))
+	if err != nil {
+		return fmt.Errorf("invalid NSEC3 next hashed owner name: %s", err.Error())
+	}
+
+	types, err := parseTypes(fields[5:])
+	if err != nil {
+		return err
+	}
+
+	record, err := NewNSEC3(v.Name, 0, hashAlgorithm, flags, iterations, salt, nextHashedOwner, types)
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *NSEC3) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *NSEC3) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.NSEC3
+
+	return UnmarshalRecordJSON(data, v)
+}
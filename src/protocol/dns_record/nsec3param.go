@@ -0,0 +1,133 @@
+package dns_record
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"strings"
+)
+
+type NSEC3PARAM struct {
+	AbstractDnsRecord
+	hashAlgorithm common.NSEC3HashAlgorithm
+	flags         uint8
+	iterations    uint16
+	salt          []byte
+}
+
+func NewNSEC3PARAM(name string, ttl uint32, hashAlgorithm common.NSEC3HashAlgorithm, flags uint8, iterations uint16, salt []byte) (*NSEC3PARAM, error) {
+	abstract, err := newAbstractRecord(name, common.NSEC3PARAM, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(salt) > 255 {
+		return nil, fmt.Errorf("NSEC3PARAM salt is too long (%d bytes)", len(salt))
+	}
+
+	return &NSEC3PARAM{
+		AbstractDnsRecord: abstract,
+		hashAlgorithm:     hashAlgorithm,
+		flags:             flags,
+		iterations:        iterations,
+		salt:              salt,
+	}, nil
+}
+
+func (v *NSEC3PARAM) GetHashAlgorithm() common.NSEC3HashAlgorithm {
+	return v.hashAlgorithm
+}
+
+func (v *NSEC3PARAM) GetFlags() uint8 {
+	return v.flags
+}
+
+func (v *NSEC3PARAM) GetIterations() uint16 {
+	return v.iterations
+}
+
+func (v *NSEC3PARAM) GetSalt() []byte {
+	return v.salt
+}
+
+func (v *NSEC3PARAM) ReadData(buf *buffer.BytePacketBuffer) error {
+	dataStart := buf.GetPos()
+
+	hashAlgorithm, flags, iterations, salt, err := readNSEC3Params(buf)
+	if err != nil {
+		return err
+	}
+
+	if buf.GetPos()-dataStart != uint(v.DataLength) {
+		return fmt.Errorf("invalid data length in NSEC3PARAM record")
+	}
+
+	v.hashAlgorithm = hashAlgorithm
+	v.flags = flags
+	v.iterations = iterations
+	v.salt = salt
+
+	return nil
+}
+
+func (v *NSEC3PARAM) WriteData(buf *buffer.BytePacketBuffer) error {
+	err := buf.PrependDataLength(func() error {
+		return writeNSEC3Params(buf, v.hashAlgorithm, v.flags, v.iterations, v.salt)
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *NSEC3PARAM) String() string {
+	r := new(strings.Builder)
+
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
+	fmt.Fprintf(r, "Hash algorithm: %d (%s)\n", v.hashAlgorithm, v.hashAlgorithm.String())
+	fmt.Fprintf(r, "Flags: %d\n", v.flags)
+	fmt.Fprintf(r, "Iterations: %d\n", v.iterations)
+	fmt.Fprintf(r, "Salt: %s", saltPresentation(v.salt))
+
+	return r.String()
+}
+
+func (v *NSEC3PARAM) CompactString() string {
+	return fmt.Sprintf("NSEC3PARAM { Domain: %s, Iterations: %d, Salt: %s, TTL: %d }", v.Name, v.iterations, saltPresentation(v.salt), v.TTL)
+}
+
+func (v *NSEC3PARAM) PresentationString() string {
+	return v.presentationPreamble() + fmt.Sprintf("%d %d %d %s", v.hashAlgorithm, v.flags, v.iterations, saltPresentation(v.salt))
+}
+
+func (v *NSEC3PARAM) ParseData(fields []string) error {
+	if len(fields) != 4 {
+		return fmt.Errorf("NSEC3PARAM record data must consist of hash algorithm, flags, iterations and salt")
+	}
+
+	hashAlgorithm, flags, iterations, salt, err := parseNSEC3Params(fields)
+	if err != nil {
+		return err
+	}
+
+	record, err := NewNSEC3PARAM(v.Name, 0, hashAlgorithm, flags, iterations, salt)
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *NSEC3PARAM) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *NSEC3PARAM) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.NSEC3PARAM
+
+	return UnmarshalRecordJSON(data, v)
+}
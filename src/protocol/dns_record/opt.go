@@ -0,0 +1,160 @@
+package dns_record
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"strings"
+)
+
+/*
+ * OPT is a pseudo-record carrying EDNS information (RFC 6891). It reuses the record fields in a special way:
+ * - CLASS holds the requestor's UDP payload size
+ * - TTL holds the extended RCODE (8 bits), EDNS version (8 bits) and flags (16 bits, with DO being the top one)
+ */
+
+const (
+	EDNSVersion      uint8  = 0
+	EDNSFlagDNSSECOk uint32 = 0x8000
+)
+
+type EDNSOption struct {
+	Code uint16
+	Data []byte
+}
+
+type OPT struct {
+	AbstractDnsRecord
+	options []EDNSOption
+}
+
+func NewOPT(udpPayloadSize uint16, dnssecOk bool) *OPT {
+	result := &OPT{AbstractDnsRecord: NewAbstractRecord()}
+	result.QueryType = common.OPT
+	result.Class = common.Class(udpPayloadSize)
+
+	if dnssecOk {
+		result.TTL |= EDNSFlagDNSSECOk
+	}
+
+	return result
+}
+
+func (v *OPT) GetUDPPayloadSize() uint16 {
+	return uint16(v.Class)
+}
+
+func (v *OPT) GetExtendedRCode() uint8 {
+	return uint8(v.TTL >> 24)
+}
+
+func (v *OPT) GetVersion() uint8 {
+	return uint8(v.TTL >> 16)
+}
+
+func (v *OPT) IsDNSSECOk() bool {
+	return v.TTL&EDNSFlagDNSSECOk != 0
+}
+
+func (v *OPT) GetOptions() []EDNSOption {
+	return v.options
+}
+
+func (v *OPT) ReadData(buf *buffer.BytePacketBuffer) error {
+	dataStart := buf.GetPos()
+	options := make([]EDNSOption, 0)
+
+	for buf.GetPos()-dataStart < uint(v.DataLength) {
+		code, err := buf.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		length, err := buf.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		data, err := buf.ReadBytes(uint(length))
+		if err != nil {
+			return err
+		}
+
+		options = append(options, EDNSOption{Code: code, Data: data})
+	}
+
+	if buf.GetPos()-dataStart != uint(v.DataLength) {
+		return fmt.Errorf("invalid data length in OPT record")
+	}
+
+	v.options = options
+
+	return nil
+}
+
+func (v *OPT) WriteData(buf *buffer.BytePacketBuffer) error {
+	err := buf.PrependDataLength(func() error {
+		for _, option := range v.options {
+			err := buf.WriteUint16(option.Code)
+			if err != nil {
+				return err
+			}
+
+			err = buf.WriteUint16(uint16(len(option.Data)))
+			if err != nil {
+				return err
+			}
+
+			err = buf.WriteBytes(option.Data)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *OPT) String() string {
+	r := new(strings.Builder)
+
+	fmt.Fprintf(r, "Name: %s\n", v.Name)
+	fmt.Fprintf(r, "Type: %d (%s)\n", v.QueryType, v.QueryType.String())
+	fmt.Fprintf(r, "UDP payload size: %d\n", v.GetUDPPayloadSize())
+	fmt.Fprintf(r, "Extended RCODE: %d\n", v.GetExtendedRCode())
+	fmt.Fprintf(r, "EDNS version: %d\n", v.GetVersion())
+	fmt.Fprintf(r, "DNSSEC OK: %t\n", v.IsDNSSECOk())
+	fmt.Fprintf(r, "Options: %s", v.optionsPresentation())
+
+	return r.String()
+}
+
+func (v *OPT) CompactString() string {
+	return fmt.Sprintf("OPT { UDPPayloadSize: %d, Version: %d, DO: %t, Options: [%s] }", v.GetUDPPayloadSize(), v.GetVersion(), v.IsDNSSECOk(), v.optionsPresentation())
+}
+
+func (v *OPT) optionsPresentation() string {
+	options := make([]string, 0, len(v.options))
+
+	for _, option := range v.options {
+		options = append(options, fmt.Sprintf("%d:%s", option.Code, hex.EncodeToString(option.Data)))
+	}
+
+	return strings.Join(options, " ")
+}
+
+func (v *OPT) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *OPT) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.OPT
+
+	return UnmarshalRecordJSON(data, v)
+}
@@ -0,0 +1,80 @@
+package dns_record
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"strconv"
+	"strings"
+)
+
+// fqdn returns name in the fully qualified form used by the presentation format ("example.com.")
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+
+	return name + "."
+}
+
+// classMnemonic returns the mnemonic of the given class or its generic "CLASSnnn" form (RFC 3597, section 5) if it's unknown
+func classMnemonic(class common.Class) string {
+	mnemonic := class.String()
+
+	if mnemonic == "UNKNOWN" {
+		return fmt.Sprintf("CLASS%d", class)
+	}
+
+	return mnemonic
+}
+
+// presentationPreamble returns owner, TTL, class and type of the record in the presentation format (RFC 1035, section 5.1)
+func (v *AbstractDnsRecord) presentationPreamble() string {
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t", fqdn(v.Name), v.TTL, classMnemonic(v.Class), v.QueryType.String())
+}
+
+// genericRDataPresentation returns data in the "\# <length> <hex>" form (RFC 3597, section 5)
+func genericRDataPresentation(data []byte) string {
+	if len(data) == 0 {
+		return "\\# 0"
+	}
+
+	return fmt.Sprintf("\\# %d %s", len(data), strings.ToUpper(hex.EncodeToString(data)))
+}
+
+// ParseGenericRData parses data in the "\# <length> <hex>" form (RFC 3597, section 5), where hex may be split into multiple words
+func ParseGenericRData(value string) ([]byte, error) {
+	fields := strings.Fields(value)
+
+	if len(fields) < 2 || fields[0] != "\\#" {
+		return nil, fmt.Errorf("generic record data must start with \\# and the data length")
+	}
+
+	length, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generic record data length: %s", err.Error())
+	}
+
+	data, err := hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid generic record data: %s", err.Error())
+	}
+
+	if len(data) != int(length) {
+		return nil, fmt.Errorf("generic record data length mismatch (declared %d, got %d bytes)", length, len(data))
+	}
+
+	return data, nil
+}
+
+func nameToWire(name string) ([]byte, error) {
+	buf := buffer.NewBytePacketBufferWithSize(buffer.MaxDNSBufferSize)
+
+	err := buf.WriteLabel(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.GetBytes(), nil
+}
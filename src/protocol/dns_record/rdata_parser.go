@@ -0,0 +1,115 @@
+package dns_record
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DataParser is implemented by records whose data can be given in the presentation format of their type, as written
+// by their PresentationString methods. ParseData is called on a record with its preamble already set; the data is
+// validated by the constructor of the type, so the preamble of the record it creates is discarded.
+type DataParser interface {
+	ParseData(fields []string) error
+}
+
+func parseIP(value string) (net.IP, error) {
+	result := net.ParseIP(value)
+	if result == nil {
+		return nil, fmt.Errorf("invalid IP address %q", value)
+	}
+
+	return result, nil
+}
+
+func parseUintField(value string, bits int, what string) (uint64, error) {
+	result, err := strconv.ParseUint(value, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", what, value)
+	}
+
+	return result, nil
+}
+
+// parseDNSSECFields parses the 16-bit and two 8-bit fields which start data of DS and DNSKEY records
+func parseDNSSECFields(fields []string, first string, second string, third string) (uint16, uint8, uint8, error) {
+	firstValue, err := parseUintField(fields[0], 16, first)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	secondValue, err := parseUintField(fields[1], 8, second)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	thirdValue, err := parseUintField(fields[2], 8, third)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return uint16(firstValue), uint8(secondValue), uint8(thirdValue), nil
+}
+
+// parseRRSIGTime parses signature expiration/inception given either as YYYYMMDDHHmmSS in UTC or the number of seconds
+// since the epoch (RFC 4034, section 3.2)
+func parseRRSIGTime(value string) (uint32, error) {
+	if len(value) == len(rrsigTimeFormat) {
+		timestamp, err := time.Parse(rrsigTimeFormat, value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid RRSIG time %q", value)
+		}
+
+		return uint32(timestamp.Unix()), nil
+	}
+
+	result, err := parseUintField(value, 32, "RRSIG time")
+
+	return uint32(result), err
+}
+
+// parseNSEC3Params parses the fields shared by NSEC3 and NSEC3PARAM records, with "-" standing for the empty salt
+func parseNSEC3Params(fields []string) (common.NSEC3HashAlgorithm, uint8, uint16, []byte, error) {
+	hashAlgorithm, err := parseUintField(fields[0], 8, "NSEC3 hash algorithm")
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	flags, err := parseUintField(fields[1], 8, "NSEC3 flags")
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	iterations, err := parseUintField(fields[2], 16, "NSEC3 iterations")
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	salt := make([]byte, 0)
+	if fields[3] != "-" {
+		salt, err = hex.DecodeString(fields[3])
+		if err != nil {
+			return 0, 0, 0, nil, fmt.Errorf("invalid NSEC3 salt: %s", err.Error())
+		}
+	}
+
+	return common.NSEC3HashAlgorithm(hashAlgorithm), uint8(flags), uint16(iterations), salt, nil
+}
+
+func parseTypes(mnemonics []string) ([]common.QueryType, error) {
+	result := make([]common.QueryType, 0, len(mnemonics))
+
+	for _, mnemonic := range mnemonics {
+		qType, err := common.ParseQueryType(mnemonic)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, qType)
+	}
+
+	return result, nil
+}
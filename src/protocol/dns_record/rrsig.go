@@ -0,0 +1,318 @@
+package dns_record
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"strings"
+	"time"
+)
+
+const rrsigTimeFormat = "20060102150405"
+
+type RRSIG struct {
+	AbstractDnsRecord
+	typeCovered common.QueryType
+	algorithm   common.DNSSECAlgorithm
+	labels      uint8
+	originalTTL uint32
+	expiration  uint32
+	inception   uint32
+	keyTag      uint16
+	signerName  string
+	signature   []byte
+}
+
+func NewRRSIG(
+	name string, ttl uint32, typeCovered common.QueryType, algorithm common.DNSSECAlgorithm, labels uint8,
+	originalTTL uint32, expiration uint32, inception uint32, keyTag uint16, signerName string, signature []byte,
+) (*RRSIG, error) {
+	abstract, err := newAbstractRecord(name, common.RRSIG, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	signerName, err = validateHost(signerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if labelCount(abstract.Name) < int(labels) {
+		return nil, fmt.Errorf("RRSIG labels count (%d) exceeds the number of labels of %s", labels, abstract.Name)
+	}
+
+	return &RRSIG{
+		AbstractDnsRecord: abstract,
+		typeCovered:       typeCovered,
+		algorithm:         algorithm,
+		labels:            labels,
+		originalTTL:       originalTTL,
+		expiration:        expiration,
+		inception:         inception,
+		keyTag:            keyTag,
+		signerName:        signerName,
+		signature:         signature,
+	}, nil
+}
+
+func (v *RRSIG) GetTypeCovered() common.QueryType {
+	return v.typeCovered
+}
+
+func (v *RRSIG) GetAlgorithm() common.DNSSECAlgorithm {
+	return v.algorithm
+}
+
+func (v *RRSIG) GetLabels() uint8 {
+	return v.labels
+}
+
+func (v *RRSIG) GetOriginalTTL() uint32 {
+	return v.originalTTL
+}
+
+func (v *RRSIG) GetExpiration() uint32 {
+	return v.expiration
+}
+
+func (v *RRSIG) GetInception() uint32 {
+	return v.inception
+}
+
+func (v *RRSIG) GetKeyTag() uint16 {
+	return v.keyTag
+}
+
+func (v *RRSIG) GetSignerName() string {
+	return v.signerName
+}
+
+func (v *RRSIG) GetSignature() []byte {
+	return v.signature
+}
+
+func (v *RRSIG) ReadData(buf *buffer.BytePacketBuffer) error {
+	dataStart := buf.GetPos()
+
+	typeCovered, err := buf.ReadUint16()
+	if err != nil {
+		return err
+	}
+
+	algorithm, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	labels, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	originalTTL, err := buf.ReadUint32()
+	if err != nil {
+		return err
+	}
+
+	expiration, err := buf.ReadUint32()
+	if err != nil {
+		return err
+	}
+
+	inception, err := buf.ReadUint32()
+	if err != nil {
+		return err
+	}
+
+	keyTag, err := buf.ReadUint16()
+	if err != nil {
+		return err
+	}
+
+	signerName, err := buf.ReadLabel()
+	if err != nil {
+		return err
+	}
+
+	signature, err := v.readRemainingData(buf, dataStart)
+	if err != nil {
+		return err
+	}
+
+	v.typeCovered = common.QueryType(typeCovered)
+	v.algorithm = common.DNSSECAlgorithm(algorithm)
+	v.labels = labels
+	v.originalTTL = originalTTL
+	v.expiration = expiration
+	v.inception = inception
+	v.keyTag = keyTag
+	v.signerName = signerName
+	v.signature = signature
+
+	return nil
+}
+
+// WriteSignedData writes all fields of the record except the signature in the canonical form,
+// which is the prefix of data being signed (RFC 4034, section 3.1.8.1)
+func (v *RRSIG) WriteSignedData(buf *buffer.BytePacketBuffer) error {
+	return v.writeFields(buf, strings.ToLower(v.signerName))
+}
+
+func (v *RRSIG) writeFields(buf *buffer.BytePacketBuffer, signerName string) error {
+	err := buf.WriteUint16(uint16(v.typeCovered))
+	if err != nil {
+		return err
+	}
+
+	err = buf.WriteByte(byte(v.algorithm))
+	if err != nil {
+		return err
+	}
+
+	err = buf.WriteByte(v.labels)
+	if err != nil {
+		return err
+	}
+
+	err = buf.WriteUint32(v.originalTTL)
+	if err != nil {
+		return err
+	}
+
+	err = buf.WriteUint32(v.expiration)
+	if err != nil {
+		return err
+	}
+
+	err = buf.WriteUint32(v.inception)
+	if err != nil {
+		return err
+	}
+
+	err = buf.WriteUint16(v.keyTag)
+	if err != nil {
+		return err
+	}
+
+	return buf.WriteLabel(signerName)
+}
+
+func (v *RRSIG) WriteData(buf *buffer.BytePacketBuffer) error {
+	err := buf.PrependDataLength(func() error {
+		err := v.writeFields(buf, v.signerName)
+		if err != nil {
+			return err
+		}
+
+		return buf.WriteBytes(v.signature)
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *RRSIG) String() string {
+	r := new(strings.Builder)
+
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
+	fmt.Fprintf(r, "Type covered: %d (%s)\n", v.typeCovered, v.typeCovered.String())
+	fmt.Fprintf(r, "Algorithm: %d (%s)\n", v.algorithm, v.algorithm.String())
+	fmt.Fprintf(r, "Labels: %d\n", v.labels)
+	fmt.Fprintf(r, "Original TTL: %d\n", v.originalTTL)
+	fmt.Fprintf(r, "Expiration: %s\n", formatRRSIGTime(v.expiration))
+	fmt.Fprintf(r, "Inception: %s\n", formatRRSIGTime(v.inception))
+	fmt.Fprintf(r, "Key tag: %d\n", v.keyTag)
+	fmt.Fprintf(r, "Signer name: %s\n", v.signerName)
+	fmt.Fprintf(r, "Signature: %s", base64.StdEncoding.EncodeToString(v.signature))
+
+	return r.String()
+}
+
+func (v *RRSIG) CompactString() string {
+	return fmt.Sprintf("RRSIG { Domain: %s, TypeCovered: %s, Algorithm: %s, KeyTag: %d, Signer: %s, TTL: %d }", v.Name, v.typeCovered.String(), v.algorithm.String(), v.keyTag, v.signerName, v.TTL)
+}
+
+func (v *RRSIG) PresentationString() string {
+	return v.presentationPreamble() + fmt.Sprintf(
+		"%s %d %d %d %s %s %d %s %s",
+		v.typeCovered.String(), v.algorithm, v.labels, v.originalTTL,
+		formatRRSIGTime(v.expiration), formatRRSIGTime(v.inception),
+		v.keyTag, fqdn(v.signerName), base64.StdEncoding.EncodeToString(v.signature),
+	)
+}
+
+// formatRRSIGTime formats signature expiration/inception as YYYYMMDDHHmmSS in UTC (RFC 4034, section 3.2)
+func formatRRSIGTime(timestamp uint32) string {
+	return time.Unix(int64(timestamp), 0).UTC().Format(rrsigTimeFormat)
+}
+
+func (v *RRSIG) ParseData(fields []string) error {
+	if len(fields) < 9 {
+		return fmt.Errorf("RRSIG record data must consist of type covered, algorithm, labels, original TTL, " +
+			"expiration, inception, key tag, signer's name and signature")
+	}
+
+	typeCovered, err := common.ParseQueryType(fields[0])
+	if err != nil {
+		return err
+	}
+
+	algorithm, err := parseUintField(fields[1], 8, "RRSIG algorithm")
+	if err != nil {
+		return err
+	}
+
+	labels, err := parseUintField(fields[2], 8, "RRSIG labels")
+	if err != nil {
+		return err
+	}
+
+	originalTTL, err := parseUintField(fields[3], 32, "RRSIG original TTL")
+	if err != nil {
+		return err
+	}
+
+	expiration, err := parseRRSIGTime(fields[4])
+	if err != nil {
+		return err
+	}
+
+	inception, err := parseRRSIGTime(fields[5])
+	if err != nil {
+		return err
+	}
+
+	keyTag, err := parseUintField(fields[6], 16, "RRSIG key tag")
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.Join(fields[8:], ""))
+	if err != nil {
+		return fmt.Errorf("invalid RRSIG signature: %s", err.Error())
+	}
+
+	record, err := NewRRSIG(v.Name, 0, typeCovered, common.DNSSECAlgorithm(algorithm), uint8(labels), uint32(originalTTL),
+		expiration, inception, uint16(keyTag), fields[7], signature)
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *RRSIG) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *RRSIG) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.RRSIG
+
+	return UnmarshalRecordJSON(data, v)
+}
@@ -3,6 +3,7 @@ package dns_record
 import (
 	"fmt"
 	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
 	"strings"
 )
 
@@ -17,6 +18,62 @@ type SOA struct {
 	minimum uint32
 }
 
+func NewSOA(name string, ttl uint32, mName string, rName string, serial uint32, refresh uint32, retry uint32, expire uint32, minimum uint32) (*SOA, error) {
+	abstract, err := newAbstractRecord(name, common.SOA, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	mName, err = validateHost(mName)
+	if err != nil {
+		return nil, err
+	}
+
+	rName, err = validateHost(rName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SOA{
+		AbstractDnsRecord: abstract,
+		mName:             mName,
+		rName:             rName,
+		serial:            serial,
+		refresh:           refresh,
+		retry:             retry,
+		expire:            expire,
+		minimum:           minimum,
+	}, nil
+}
+
+func (v *SOA) GetMName() string {
+	return v.mName
+}
+
+func (v *SOA) GetRName() string {
+	return v.rName
+}
+
+func (v *SOA) GetSerial() uint32 {
+	return v.serial
+}
+
+func (v *SOA) GetRefresh() uint32 {
+	return v.refresh
+}
+
+func (v *SOA) GetRetry() uint32 {
+	return v.retry
+}
+
+func (v *SOA) GetExpire() uint32 {
+	return v.expire
+}
+
+func (v *SOA) GetMinimum() uint32 {
+	return v.minimum
+}
+
 func (v *SOA) ReadData(buf *buffer.BytePacketBuffer) error {
 	mName, err := buf.ReadLabel()
 	if err != nil {
@@ -113,7 +170,7 @@ func (v *SOA) WriteData(buf *buffer.BytePacketBuffer) error {
 func (v *SOA) String() string {
 	r := new(strings.Builder)
 
-	fmt.Fprintf(r, v.AbstractDnsRecord.String())
+	fmt.Fprint(r, v.AbstractDnsRecord.String())
 	fmt.Fprintf(r, "m_name: %s\n", v.mName)
 	fmt.Fprintf(r, "r_name: %s\n", v.rName)
 	fmt.Fprintf(r, "Serial: %d\n", v.serial)
@@ -128,3 +185,43 @@ func (v *SOA) String() string {
 func (v *SOA) CompactString() string {
 	return fmt.Sprintf("SOA { Domain: %s, MName: %s, RName: %s, Serial: %d, Refresh: %d, Retry: %d, Expire: %d, Minimum: %d, TTL: %d }", v.Name, v.mName, v.rName, v.serial, v.refresh, v.retry, v.expire, v.minimum, v.TTL)
 }
+
+func (v *SOA) PresentationString() string {
+	return v.presentationPreamble() + fmt.Sprintf("%s %s %d %d %d %d %d", fqdn(v.mName), fqdn(v.rName), v.serial, v.refresh, v.retry, v.expire, v.minimum)
+}
+
+func (v *SOA) ParseData(fields []string) error {
+	if len(fields) != 7 {
+		return fmt.Errorf("SOA record data must consist of mname, rname, serial, refresh, retry, expire and minimum")
+	}
+
+	values := make([]uint32, 0, 5)
+	for _, field := range fields[2:] {
+		value, err := parseUintField(field, 32, "SOA field")
+		if err != nil {
+			return err
+		}
+
+		values = append(values, uint32(value))
+	}
+
+	record, err := NewSOA(v.Name, 0, fields[0], fields[1], values[0], values[1], values[2], values[3], values[4])
+	if err != nil {
+		return err
+	}
+
+	record.AbstractDnsRecord = v.AbstractDnsRecord
+	*v = *record
+
+	return nil
+}
+
+func (v *SOA) MarshalJSON() ([]byte, error) {
+	return MarshalRecordJSON(v)
+}
+
+func (v *SOA) UnmarshalJSON(data []byte) error {
+	v.QueryType = common.SOA
+
+	return UnmarshalRecordJSON(data, v)
+}
@@ -0,0 +1,97 @@
+package dns_record
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"sort"
+	"strings"
+)
+
+/*
+ * Type bit maps are used by NSEC and NSEC3 records to list RR types present at the owner name.
+ * Types are split into 256 windows, each one encoded as: window number, bitmap length (1-32) and bitmap,
+ * where the most significant bit of the first byte represents type 0 of the window (RFC 4034, section 4.1.2).
+ */
+
+func decodeTypeBitmap(data []byte) ([]common.QueryType, error) {
+	result := make([]common.QueryType, 0)
+	lastWindow := -1
+
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("truncated type bitmap")
+		}
+
+		window := int(data[0])
+		length := int(data[1])
+
+		if window <= lastWindow {
+			return nil, fmt.Errorf("type bitmap windows are not in ascending order")
+		}
+
+		if length == 0 || length > 32 || len(data) < 2+length {
+			return nil, fmt.Errorf("invalid type bitmap window length (%d)", length)
+		}
+
+		for i, b := range data[2 : 2+length] {
+			for bit := 0; bit < 8; bit++ {
+				if b&(0x80>>bit) != 0 {
+					result = append(result, common.QueryType(window<<8|i*8+bit))
+				}
+			}
+		}
+
+		lastWindow = window
+		data = data[2+length:]
+	}
+
+	return result, nil
+}
+
+func encodeTypeBitmap(types []common.QueryType) []byte {
+	sorted := make([]common.QueryType, len(types))
+	copy(sorted, types)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	result := make([]byte, 0)
+
+	for i := 0; i < len(sorted); {
+		window := byte(sorted[i] >> 8)
+		bitmap := make([]byte, 32)
+		length := 0
+
+		for ; i < len(sorted) && byte(sorted[i]>>8) == window; i++ {
+			low := int(sorted[i] & 0xFF)
+			bitmap[low/8] |= 0x80 >> (low % 8)
+
+			if low/8+1 > length {
+				length = low/8 + 1
+			}
+		}
+
+		result = append(result, window, byte(length))
+		result = append(result, bitmap[:length]...)
+	}
+
+	return result
+}
+
+func typesPresentation(types []common.QueryType) string {
+	mnemonics := make([]string, 0, len(types))
+
+	for _, qType := range types {
+		mnemonics = append(mnemonics, qType.String())
+	}
+
+	return strings.Join(mnemonics, " ")
+}
+
+func hasType(types []common.QueryType, qType common.QueryType) bool {
+	for _, t := range types {
+		if t == qType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,86 @@
+package dns_record
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"strings"
+)
+
+const (
+	maxNameLength  = 255
+	maxLabelLength = 63
+	maxTTL         = 0x7FFFFFFF // RFC 2181, section 8
+)
+
+// ValidateName checks whether the name can be encoded in the wire format: labels must be 1-63 bytes long,
+// with the whole name not exceeding 255 bytes (RFC 1035, section 2.3.4)
+func ValidateName(name string) error {
+	name = strings.TrimSuffix(name, ".")
+
+	if name == "" {
+		return nil
+	}
+
+	// each label is prefixed with its length, and the name is terminated by the zero byte
+	if len(name)+2 > maxNameLength {
+		return fmt.Errorf("name %s is too long (over %d bytes)", name, maxNameLength)
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 {
+			return fmt.Errorf("name %s contains an empty label", name)
+		}
+
+		if len(label) > maxLabelLength {
+			return fmt.Errorf("label %s of name %s is too long (over %d bytes)", label, name, maxLabelLength)
+		}
+	}
+
+	return nil
+}
+
+// newAbstractRecord creates the preamble of a record, validating the owner name and TTL
+func newAbstractRecord(name string, qType common.QueryType, ttl uint32) (AbstractDnsRecord, error) {
+	result := NewAbstractRecord()
+
+	err := ValidateName(name)
+	if err != nil {
+		return result, err
+	}
+
+	if ttl > maxTTL {
+		return result, fmt.Errorf("TTL %d is too large (max %d)", ttl, maxTTL)
+	}
+
+	result.Name = strings.TrimSuffix(name, ".")
+	result.QueryType = qType
+	result.TTL = ttl
+
+	return result, nil
+}
+
+// validateHost validates a name embedded in record data and returns it without the trailing dot
+func validateHost(host string) (string, error) {
+	err := ValidateName(host)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(host, "."), nil
+}
+
+// labelCount returns the number of labels of the name, not counting the root and the leading wildcard (RFC 4034, section 3.1.3)
+func labelCount(name string) int {
+	name = strings.TrimSuffix(name, ".")
+
+	if name == "" {
+		return 0
+	}
+
+	labels := strings.Split(name, ".")
+	if labels[0] == "*" {
+		return len(labels) - 1
+	}
+
+	return len(labels)
+}
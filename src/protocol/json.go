@@ -0,0 +1,336 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"github.com/wiktor-mazur/dns-go/src/protocol/json_object"
+	"strings"
+)
+
+/*
+ * JSON representation of DNS messages follows RFC 8427: header fields are members of the message object, while
+ * questions and records are objects in the arrays of their sections. Records are written and read by the functions
+ * of dns_record package, so that they look the same whether they're a part of a message or not.
+ */
+
+func flagValue(flag bool) int {
+	if flag {
+		return 1
+	}
+
+	return 0
+}
+
+func (v *DnsHeader) jsonObject() json_object.Object {
+	result := json_object.Object{}.
+		Add("ID", v.ID).
+		Add("QR", flagValue(v.IsResponse)).
+		Add("Opcode", uint8(v.OPCODE)).
+		Add("AA", flagValue(v.AuthoritativeAnswer)).
+		Add("TC", flagValue(v.TruncatedMessage)).
+		Add("RD", flagValue(v.RecursionDesired)).
+		Add("RA", flagValue(v.RecursionAvailable)).
+		Add("AD", flagValue(v.AuthedData)).
+		Add("CD", flagValue(v.CheckingDisabled)).
+		Add("RCODE", uint8(v.ResultCode)).
+		Add("QDCOUNT", v.QuestionsCount).
+		Add("ANCOUNT", v.AnswersCount).
+		Add("NSCOUNT", v.AuthoritiesCount).
+		Add("ARCOUNT", v.ResourcesCount)
+
+	// the reserved bit is not a part of RFC 8427, but it's kept so that messages round-trip without loss
+	if v.DNSSECAvailable {
+		result = result.Add("Z", 1)
+	}
+
+	return result
+}
+
+func (v *DnsHeader) MarshalJSON() ([]byte, error) {
+	return v.jsonObject().MarshalJSON()
+}
+
+func (v *DnsHeader) UnmarshalJSON(data []byte) error {
+	var members json_object.Members
+
+	err := json.Unmarshal(data, &members)
+	if err != nil {
+		return err
+	}
+
+	return v.readJSONMembers(members)
+}
+
+func (v *DnsHeader) readJSONMembers(members json_object.Members) error {
+	id, err := members.Uint("ID", 16)
+	if err != nil {
+		return err
+	}
+
+	v.ID = uint16(id)
+
+	if members.Has("Opcode") {
+		opcode, err := members.Uint("Opcode", 4)
+		if err != nil {
+			return err
+		}
+
+		v.OPCODE = common.OPCODE(opcode)
+	}
+
+	if members.Has("RCODE") {
+		rcode, err := members.Uint("RCODE", 4)
+		if err != nil {
+			return err
+		}
+
+		v.ResultCode = common.ResultCode(rcode)
+	}
+
+	flags := []struct {
+		key   string
+		value *bool
+	}{
+		{"QR", &v.IsResponse},
+		{"AA", &v.AuthoritativeAnswer},
+		{"TC", &v.TruncatedMessage},
+		{"RD", &v.RecursionDesired},
+		{"RA", &v.RecursionAvailable},
+		{"Z", &v.DNSSECAvailable},
+		{"AD", &v.AuthedData},
+		{"CD", &v.CheckingDisabled},
+	}
+
+	for _, flag := range flags {
+		*flag.value, err = members.Flag(flag.key)
+		if err != nil {
+			return err
+		}
+	}
+
+	counts := []struct {
+		key   string
+		value *uint16
+	}{
+		{"QDCOUNT", &v.QuestionsCount},
+		{"ANCOUNT", &v.AnswersCount},
+		{"NSCOUNT", &v.AuthoritiesCount},
+		{"ARCOUNT", &v.ResourcesCount},
+	}
+
+	for _, count := range counts {
+		if !members.Has(count.key) {
+			continue
+		}
+
+		value, err := members.Uint(count.key, 16)
+		if err != nil {
+			return err
+		}
+
+		*count.value = uint16(value)
+	}
+
+	return nil
+}
+
+func (v *DnsQuestion) jsonObject() json_object.Object {
+	return json_object.Object{}.
+		Add("NAME", v.Name).
+		Add("TYPE", uint16(v.QueryType)).
+		Add("TYPEname", v.QueryType.String()).
+		Add("CLASS", uint16(v.Class)).
+		Add("CLASSname", v.Class.String())
+}
+
+func (v *DnsQuestion) MarshalJSON() ([]byte, error) {
+	return v.jsonObject().MarshalJSON()
+}
+
+func (v *DnsQuestion) UnmarshalJSON(data []byte) error {
+	var members json_object.Members
+
+	err := json.Unmarshal(data, &members)
+	if err != nil {
+		return err
+	}
+
+	name, err := members.String("NAME")
+	if err != nil {
+		return err
+	}
+
+	qType, err := members.QueryType("TYPE")
+	if err != nil {
+		return err
+	}
+
+	class, err := members.Class("CLASS")
+	if err != nil {
+		return err
+	}
+
+	v.Name = strings.TrimSuffix(name, ".")
+	v.QueryType = qType
+	v.Class = class
+
+	return nil
+}
+
+// recordFromJSON creates the record described by RFC 8427 JSON object as its registered type
+func recordFromJSON(data []byte) (DnsRecord, error) {
+	abstract, rData, err := dns_record.ReadRecordJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRecordFromRData(abstract, rData)
+}
+
+func (v *DnsPacket) MarshalJSON() ([]byte, error) {
+	header := v.Header
+	header.QuestionsCount = uint16(len(v.Questions))
+	header.AnswersCount = uint16(len(v.Answers))
+	header.AuthoritiesCount = uint16(len(v.Authorities))
+	header.ResourcesCount = uint16(len(v.Resources))
+
+	result := header.jsonObject()
+
+	// the only question is also described by the members of the message (RFC 8427, section 2.1)
+	if len(v.Questions) == 1 {
+		question := v.Questions[0]
+
+		result = result.
+			Add("QNAME", question.Name).
+			Add("QTYPE", uint16(question.QueryType)).
+			Add("QTYPEname", question.QueryType.String()).
+			Add("QCLASS", uint16(question.Class)).
+			Add("QCLASSname", question.Class.String())
+	}
+
+	questions := make([]json_object.Object, 0, len(v.Questions))
+	for _, question := range v.Questions {
+		questions = append(questions, question.jsonObject())
+	}
+
+	result = result.Add("questionRRs", questions)
+
+	sections := []struct {
+		key     string
+		records []DnsRecord
+	}{
+		{"answerRRs", v.Answers},
+		{"authorityRRs", v.Authorities},
+		{"additionalRRs", v.Resources},
+	}
+
+	for _, section := range sections {
+		records := make([]json.RawMessage, 0, len(section.records))
+
+		// records of registered types embedding AbstractDnsRecord might not implement json.Marshaler themselves
+		for _, record := range section.records {
+			object, err := dns_record.MarshalRecordJSON(record)
+			if err != nil {
+				return nil, err
+			}
+
+			records = append(records, object)
+		}
+
+		result = result.Add(section.key, records)
+	}
+
+	return result.MarshalJSON()
+}
+
+func (v *DnsPacket) UnmarshalJSON(data []byte) error {
+	var members json_object.Members
+
+	err := json.Unmarshal(data, &members)
+	if err != nil {
+		return err
+	}
+
+	packet := DnsPacket{}
+
+	err = packet.Header.readJSONMembers(members)
+	if err != nil {
+		return err
+	}
+
+	if members.Has("questionRRs") {
+		err = json.Unmarshal(members["questionRRs"], &packet.Questions)
+		if err != nil {
+			return fmt.Errorf("invalid questionRRs member: %s", err.Error())
+		}
+	} else if members.Has("QNAME") {
+		question, err := readQuestionJSONMembers(members)
+		if err != nil {
+			return err
+		}
+
+		packet.Questions = []DnsQuestion{*question}
+	}
+
+	sections := []struct {
+		key     string
+		records *[]DnsRecord
+	}{
+		{"answerRRs", &packet.Answers},
+		{"authorityRRs", &packet.Authorities},
+		{"additionalRRs", &packet.Resources},
+	}
+
+	for _, section := range sections {
+		if !members.Has(section.key) {
+			continue
+		}
+
+		var objects []json.RawMessage
+
+		err = json.Unmarshal(members[section.key], &objects)
+		if err != nil {
+			return fmt.Errorf("invalid %s member: %s", section.key, err.Error())
+		}
+
+		for _, object := range objects {
+			record, err := recordFromJSON(object)
+			if err != nil {
+				return fmt.Errorf("invalid record in %s: %s", section.key, err.Error())
+			}
+
+			*section.records = append(*section.records, record)
+		}
+	}
+
+	packet.Header.QuestionsCount = uint16(len(packet.Questions))
+	packet.Header.AnswersCount = uint16(len(packet.Answers))
+	packet.Header.AuthoritiesCount = uint16(len(packet.Authorities))
+	packet.Header.ResourcesCount = uint16(len(packet.Resources))
+
+	*v = packet
+
+	return nil
+}
+
+// readQuestionJSONMembers reads the question described by QNAME, QTYPE and QCLASS members of the message
+func readQuestionJSONMembers(members json_object.Members) (*DnsQuestion, error) {
+	name, err := members.String("QNAME")
+	if err != nil {
+		return nil, err
+	}
+
+	qType, err := members.QueryType("QTYPE")
+	if err != nil {
+		return nil, err
+	}
+
+	class, err := members.Class("QCLASS")
+	if err != nil {
+		return nil, err
+	}
+
+	return &DnsQuestion{Name: strings.TrimSuffix(name, "."), QueryType: qType, Class: class}, nil
+}
@@ -0,0 +1,149 @@
+package json_object
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+)
+
+type member struct {
+	key   string
+	value any
+}
+
+// Object is JSON object whose members are written in the order they were added, like in RFC 8427 examples
+type Object []member
+
+// Add returns the object with the member appended
+func (v Object) Add(key string, value any) Object {
+	return append(v, member{key, value})
+}
+
+func (v Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for idx, member := range v {
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(member.key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// Members holds members of JSON object being read
+type Members map[string]json.RawMessage
+
+func (v Members) Has(key string) bool {
+	_, ok := v[key]
+
+	return ok
+}
+
+// Uint reads the unsigned integer member, which must fit in the given number of bits
+func (v Members) Uint(key string, bits int) (uint64, error) {
+	raw, ok := v[key]
+	if !ok {
+		return 0, fmt.Errorf("missing %s member", key)
+	}
+
+	var result uint64
+
+	err := json.Unmarshal(raw, &result)
+	if err != nil || result >= 1<<bits {
+		return 0, fmt.Errorf("invalid %s member: %s", key, string(raw))
+	}
+
+	return result, nil
+}
+
+// Flag reads the member holding a header flag, given either as 0/1 (like in RFC 8427 examples) or as a boolean
+func (v Members) Flag(key string) (bool, error) {
+	raw, ok := v[key]
+	if !ok {
+		return false, nil
+	}
+
+	var boolean bool
+	if json.Unmarshal(raw, &boolean) == nil {
+		return boolean, nil
+	}
+
+	value, err := v.Uint(key, 1)
+	if err != nil {
+		return false, err
+	}
+
+	return value == 1, nil
+}
+
+func (v Members) String(key string) (string, error) {
+	raw, ok := v[key]
+	if !ok {
+		return "", fmt.Errorf("missing %s member", key)
+	}
+
+	var result string
+
+	err := json.Unmarshal(raw, &result)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s member: %s", key, string(raw))
+	}
+
+	return result, nil
+}
+
+// QueryType reads the type given as a number, or by the mnemonic if there's no number
+func (v Members) QueryType(key string) (common.QueryType, error) {
+	if !v.Has(key) && v.Has(key+"name") {
+		name, err := v.String(key + "name")
+		if err != nil {
+			return 0, err
+		}
+
+		return common.ParseQueryType(name)
+	}
+
+	value, err := v.Uint(key, 16)
+
+	return common.QueryType(value), err
+}
+
+// Class reads the class given as a number, or by the mnemonic if there's no number (IN if there's neither)
+func (v Members) Class(key string) (common.Class, error) {
+	if !v.Has(key) && v.Has(key+"name") {
+		name, err := v.String(key + "name")
+		if err != nil {
+			return 0, err
+		}
+
+		return common.ParseClass(name)
+	}
+
+	if !v.Has(key) {
+		return common.IN, nil
+	}
+
+	value, err := v.Uint(key, 16)
+
+	return common.Class(value), err
+}
@@ -0,0 +1,130 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"net"
+	"strings"
+	"testing"
+)
+
+var testRecords = []string{
+	"example.com. 300 IN A 192.0.2.1",
+	"example.com. 300 IN AAAA 2001:db8::1",
+	"example.com. 300 IN MX 10 mail.example.com.",
+	"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 2024010101 7200 3600 1209600 300",
+	"example.com. 3600 IN DNSKEY 257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==",
+	"example.com. 300 IN RRSIG A 13 2 300 20240201000000 20240101000000 12345 example.com. c2lnbmF0dXJl",
+	"example.com. 300 IN NSEC www.example.com. A MX RRSIG NSEC DNSKEY",
+	`example.com. 300 IN TYPE65300 \# 4 DEADBEEF`,
+}
+
+// testPacket returns the response carrying records of the types known to dns-go, OPT and a record of an unknown type
+func testPacket(t *testing.T) *DnsPacket {
+	t.Helper()
+
+	packet := NewDnsPacket()
+	packet.Header.ID = 4321
+	packet.Header.IsResponse = true
+	packet.Header.RecursionDesired = true
+	packet.Header.AuthedData = true
+	packet.AddQuestion(DnsQuestion{Name: "example.com", QueryType: common.A, Class: common.IN})
+
+	for _, line := range testRecords {
+		record, err := ParseRecord(line)
+		if err != nil {
+			t.Fatalf("could not parse %q: %s", line, err)
+		}
+
+		packet.AddAnswer(record)
+	}
+
+	packet.AddResource(dns_record.NewOPT(1232, true))
+
+	return packet
+}
+
+func TestPacketRoundTripsThroughJSON(t *testing.T) {
+	rawPacket, err := testPacket(t).ToRawBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet, err := DnsPacketFromRawBuffer(rawPacket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result DnsPacket
+
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		t.Fatalf("could not read back %s: %s", data, err)
+	}
+
+	rawResult, err := result.ToRawBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rawPacket, rawResult) {
+		t.Errorf("message changed after the round trip through %s", data)
+	}
+
+	if _, ok := result.Answers[len(result.Answers)-1].(*dns_record.AbstractDnsRecord); !ok {
+		t.Errorf("expected the record of unknown type to be kept in the generic form")
+	}
+}
+
+func TestRecordRoundTripsThroughJSON(t *testing.T) {
+	for _, line := range testRecords {
+		record, err := ParseRecord(line)
+		if err != nil {
+			t.Fatalf("could not parse %q: %s", line, err)
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(data), `"RDATAHEX"`) {
+			t.Errorf("expected RFC 8427 object for %q, got %s", line, data)
+		}
+
+		result := NewRecord(dns_record.AbstractDnsRecord{QueryType: record.GetType()})
+
+		err = json.Unmarshal(data, result)
+		if err != nil {
+			t.Fatalf("could not read back %s: %s", data, err)
+		}
+
+		if result.PresentationString() != record.PresentationString() {
+			t.Errorf("expected %q after the round trip, got %q", record.PresentationString(), result.PresentationString())
+		}
+	}
+}
+
+func TestRecordOfOtherTypeIsRejected(t *testing.T) {
+	record, err := dns_record.NewAAAA("example.com", 300, net.ParseIP("2001:db8::1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.Unmarshal(data, &dns_record.A{})
+	if err == nil {
+		t.Errorf("expected AAAA record not to be read as A record")
+	}
+}
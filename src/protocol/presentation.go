@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"strconv"
+	"strings"
+)
+
+// NewRecordFromRData creates a record of the type given in the preamble and reads its data from the wire format
+func NewRecordFromRData(abstract dns_record.AbstractDnsRecord, rData []byte) (DnsRecord, error) {
+	if len(rData) > buffer.MaxDNSBufferSize {
+		return nil, fmt.Errorf("record data is too long (%d bytes)", len(rData))
+	}
+
+	abstract.DataLength = uint16(len(rData))
+	record := NewRecord(abstract)
+
+	buf := buffer.BytePacketBufferFromRawBuffer(rData)
+
+	err := record.ReadData(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if buf.GetPos() != uint(len(rData)) {
+		return nil, fmt.Errorf("invalid data length in %s record", abstract.QueryType.String())
+	}
+
+	return record, nil
+}
+
+// ParseRecord parses a record in the presentation format: "<owner> <TTL> <class> <type> <data>", where data is given
+// in the format of its type or in the generic "\# <length> <hex>" form (RFC 3597, section 5)
+func ParseRecord(line string) (DnsRecord, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return nil, fmt.Errorf("record must consist of owner, TTL, class, type and data")
+	}
+
+	ttl, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TTL: %s", err.Error())
+	}
+
+	class, err := common.ParseClass(fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	qType, err := common.ParseQueryType(fields[3])
+	if err != nil {
+		return nil, err
+	}
+
+	abstract := dns_record.NewAbstractRecord()
+	abstract.Name = strings.TrimSuffix(fields[0], ".")
+	abstract.TTL = uint32(ttl)
+	abstract.Class = class
+	abstract.QueryType = qType
+
+	if fields[4] == "\\#" {
+		rData, err := dns_record.ParseGenericRData(strings.Join(fields[4:], " "))
+		if err != nil {
+			return nil, err
+		}
+
+		return NewRecordFromRData(abstract, rData)
+	}
+
+	// the presentation format of its own is only known to the types whose records implement the parser (e.g. not OPT)
+	record := NewRecord(abstract)
+
+	parser, ok := record.(dns_record.DataParser)
+	if !ok {
+		return nil, fmt.Errorf("data of %s records can only be given in the generic form", qType.String())
+	}
+
+	err = parser.ParseData(fields[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
@@ -0,0 +1,101 @@
+package protocol
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+)
+
+// RecordFactory creates a record of a registered type, given its already read preamble
+type RecordFactory func(abstract dns_record.AbstractDnsRecord) DnsRecord
+
+// builtInRecordTypes are the types known to dns-go; the ones without factory are only given their mnemonics,
+// while their records are kept in the generic form
+var builtInRecordTypes = []common.RecordType{
+	{Code: common.A, Mnemonic: "A", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.A{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.NS, Mnemonic: "NS", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.NS{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.MD, Mnemonic: "MD"},
+	{Code: common.MF, Mnemonic: "MF"},
+	{Code: common.CNAME, Mnemonic: "CNAME", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.CNAME{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.SOA, Mnemonic: "SOA", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.SOA{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.MB, Mnemonic: "MB"},
+	{Code: common.MG, Mnemonic: "MG"},
+	{Code: common.MR, Mnemonic: "MR"},
+	{Code: common.PTR, Mnemonic: "PTR"},
+	{Code: common.MINFO, Mnemonic: "MINFO"},
+	{Code: common.MX, Mnemonic: "MX", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.MX{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.AAAA, Mnemonic: "AAAA", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.AAAA{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.OPT, Mnemonic: "OPT", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.OPT{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.DS, Mnemonic: "DS", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.DS{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.RRSIG, Mnemonic: "RRSIG", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.RRSIG{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.NSEC, Mnemonic: "NSEC", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.NSEC{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.DNSKEY, Mnemonic: "DNSKEY", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.DNSKEY{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.NSEC3, Mnemonic: "NSEC3", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.NSEC3{AbstractDnsRecord: abstract}
+	})},
+	{Code: common.NSEC3PARAM, Mnemonic: "NSEC3PARAM", Factory: RecordFactory(func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &dns_record.NSEC3PARAM{AbstractDnsRecord: abstract}
+	})},
+}
+
+func init() {
+	common.SetRecordFactoryCheck(func(factory any) bool {
+		_, ok := factory.(RecordFactory)
+		return ok
+	})
+
+	for _, recordType := range builtInRecordTypes {
+		err := common.RegisterRecordType(recordType)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterRecordType makes ReadDnsRecord use the factory for records of the given type, and registers its mnemonic.
+// It's meant for applications implementing their own types (e.g. from the private use range), so overriding
+// an already registered implementation is not allowed. Records of such types are written and read as JSON by
+// dns_record.MarshalRecordJSON and dns_record.UnmarshalRecordJSON, which their MarshalJSON and UnmarshalJSON can call.
+// If the records implement dns_record.DataParser, ParseRecord accepts their data in the presentation format of the type,
+// not only in the generic one.
+func RegisterRecordType(qType common.QueryType, mnemonic string, factory RecordFactory) error {
+	if factory == nil {
+		return fmt.Errorf("record factory must not be nil")
+	}
+
+	return common.RegisterRecordType(common.RecordType{Code: qType, Mnemonic: mnemonic, Factory: factory})
+}
+
+// NewRecord creates a record of given type with the preamble already set,
+// falling back to the generic RFC 3597 record if the type has no registered factory
+func NewRecord(abstract dns_record.AbstractDnsRecord) DnsRecord {
+	recordType, _ := common.LookupRecordType(abstract.QueryType)
+	if recordType.Factory == nil {
+		return &abstract
+	}
+
+	// the registry accepts no other factories
+	return recordType.Factory.(RecordFactory)(abstract)
+}
@@ -0,0 +1,89 @@
+package protocol
+
+import (
+	"bytes"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"strings"
+	"testing"
+)
+
+const testPrivateType = common.QueryType(65280)
+
+// privateRecord is a record of a type from the private use range, whose data is the text it's given in
+type privateRecord struct {
+	dns_record.AbstractDnsRecord
+}
+
+func (v *privateRecord) ParseData(fields []string) error {
+	return v.SetRData([]byte(strings.Join(fields, " ")))
+}
+
+func init() {
+	err := RegisterRecordType(testPrivateType, "PRIVATE", func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &privateRecord{AbstractDnsRecord: abstract}
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisteredTypeIsParsed(t *testing.T) {
+	record, err := ParseRecord("example.com. 300 IN PRIVATE some data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private, ok := record.(*privateRecord)
+	if !ok {
+		t.Fatalf("expected the record to be created by the registered factory, got %T", record)
+	}
+
+	if !bytes.Equal(private.GetRData(), []byte("some data")) || private.GetType() != testPrivateType {
+		t.Errorf("unexpected record %q", record.PresentationString())
+	}
+}
+
+func TestRegisteredTypeIsParsedFromGenericForm(t *testing.T) {
+	record, err := ParseRecord(`example.com. 300 IN TYPE65280 \# 2 ABCD`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := record.(*privateRecord); !ok {
+		t.Errorf("expected the record to be created by the registered factory, got %T", record)
+	}
+}
+
+func TestTypeWithoutParserRequiresGenericForm(t *testing.T) {
+	_, err := ParseRecord("example.com. 300 IN OPT 1232")
+	if err == nil {
+		t.Errorf("expected OPT data to be accepted only in the generic form")
+	}
+}
+
+func TestRecordTypeCannotBeOverridden(t *testing.T) {
+	err := RegisterRecordType(common.A, "A", func(abstract dns_record.AbstractDnsRecord) DnsRecord {
+		return &privateRecord{AbstractDnsRecord: abstract}
+	})
+	if err == nil {
+		t.Errorf("expected registering A record type again to fail")
+	}
+}
+
+func TestFactoryOfOtherTypeIsRejected(t *testing.T) {
+	err := common.RegisterRecordType(common.RecordType{
+		Code:     testPrivateType + 1,
+		Mnemonic: "OTHERFACTORY",
+		Factory: func(abstract dns_record.AbstractDnsRecord) *privateRecord {
+			return &privateRecord{AbstractDnsRecord: abstract}
+		},
+	})
+	if err == nil {
+		t.Fatalf("expected the factory not returning DnsRecord to be rejected")
+	}
+
+	if _, ok := common.LookupRecordType(testPrivateType + 1); ok {
+		t.Errorf("expected the rejected type not to be registered")
+	}
+}
@@ -0,0 +1,41 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ReadTCPMessage reads a single DNS message from the stream, where it's prefixed with two-byte length (RFC 1035, section 4.2.2)
+func ReadTCPMessage(r io.Reader) ([]byte, error) {
+	var length uint16
+
+	err := binary.Read(r, binary.BigEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+
+	message := make([]byte, length)
+
+	_, err = io.ReadFull(r, message)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// WriteTCPMessage writes a single DNS message to the stream, prefixed with its length
+func WriteTCPMessage(w io.Writer, message []byte) error {
+	if len(message) > 0xFFFF {
+		return errors.New("message is too long to be sent over TCP")
+	}
+
+	data := make([]byte, 2, 2+len(message))
+	binary.BigEndian.PutUint16(data, uint16(len(message)))
+	data = append(data, message...)
+
+	_, err := w.Write(data)
+
+	return err
+}
@@ -0,0 +1,169 @@
+package resolver
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/dnssec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry describes the data cached by the resolver
+type CacheEntry struct {
+	Cache string `json:"cache"`
+	Name  string `json:"name"`
+	TTL   uint32 `json:"ttl"` // seconds left until the entry expires
+	Value string `json:"value"`
+}
+
+// CacheEntries returns the entries of resolver's caches, sorted by name. Answers are not cached, so only the validated
+// DNSSEC keys of zones are, and the zones of names whose unsigned responses were forwarded.
+func (v *Resolver) CacheEntries() []CacheEntry {
+	now := time.Now()
+	result := make([]CacheEntry, 0)
+
+	for _, cache := range v.caches() {
+		for key, entry := range cache.list() {
+			value := fmt.Sprint(entry.value)
+			if zone, ok := entry.value.(string); ok {
+				value = displayName(zone)
+			}
+
+			result = append(result, CacheEntry{
+				Cache: cache.name,
+				Name:  displayName(key),
+				TTL:   uint32(entry.expiresAt.Sub(now).Seconds()),
+				Value: value,
+			})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// FlushCache removes all cached data, returning the number of entries removed
+func (v *Resolver) FlushCache() int {
+	return v.flush(nil)
+}
+
+// FlushCacheName removes the data cached for the name, returning the number of entries removed
+func (v *Resolver) FlushCacheName(name string) int {
+	name = dnssec.CanonicalName(name)
+
+	return v.flush(func(key string) bool {
+		return key == name
+	})
+}
+
+// FlushCacheSuffix removes the data cached for the name and all names below it, returning the number of entries removed
+func (v *Resolver) FlushCacheSuffix(suffix string) int {
+	suffix = dnssec.CanonicalName(suffix)
+
+	return v.flush(func(key string) bool {
+		return suffix == "" || key == suffix || strings.HasSuffix(key, "."+suffix)
+	})
+}
+
+func (v *Resolver) caches() []*cache {
+	return []*cache{v.keyCache, v.zoneCache}
+}
+
+func (v *Resolver) flush(match func(key string) bool) int {
+	result := 0
+	for _, cache := range v.caches() {
+		result += cache.flush(match)
+	}
+
+	return result
+}
+
+func (v *zoneKeys) String() string {
+	if v.err != nil {
+		return fmt.Sprintf("%s, error: %s", v.status.String(), v.err.Error())
+	}
+
+	tags := make([]string, 0, len(v.keys))
+	for _, key := range v.keys {
+		tags = append(tags, fmt.Sprint(key.GetKeyTag()))
+	}
+
+	return fmt.Sprintf("%s, key tags: %s", v.status.String(), strings.Join(tags, " "))
+}
+
+// UpstreamHealth describes the results of exchanges with the upstream used in forwarding mode
+type UpstreamHealth struct {
+	Upstream            string     `json:"upstream"`
+	Healthy             bool       `json:"healthy"` // whether the last exchange succeeded (or none were made yet)
+	Queries             uint64     `json:"queries"`
+	Failures            uint64     `json:"failures"`
+	ConsecutiveFailures uint64     `json:"consecutive_failures"`
+	LastRTT             float64    `json:"last_rtt_ms"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// upstreamsHealth tracks the health of all upstreams
+type upstreamsHealth struct {
+	mu        sync.Mutex
+	upstreams map[string]*UpstreamHealth
+}
+
+func newUpstreamsHealth(upstreams []Upstream) *upstreamsHealth {
+	result := &upstreamsHealth{upstreams: make(map[string]*UpstreamHealth)}
+
+	for _, upstream := range upstreams {
+		result.upstreams[upstream.String()] = &UpstreamHealth{Upstream: upstream.String(), Healthy: true}
+	}
+
+	return result
+}
+
+// record updates the health of the upstream after the exchange; responses with errors still mean the upstream is reachable
+func (v *upstreamsHealth) record(upstream Upstream, start time.Time, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	health, ok := v.upstreams[upstream.String()]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+
+	health.Queries++
+	health.LastRTT = float64(now.Sub(start).Microseconds()) / 1000
+
+	if err != nil {
+		health.Healthy = false
+		health.Failures++
+		health.ConsecutiveFailures++
+		health.LastFailure = &now
+		health.LastError = err.Error()
+
+		return
+	}
+
+	health.Healthy = true
+	health.ConsecutiveFailures = 0
+	health.LastSuccess = &now
+}
+
+// UpstreamHealth returns the health of upstreams in the order of preference, or nothing if forwarding is not used
+func (v *Resolver) UpstreamHealth() []UpstreamHealth {
+	v.upstreamsHealth.mu.Lock()
+	defer v.upstreamsHealth.mu.Unlock()
+
+	result := make([]UpstreamHealth, 0, len(v.cfg.Upstreams))
+
+	for _, upstream := range v.cfg.Upstreams {
+		result = append(result, *v.upstreamsHealth.upstreams[upstream.String()])
+	}
+
+	return result
+}
@@ -0,0 +1,120 @@
+package resolver
+
+import (
+	"github.com/wiktor-mazur/dns-go/src/metrics"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// cache is a simple thread-safe key-value store with entries expiring after their TTL
+type cache struct {
+	name       string // used in metrics
+	mu         sync.Mutex
+	entries    map[string]cacheEntry
+	maxEntries int
+}
+
+func newCache(name string, maxEntries int) *cache {
+	return &cache{name: name, entries: make(map[string]cacheEntry), maxEntries: maxEntries}
+}
+
+func (v *cache) get(key string) (interface{}, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry, ok := v.entries[key]
+	if !ok {
+		metrics.CacheMisses.WithLabelValues(v.name).Inc()
+		return nil, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(v.entries, key)
+		metrics.CacheMisses.WithLabelValues(v.name).Inc()
+		return nil, false
+	}
+
+	metrics.CacheHits.WithLabelValues(v.name).Inc()
+
+	return entry.value, true
+}
+
+func (v *cache) set(key string, value interface{}, ttl time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, exists := v.entries[key]; !exists && len(v.entries) >= v.maxEntries {
+		v.evict()
+	}
+
+	v.entries[key] = cacheEntry{value: value, expiresAt: time.Now().Add(ttl)}
+}
+
+// evict removes expired entries or, if there are none, an arbitrary one to make room for a new entry
+func (v *cache) evict() {
+	now := time.Now()
+	evicted := 0
+
+	for key, entry := range v.entries {
+		if now.After(entry.expiresAt) {
+			delete(v.entries, key)
+			evicted++
+		}
+	}
+
+	if evicted == 0 {
+		for key := range v.entries {
+			delete(v.entries, key)
+			evicted++
+			break
+		}
+	}
+
+	metrics.CacheEvictions.WithLabelValues(v.name).Add(float64(evicted))
+}
+
+// list returns copies of the entries which haven't expired yet
+func (v *cache) list() map[string]cacheEntry {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	result := make(map[string]cacheEntry, len(v.entries))
+
+	for key, entry := range v.entries {
+		if !now.After(entry.expiresAt) {
+			result[key] = entry
+		}
+	}
+
+	return result
+}
+
+// flush removes the entries whose keys match, or all entries if match is nil, returning the number of entries removed
+func (v *cache) flush(match func(key string) bool) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if match == nil {
+		flushed := len(v.entries)
+		v.entries = make(map[string]cacheEntry)
+
+		return flushed
+	}
+
+	flushed := 0
+
+	for key := range v.entries {
+		if match(key) {
+			delete(v.entries, key)
+			flushed++
+		}
+	}
+
+	return flushed
+}
@@ -0,0 +1,204 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/dnssec"
+	"github.com/wiktor-mazur/dns-go/src/dnstap"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const zoneCacheSize = 10000
+
+// unknownZone stands for the zone of the forwarded response which couldn't be found, so the response is insecure.
+// It's not a name the resolver could return otherwise, as names are kept without the trailing dot.
+const unknownZone = "."
+
+// forward sends the query to upstreams, returning also the zone the response comes from, which is only needed
+// (and looked up) when it's not signed
+func (v *Resolver) forward(ctx context.Context, queryID uint16, qName string, qType common.QueryType) (*protocol.DnsPacket, string, error) {
+	response, err := v.exchangeUpstreams(ctx, queryID, qName, qType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !v.cfg.DNSSECValidation || isSigned(response) {
+		return response, "", nil
+	}
+
+	zone, err := v.findZone(ctx, queryID, qName, response)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+
+		// without the zone, the validator can't tell whether the response should have been signed
+		slog.Warn("Could not find the zone of unsigned response, passing it as insecure", "id", queryID, "name", qName, "error", err)
+
+		return response, unknownZone, nil
+	}
+
+	return response, zone, nil
+}
+
+// exchangeUpstreams sends the query to the first upstream which responds
+func (v *Resolver) exchangeUpstreams(ctx context.Context, queryID uint16, qName string, qType common.QueryType) (*protocol.DnsPacket, error) {
+	query := buildQueryPacket(qName, qType, v.cfg.DNSSECValidation)
+
+	// data is validated locally, so the upstream should return it even if its own validation fails (RFC 4035, section 3.2.2)
+	query.Header.CheckingDisabled = v.cfg.DNSSECValidation
+
+	var result *protocol.DnsPacket
+	err := errors.New("no upstreams configured")
+
+	for _, upstream := range v.cfg.Upstreams {
+		slog.Debug("Forwarding lookup", "id", queryID, "name", qName, "type", qType.String(), "upstream", upstream.String())
+
+		_, span := tracing.Tracer().Start(ctx, "Forward", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			tracing.Nameserver.String(upstream.String()),
+			tracing.QueryName.String(qName),
+			tracing.QueryType.String(qType.String()),
+		))
+
+		start := time.Now()
+		response, exchangeErr := upstream.Exchange(ctx, query)
+
+		scheme := strings.SplitN(upstream.String(), "://", 2)[0]
+		observeUpstreamQuery(upstream.String(), scheme, start, response, exchangeErr)
+		traceExchange(span, start, response, exchangeErr)
+		v.upstreamsHealth.record(upstream, start, exchangeErr)
+		span.End()
+		v.tapForwarded(scheme, query, response, start)
+
+		if exchangeErr != nil {
+			slog.Warn("Upstream failed", "id", queryID, "upstream", upstream.String(), "error", exchangeErr)
+			err = exchangeErr
+			continue
+		}
+
+		// another upstream may be able to answer, but the failure is returned if none of them is
+		if response.Header.ResultCode == common.SERVFAIL || response.Header.ResultCode == common.REFUSED {
+			slog.Debug("Upstream responded with an error", "id", queryID, "upstream", upstream.String(), "rcode", response.Header.ResultCode.String())
+			result = response
+			continue
+		}
+
+		result = response
+		break
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("all upstreams failed, last error: %s", err.Error())
+	}
+
+	return result, nil
+}
+
+// findZone returns the zone the name belongs to, which is the owner of SOA record in the authority section of the response
+// (negative responses include it), or else of the one returned when asked for the name's SOA. Zone cuts found are cached.
+func (v *Resolver) findZone(ctx context.Context, queryID uint16, qName string, response *protocol.DnsPacket) (string, error) {
+	key := dnssec.CanonicalName(qName)
+
+	if soa := findSOA(qName, response.Authorities); soa != nil {
+		v.zoneCache.set(key, soa.GetName(), keyCacheTTL(soa.GetTTL()))
+
+		return soa.GetName(), nil
+	}
+
+	cached, ok := v.zoneCache.get(key)
+	if ok {
+		return cached.(string), nil
+	}
+
+	soaResponse, err := v.exchangeUpstreams(ctx, queryID, qName, common.SOA)
+	if err != nil {
+		return "", err
+	}
+
+	for _, records := range [][]protocol.DnsRecord{soaResponse.Answers, soaResponse.Authorities} {
+		if soa := findSOA(qName, records); soa != nil {
+			v.zoneCache.set(key, soa.GetName(), keyCacheTTL(soa.GetTTL()))
+
+			return soa.GetName(), nil
+		}
+	}
+
+	return "", fmt.Errorf("could not find the zone of %s", displayName(qName))
+}
+
+// findSOA returns SOA record of a zone the name belongs to, if there's one among the records
+func findSOA(qName string, records []protocol.DnsRecord) protocol.DnsRecord {
+	for _, record := range records {
+		if record.GetType() == common.SOA && dnssec.IsSubdomain(qName, record.GetName()) {
+			return record
+		}
+	}
+
+	return nil
+}
+
+func isSigned(response *protocol.DnsPacket) bool {
+	for _, records := range [][]protocol.DnsRecord{response.Answers, response.Authorities} {
+		for _, record := range records {
+			if record.GetType() == common.RRSIG {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// dnstapProtocols maps schemes of upstream URLs to the transports reported in dnstap
+var dnstapProtocols = map[string]dnstap.SocketProtocol{
+	"udp":   dnstap.UDP,
+	"tcp":   dnstap.TCP,
+	"tls":   dnstap.DOT,
+	"https": dnstap.DOH,
+}
+
+// tapForwarded logs the query forwarded to the upstream and its response to dnstap. Upstreams return parsed responses,
+// so messages are serialized again, which may differ from the original ones (e.g. in name compression).
+func (v *Resolver) tapForwarded(scheme string, query *protocol.DnsPacket, response *protocol.DnsPacket, start time.Time) {
+	if v.cfg.Dnstap == nil {
+		return
+	}
+
+	rawQuery, err := query.ToRawBufferWithSize(buffer.MaxDNSBufferSize)
+	if err != nil {
+		return
+	}
+
+	v.cfg.Dnstap.Log(&dnstap.Message{
+		Type:         dnstap.FORWARDER_QUERY,
+		Protocol:     dnstapProtocols[scheme],
+		QueryTime:    start,
+		QueryMessage: rawQuery,
+	})
+
+	if response == nil {
+		return
+	}
+
+	rawResponse, err := response.ToRawBufferWithSize(buffer.MaxDNSBufferSize)
+	if err != nil {
+		return
+	}
+
+	v.cfg.Dnstap.Log(&dnstap.Message{
+		Type:            dnstap.FORWARDER_RESPONSE,
+		Protocol:        dnstapProtocols[scheme],
+		QueryTime:       start,
+		ResponseTime:    time.Now(),
+		QueryMessage:    rawQuery,
+		ResponseMessage: rawResponse,
+	})
+}
@@ -0,0 +1,96 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestZoneIsTakenFromAuthoritySOA(t *testing.T) {
+	upstream, anchor := newTestNetwork(t)
+	upstream.respond("www.insecure", common.AAAA, common.NOERROR, nil, []protocol.DnsRecord{testSOA(t, "insecure")})
+
+	response := resolveValidated(t, newTestResolver(upstream, anchor), "www.insecure", common.AAAA)
+
+	if response.Header.ResultCode != common.NOERROR || response.Header.AuthedData {
+		t.Errorf("expected insecure empty answer, got %s", response.Header.ResultCode.String())
+	}
+
+	if count := upstream.queryCount("www.insecure", common.SOA); count != 0 {
+		t.Errorf("expected the zone to be taken from the response, got %d SOA queries", count)
+	}
+}
+
+func TestZoneCutIsCached(t *testing.T) {
+	upstream, anchor := newTestNetwork(t)
+	resolver := newTestResolver(upstream, anchor)
+
+	for i := 0; i < 3; i++ {
+		response := resolveValidated(t, resolver, "www.insecure", common.A)
+
+		if response.Header.ResultCode != common.NOERROR {
+			t.Fatalf("expected NOERROR, got %s", response.Header.ResultCode.String())
+		}
+	}
+
+	if count := upstream.queryCount("www.insecure", common.SOA); count != 1 {
+		t.Errorf("expected the zone to be looked up once, got %d SOA queries", count)
+	}
+}
+
+func TestResponseOfUnknownZoneIsInsecure(t *testing.T) {
+	upstream, anchor := newTestNetwork(t)
+	upstream.respond("www.unknown", common.A, common.NOERROR, []protocol.DnsRecord{testA(t, "www.unknown")}, nil)
+	upstream.respond("www.unknown", common.SOA, common.NOERROR, nil, nil)
+
+	response := resolveValidated(t, newTestResolver(upstream, anchor), "www.unknown", common.A)
+
+	if response.Header.ResultCode != common.NOERROR || len(response.Answers) != 1 {
+		t.Errorf("expected the answer to be passed on, got %s", response.Header.ResultCode.String())
+	}
+
+	if response.Header.AuthedData {
+		t.Errorf("expected the answer to be insecure")
+	}
+}
+
+func TestStreamExchangeEndsWithContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	// the server accepts the connection, but never responds
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(exchangeTimeout)
+		}
+	}()
+
+	upstream, err := ParseUpstream("tcp://" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+
+	_, err = upstream.Exchange(ctx, buildQueryPacket("example.com", common.A, false))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the exchange to be cancelled, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the exchange to end when cancelled, it took %s", elapsed)
+	}
+}
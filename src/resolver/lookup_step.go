@@ -0,0 +1,40 @@
+package resolver
+
+import (
+	"context"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"time"
+)
+
+// LookupStep is a single query sent to the name server during the recursive lookup, e.g. the one which returned
+// a referral to the next zone
+type LookupStep struct {
+	Depth      int // 0 for the lookup of the queried name, increased for each nested lookup of glueless name server's address
+	Name       string
+	Type       common.QueryType
+	Nameserver string
+	Zone       string // zone the name server was queried as authoritative for, "" for the root
+	RTT        time.Duration
+	Response   *protocol.DnsPacket // nil if the exchange failed
+	Err        error
+}
+
+// lookupDepthKey is the context key of the depth of the lookup, increased for lookups of glueless name servers
+type lookupDepthKey struct{}
+
+func lookupDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(lookupDepthKey{}).(int)
+
+	return depth
+}
+
+// reportLookupStep passes the lookup's step to the observer, if the resolver has one
+func (v *Resolver) reportLookupStep(ctx context.Context, step LookupStep) {
+	if v.cfg.OnLookupStep == nil {
+		return
+	}
+
+	step.Depth = lookupDepth(ctx)
+	v.cfg.OnLookupStep(&step)
+}
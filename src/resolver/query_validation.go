@@ -0,0 +1,54 @@
+package resolver
+
+import (
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+)
+
+// ValidateQuery checks whether the query can be resolved, returning the result code the client should get otherwise
+// (or NOERROR if the query is valid)
+func ValidateQuery(query *protocol.DnsPacket) common.ResultCode {
+	if query.Header.IsResponse {
+		return common.FORMERR
+	}
+
+	// inverse queries are obsolete (RFC 3425) and server status requests were never specified
+	if query.Header.OPCODE != common.QUERY {
+		return common.NOTIMP
+	}
+
+	// the query must contain exactly one question (RFC 9619)
+	if query.Header.QuestionsCount != 1 || len(query.Questions) != 1 {
+		return common.FORMERR
+	}
+
+	question := query.Questions[0]
+
+	// OPT is a pseudo-record which can't be asked for (RFC 6891, section 6.1.1)
+	if question.QueryType == common.OPT {
+		return common.FORMERR
+	}
+
+	if countOPT(query.Resources) > 1 || countOPT(query.Answers) > 0 || countOPT(query.Authorities) > 0 {
+		return common.FORMERR
+	}
+
+	// only the Internet is recursively resolved, other classes (e.g. CHAOS) are not served
+	if question.Class != common.IN {
+		return common.REFUSED
+	}
+
+	return common.NOERROR
+}
+
+func countOPT(records []protocol.DnsRecord) int {
+	result := 0
+
+	for _, record := range records {
+		if record.GetType() == common.OPT {
+			result++
+		}
+	}
+
+	return result
+}
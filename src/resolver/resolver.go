@@ -1,84 +1,280 @@
 package resolver
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"github.com/wiktor-mazur/dns-go/src/buffer"
 	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/dnssec"
+	"github.com/wiktor-mazur/dns-go/src/dnstap"
+	"github.com/wiktor-mazur/dns-go/src/metrics"
 	"github.com/wiktor-mazur/dns-go/src/protocol"
-	"log"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"github.com/wiktor-mazur/dns-go/src/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
 	"net"
+	"time"
 )
 
+// ednsUDPPayloadSize is the UDP payload size advertised via EDNS, chosen to avoid IP fragmentation (DNS Flag Day 2020)
+const ednsUDPPayloadSize = 1232
+
+// exchangeTimeout is the maximum time of a single exchange with the upstream name server
+const exchangeTimeout = 5 * time.Second
+
 type Config struct {
 	InternetRootServer string
+	Upstreams          []Upstream // if set, queries are forwarded to them (in order of preference) instead of resolved from the root
+	DNSSECValidation   bool
+	TrustAnchors       []*dnssec.TrustAnchor
+	Dnstap             *dnstap.Tap            // optional, queries sent to name servers and upstreams are logged to it
+	OnLookupStep       func(step *LookupStep) // optional, called after each query sent to a name server when resolving from the root
 }
 
 type Resolver struct {
-	cfg Config
+	cfg             Config
+	keyCache        *cache
+	zoneCache       *cache // zones of names whose unsigned responses were forwarded
+	upstreamsHealth *upstreamsHealth
 }
 
 func New(cfg Config) *Resolver {
-	return &Resolver{cfg: cfg}
+	return &Resolver{
+		cfg:             cfg,
+		keyCache:        newCache("dnssec_keys", keyCacheSize),
+		zoneCache:       newCache("zones", zoneCacheSize),
+		upstreamsHealth: newUpstreamsHealth(cfg.Upstreams),
+	}
 }
 
-func (v *Resolver) ResolveQuery(query *protocol.DnsPacket) (*protocol.DnsPacket, error) {
+func (v *Resolver) ResolveQuery(ctx context.Context, query *protocol.DnsPacket) (*protocol.DnsPacket, error) {
+	resultCode := ValidateQuery(query)
+	if resultCode != common.NOERROR {
+		return v.QueryToErrResponse(query, resultCode), nil
+	}
+
 	responsePacket := protocol.NewDnsPacket()
 	responsePacket.Header.ID = query.Header.ID
 	responsePacket.Header.IsResponse = true
 	responsePacket.Header.RecursionDesired = true
 	responsePacket.Header.RecursionAvailable = true
 
-	if len(query.Questions) == 0 || query.Header.QuestionsCount == 0 {
-		responsePacket.Header.ResultCode = common.FORMERR
-		return responsePacket, nil
-	}
-
-	if query.Header.IsResponse {
-		responsePacket.Header.ResultCode = common.FORMERR
-		return responsePacket, nil
-	}
-
 	question := query.Questions[0]
 
-	lookup, err := v.LookupRecursive(query.Header.ID, question.Name, question.QueryType)
+	clientOPT := query.GetOPT()
+	dnssecOk := clientOPT != nil && clientOPT.IsDNSSECOk()
+
+	lookup, zone, err := v.lookupRecursive(ctx, query.Header.ID, question.Name, question.QueryType)
 	if err != nil {
 		return nil, err
 	}
 
-	responsePacket.Header.ResultCode = common.NOERROR
+	responsePacket.Header.CheckingDisabled = query.Header.CheckingDisabled
 	responsePacket.AddQuestion(question)
 
+	// OPT goes to the additional section after all other records, whatever the outcome
+	if clientOPT != nil {
+		defer responsePacket.AddResource(dns_record.NewOPT(ednsUDPPayloadSize, dnssecOk))
+	}
+
+	if v.cfg.DNSSECValidation && !query.Header.CheckingDisabled {
+		status, err := v.validateResponse(ctx, query.Header.ID, &question, lookup, zone)
+		if err != nil || status == dnssec.Bogus {
+			slog.Warn("DNSSEC validation failed", "id", query.Header.ID, "error", err)
+
+			responsePacket.Header.ResultCode = common.SERVFAIL
+			return responsePacket, nil
+		}
+
+		slog.Debug("DNSSEC validation result", "id", query.Header.ID, "status", status.String())
+
+		// AD bit is only set for clients that indicated they understand it (RFC 6840, section 5.8)
+		responsePacket.Header.AuthedData = status == dnssec.Secure && (dnssecOk || query.Header.AuthedData)
+	}
+
+	responsePacket.Header.ResultCode = lookup.Header.ResultCode
+
 	for _, v := range lookup.Answers {
-		log.Printf("[%d] Answer %s", query.Header.ID, v.CompactString())
-		responsePacket.AddAnswer(v)
+		if includeInResponse(v, question.QueryType, dnssecOk) {
+			slog.Debug("Answer", "id", query.Header.ID, "record", v.CompactString())
+			responsePacket.AddAnswer(v)
+		}
 	}
 
 	for _, v := range lookup.Authorities {
-		log.Printf("[%d] Authority %s", query.Header.ID, v.CompactString())
-		responsePacket.AddAuthority(v)
+		if includeInResponse(v, question.QueryType, dnssecOk) {
+			slog.Debug("Authority", "id", query.Header.ID, "record", v.CompactString())
+			responsePacket.AddAuthority(v)
+		}
 	}
 
 	for _, v := range lookup.Resources {
-		log.Printf("[%d] Resource %s", query.Header.ID, v.CompactString())
-		responsePacket.AddResource(v)
+		if includeInResponse(v, question.QueryType, dnssecOk) {
+			slog.Debug("Resource", "id", query.Header.ID, "record", v.CompactString())
+			responsePacket.AddResource(v)
+		}
 	}
 
 	return responsePacket, nil
 }
 
+// includeInResponse filters out upstream's OPT record and DNSSEC records not requested by the client (RFC 4035, section 3.2.1)
+func includeInResponse(record protocol.DnsRecord, qType common.QueryType, dnssecOk bool) bool {
+	switch record.GetType() {
+	case common.OPT:
+		return false
+	case common.RRSIG, common.NSEC, common.NSEC3:
+		return dnssecOk || record.GetType() == qType
+	default:
+		return true
+	}
+}
+
+// QueryToErrResponse builds an empty response with the given result code, echoing the query's header and question section
 func (v *Resolver) QueryToErrResponse(query *protocol.DnsPacket, err common.ResultCode) *protocol.DnsPacket {
-	response := *query
+	response := protocol.NewDnsPacket()
 
+	response.Header.ID = query.Header.ID
+	response.Header.OPCODE = query.Header.OPCODE
+	response.Header.RecursionDesired = query.Header.RecursionDesired
+	response.Header.CheckingDisabled = query.Header.CheckingDisabled
 	response.Header.IsResponse = true
 	response.Header.RecursionAvailable = true
 	response.Header.ResultCode = err
 
-	return &response
+	response.Questions = append(response.Questions, query.Questions...)
+
+	// responses to EDNS queries have to include OPT as well (RFC 6891, section 7)
+	if opt := query.GetOPT(); opt != nil {
+		response.AddResource(dns_record.NewOPT(ednsUDPPayloadSize, opt.IsDNSSECOk()))
+	}
+
+	return response
+}
+
+// Lookup sends the query to the name server, whose queries are counted in metrics under recursiveNameserverLabel
+func (v *Resolver) Lookup(ctx context.Context, qName string, qType common.QueryType, serverAddr *net.UDPAddr) (*protocol.DnsPacket, error) {
+	return v.lookup(ctx, qName, qType, serverAddr, recursiveNameserverLabel)
+}
+
+// lookup sends the query to the name server, labelled in metrics with the given name
+func (v *Resolver) lookup(ctx context.Context, qName string, qType common.QueryType, serverAddr *net.UDPAddr, nameserverLabel string) (*protocol.DnsPacket, error) {
+	_, span := tracing.Tracer().Start(ctx, "Lookup", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		tracing.Nameserver.String(serverAddr.IP.String()),
+		tracing.QueryName.String(qName),
+		tracing.QueryType.String(qType.String()),
+	))
+	defer span.End()
+
+	queryPacket := buildQueryPacket(qName, qType, v.cfg.DNSSECValidation)
+
+	queryBuf, err := queryPacket.ToRawBuffer()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	rawResponse, err := exchangeUDP(ctx, queryBuf, serverAddr)
+	responsePacket, err := v.observeLookup(span, queryBuf, rawResponse, err, serverAddr, nameserverLabel, dnstap.UDP, start)
+	if err != nil {
+		return nil, err
+	}
+
+	// truncated response is incomplete, so the query has to be repeated over TCP (RFC 7766, section 5)
+	if responsePacket.Header.TruncatedMessage {
+		slog.Debug("Response was truncated, retrying over TCP", "nameserver", serverAddr)
+		span.AddEvent("truncated response, retrying over TCP")
+
+		start = time.Now()
+		rawResponse, err = exchangeTCP(ctx, queryBuf, &net.TCPAddr{IP: serverAddr.IP, Port: serverAddr.Port})
+		responsePacket, err = v.observeLookup(span, queryBuf, rawResponse, err, serverAddr, nameserverLabel, dnstap.TCP, start)
+	}
+
+	return responsePacket, err
+}
+
+// observeLookup parses the raw response to the query sent to the name server, recording it in metrics, the span and dnstap
+func (v *Resolver) observeLookup(span trace.Span, query []byte, rawResponse []byte, err error, serverAddr *net.UDPAddr, nameserverLabel string, protocolUsed dnstap.SocketProtocol, start time.Time) (*protocol.DnsPacket, error) {
+	var response *protocol.DnsPacket
+	if err == nil {
+		response, err = protocol.DnsPacketFromRawBuffer(rawResponse)
+	}
+
+	transport := "udp"
+	if protocolUsed == dnstap.TCP {
+		transport = "tcp"
+	}
+
+	observeUpstreamQuery(nameserverLabel, transport, start, response, err)
+	traceExchange(span, start, response, err)
+
+	if v.cfg.Dnstap != nil {
+		v.cfg.Dnstap.Log(&dnstap.Message{
+			Type:         dnstap.RESOLVER_QUERY,
+			Protocol:     protocolUsed,
+			ResponseAddr: serverAddr,
+			QueryTime:    start,
+			QueryMessage: query,
+		})
+
+		if rawResponse != nil {
+			v.cfg.Dnstap.Log(&dnstap.Message{
+				Type:            dnstap.RESOLVER_RESPONSE,
+				Protocol:        protocolUsed,
+				ResponseAddr:    serverAddr,
+				QueryTime:       start,
+				ResponseTime:    time.Now(),
+				QueryMessage:    query,
+				ResponseMessage: rawResponse,
+			})
+		}
+	}
+
+	return response, err
 }
 
-func (v *Resolver) Lookup(qName string, qType common.QueryType, serverAddr *net.UDPAddr) (*protocol.DnsPacket, error) {
-	queryPacket := buildQueryPacket(qName, qType)
+// recursiveNameserverLabel stands in metrics for the name servers contacted during recursive resolution below TLDs,
+// as there's no bound on how many of them there are
+const recursiveNameserverLabel = "recursive"
+
+// nameserverLabel returns the label of the name server of the zone in metrics: servers of the root and TLDs, which
+// are a limited set, are labelled by their IP addresses, the others are all labelled recursiveNameserverLabel
+func nameserverLabel(zone string, serverAddr *net.UDPAddr) string {
+	if dnssec.LabelCount(zone) > 1 {
+		return recursiveNameserverLabel
+	}
+
+	return serverAddr.IP.String()
+}
+
+// observeUpstreamQuery records metrics of a single query sent to the name server
+func observeUpstreamQuery(nameserver string, transport string, start time.Time, response *protocol.DnsPacket, err error) {
+	result := "error"
+	if err == nil {
+		result = response.Header.ResultCode.String()
+	}
+
+	metrics.UpstreamQueries.WithLabelValues(nameserver, result).Inc()
+	metrics.UpstreamQueryDuration.WithLabelValues(transport).Observe(time.Since(start).Seconds())
+}
 
+// traceExchange records the result and round trip time of the exchange with the name server in its span
+func traceExchange(span trace.Span, start time.Time, response *protocol.DnsPacket, err error) {
+	span.SetAttributes(tracing.RTT.Float64(float64(time.Since(start).Microseconds()) / 1000))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+
+	span.SetAttributes(tracing.ResultCode.String(response.Header.ResultCode.String()))
+}
+
+func exchangeUDP(ctx context.Context, query []byte, serverAddr *net.UDPAddr) ([]byte, error) {
 	conn, err := net.DialUDP("udp", nil, serverAddr)
 	if err != nil {
 		return nil, err
@@ -86,55 +282,128 @@ func (v *Resolver) Lookup(qName string, qType common.QueryType, serverAddr *net.
 
 	defer conn.Close()
 
-	queryBuf, err := queryPacket.ToBuffer()
+	stop, err := setExchangeDeadline(ctx, conn)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = conn.Write(queryBuf.GetBytes())
+	defer stop()
+
+	_, err = conn.Write(query)
 	if err != nil {
-		return nil, err
+		return nil, contextErr(ctx, err)
 	}
 
-	buf := make([]byte, buffer.DNSBufferSize)
-	responseLength, _, err := conn.ReadFromUDP(buf)
+	buf := make([]byte, ednsUDPPayloadSize)
+
+	for {
+		responseLength, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, contextErr(ctx, err)
+		}
+
+		// responses with other IDs could be spoofed (or late responses to previous queries), so they're ignored
+		if responseLength >= 2 && bytes.Equal(buf[:2], query[:2]) {
+			return buf[:responseLength], nil
+		}
+	}
+}
+
+func exchangeTCP(ctx context.Context, query []byte, serverAddr *net.TCPAddr) ([]byte, error) {
+	dialer := &net.Dialer{Timeout: exchangeTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", serverAddr.String())
 	if err != nil {
 		return nil, err
 	}
 
-	responsePacket, err := protocol.DnsPacketFromRawBuffer(buf[:responseLength])
+	defer conn.Close()
+
+	return exchangeStream(ctx, conn, query)
+}
+
+// setExchangeDeadline limits the exchange over the connection to exchangeTimeout, or the context's deadline if it comes
+// first, and interrupts it once the context is cancelled. The returned function stops watching the context.
+func setExchangeDeadline(ctx context.Context, conn net.Conn) (func() bool, error) {
+	deadline := time.Now().Add(exchangeTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	err := conn.SetDeadline(deadline)
 	if err != nil {
 		return nil, err
 	}
 
-	return responsePacket, nil
+	return context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	}), nil
+}
+
+// contextErr returns the context's error if it interrupted the exchange, which then failed with a timeout
+func contextErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
 }
 
-func (v *Resolver) LookupRecursive(queryID uint16, qName string, qType common.QueryType) (*protocol.DnsPacket, error) {
+func (v *Resolver) LookupRecursive(ctx context.Context, queryID uint16, qName string, qType common.QueryType) (*protocol.DnsPacket, error) {
+	response, _, err := v.lookupRecursive(ctx, queryID, qName, qType)
+
+	return response, err
+}
+
+// lookupRecursive performs the recursive lookup, returning also the zone of the name server which gave the final response
+func (v *Resolver) lookupRecursive(ctx context.Context, queryID uint16, qName string, qType common.QueryType) (*protocol.DnsPacket, string, error) {
+	// lookups made on the way (e.g. of glueless name servers or DNSSEC keys) are nested in the span of the one needing them
+	ctx, span := tracing.Tracer().Start(ctx, "LookupRecursive", trace.WithAttributes(
+		tracing.QueryName.String(qName),
+		tracing.QueryType.String(qType.String()),
+	))
+	defer span.End()
+
+	if len(v.cfg.Upstreams) > 0 {
+		return v.forward(ctx, queryID, qName, qType)
+	}
+
 	ns := v.cfg.InternetRootServer
+	zone := ""
 
 	// @TODO: check max iterations and max recursive depth
 	for {
-		log.Printf("[%d] Attempting lookup of %s %s with ns %s", queryID, qType.String(), qName, ns)
+		slog.Debug("Attempting lookup", "id", queryID, "name", qName, "type", qType.String(), "nameserver", ns)
 
 		serverAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", ns, 53))
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
-		response, err := v.Lookup(qName, qType, serverAddr)
+		start := time.Now()
+		response, err := v.lookup(ctx, qName, qType, serverAddr, nameserverLabel(zone, serverAddr))
+		v.reportLookupStep(ctx, LookupStep{
+			Name:       qName,
+			Type:       qType,
+			Nameserver: ns,
+			Zone:       zone,
+			RTT:        time.Since(start),
+			Response:   response,
+			Err:        err,
+		})
+
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		isFinalResultFound := len(response.Answers) > 0 && response.Header.ResultCode == common.NOERROR
 		if isFinalResultFound {
-			return response, nil
+			return response, zone, nil
 		}
 
 		nameNotExists := response.Header.ResultCode == common.NXDOMAIN
 		if nameNotExists {
-			return response, nil
+			return response, zone, nil
 		}
 
 		// DNS server included name server's A record in the additional section, so we already have the IP
@@ -142,6 +411,7 @@ func (v *Resolver) LookupRecursive(queryID uint16, qName string, qType common.Qu
 		if resolvedNS != nil {
 			newNsIP := resolvedNS.GetIP()
 			ns = newNsIP.String()
+			zone = response.GetUnresolvedNS(qName).GetName()
 
 			continue
 		}
@@ -150,12 +420,12 @@ func (v *Resolver) LookupRecursive(queryID uint16, qName string, qType common.Qu
 		unresolvedNS := response.GetUnresolvedNS(qName)
 		if unresolvedNS == nil {
 			// we didn't get any name servers, so we return what we have
-			return response, nil
+			return response, zone, nil
 		}
 
-		recursiveResponse, err := v.LookupRecursive(queryID, unresolvedNS.GetHost(), common.A)
+		recursiveResponse, err := v.LookupRecursive(context.WithValue(ctx, lookupDepthKey{}, lookupDepth(ctx)+1), queryID, unresolvedNS.GetHost(), common.A)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		newNS := recursiveResponse.GetFirstARecord()
@@ -164,14 +434,15 @@ func (v *Resolver) LookupRecursive(queryID uint16, qName string, qType common.Qu
 			// continue search with next NS
 			newNsIP := newNS.GetIP()
 			ns = newNsIP.String()
+			zone = unresolvedNS.GetName()
 		} else {
 			// there are no A records, we return what we have
-			return response, nil
+			return response, zone, nil
 		}
 	}
 }
 
-func buildQueryPacket(qName string, qType common.QueryType) *protocol.DnsPacket {
+func buildQueryPacket(qName string, qType common.QueryType, dnssecOk bool) *protocol.DnsPacket {
 	result := protocol.NewDnsPacket()
 	result.Header.RecursionDesired = true
 
@@ -180,6 +451,7 @@ func buildQueryPacket(qName string, qType common.QueryType) *protocol.DnsPacket
 	question.QueryType = qType
 
 	result.AddQuestion(*question)
+	result.AddResource(dns_record.NewOPT(ednsUDPPayloadSize, dnssecOk))
 
 	return result
 }
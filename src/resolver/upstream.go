@@ -0,0 +1,307 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxIdleConns is the number of connections kept open to a single upstream, so that they can be reused by next queries
+const maxIdleConns = 4
+
+// Upstream is a name server the queries are forwarded to. Exchange ends when the context is done, or after exchangeTimeout.
+type Upstream interface {
+	Exchange(ctx context.Context, query *protocol.DnsPacket) (*protocol.DnsPacket, error)
+	String() string
+}
+
+/*
+ * ParseUpstream creates the upstream given its URL, whose scheme selects the transport:
+ * - udp://ip[:port] (port 53 by default), retried over TCP when the response is truncated
+ * - tcp://ip[:port] (port 53 by default)
+ * - tls://host[:port] (port 853 by default), DNS-over-TLS (RFC 7858)
+ * - https://host[:port]/path, DNS-over-HTTPS (RFC 8484)
+ * Server's certificate is verified against the host, or the "server_name" query parameter if the host is an IP address
+ * whose certificate doesn't include it (e.g. tls://9.9.9.9?server_name=dns.quad9.net).
+ */
+func ParseUpstream(value string) (Upstream, error) {
+	upstreamURL, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if upstreamURL.Hostname() == "" {
+		return nil, fmt.Errorf("upstream %q has no host", value)
+	}
+
+	serverName := upstreamURL.Query().Get("server_name")
+	if serverName == "" {
+		serverName = upstreamURL.Hostname()
+	}
+
+	tlsConfig := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+
+	switch upstreamURL.Scheme {
+	case "udp":
+		addr, err := net.ResolveUDPAddr("udp", hostPort(upstreamURL, 53))
+		if err != nil {
+			return nil, err
+		}
+
+		return &udpUpstream{addr: addr}, nil
+	case "tcp":
+		return newStreamUpstream(hostPort(upstreamURL, 53), nil), nil
+	case "tls":
+		tlsConfig.NextProtos = []string{"dot"}
+
+		return newStreamUpstream(hostPort(upstreamURL, 853), tlsConfig), nil
+	case "https":
+		return newHTTPSUpstream(upstreamURL, tlsConfig), nil
+	default:
+		return nil, fmt.Errorf("unsupported upstream scheme %q", upstreamURL.Scheme)
+	}
+}
+
+func hostPort(upstreamURL *url.URL, defaultPort int) string {
+	port := upstreamURL.Port()
+	if port == "" {
+		port = strconv.Itoa(defaultPort)
+	}
+
+	return net.JoinHostPort(upstreamURL.Hostname(), port)
+}
+
+type udpUpstream struct {
+	addr *net.UDPAddr
+}
+
+func (v *udpUpstream) Exchange(ctx context.Context, query *protocol.DnsPacket) (*protocol.DnsPacket, error) {
+	queryBuf, err := query.ToRawBuffer()
+	if err != nil {
+		return nil, err
+	}
+
+	rawResponse, err := exchangeUDP(ctx, queryBuf, v.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := protocol.DnsPacketFromRawBuffer(rawResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Header.TruncatedMessage {
+		rawResponse, err = exchangeTCP(ctx, queryBuf, &net.TCPAddr{IP: v.addr.IP, Port: v.addr.Port})
+		if err != nil {
+			return nil, err
+		}
+
+		return protocol.DnsPacketFromRawBuffer(rawResponse)
+	}
+
+	return response, nil
+}
+
+func (v *udpUpstream) String() string {
+	return "udp://" + v.addr.String()
+}
+
+// streamUpstream sends queries over TCP or TLS, reusing the connections (RFC 7766, section 6.2.1)
+type streamUpstream struct {
+	addr      string
+	tlsConfig *tls.Config
+	idle      chan net.Conn
+}
+
+func newStreamUpstream(addr string, tlsConfig *tls.Config) *streamUpstream {
+	return &streamUpstream{addr: addr, tlsConfig: tlsConfig, idle: make(chan net.Conn, maxIdleConns)}
+}
+
+func (v *streamUpstream) Exchange(ctx context.Context, query *protocol.DnsPacket) (*protocol.DnsPacket, error) {
+	queryBuf, err := query.ToRawBufferWithSize(buffer.MaxDNSBufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, reused, err := v.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := exchangeStream(ctx, conn, queryBuf)
+
+	// the upstream may have closed the idle connection in the meantime, so the query is repeated with a new one
+	if err != nil && reused && ctx.Err() == nil {
+		conn.Close()
+
+		conn, err = v.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err = exchangeStream(ctx, conn, queryBuf)
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	v.putConn(conn)
+
+	return protocol.DnsPacketFromRawBuffer(response)
+}
+
+func (v *streamUpstream) getConn(ctx context.Context) (net.Conn, bool, error) {
+	select {
+	case conn := <-v.idle:
+		return conn, true, nil
+	default:
+		conn, err := v.dial(ctx)
+
+		return conn, false, err
+	}
+}
+
+func (v *streamUpstream) putConn(conn net.Conn) {
+	select {
+	case v.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (v *streamUpstream) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: exchangeTimeout}
+
+	if v.tlsConfig != nil {
+		return (&tls.Dialer{NetDialer: dialer, Config: v.tlsConfig}).DialContext(ctx, "tcp", v.addr)
+	}
+
+	return dialer.DialContext(ctx, "tcp", v.addr)
+}
+
+func (v *streamUpstream) String() string {
+	if v.tlsConfig != nil {
+		return "tls://" + v.addr
+	}
+
+	return "tcp://" + v.addr
+}
+
+func exchangeStream(ctx context.Context, conn net.Conn, query []byte) ([]byte, error) {
+	stop, err := setExchangeDeadline(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stop()
+
+	err = protocol.WriteTCPMessage(conn, query)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	response, err := protocol.ReadTCPMessage(conn)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	if !bytes.Equal(response[:min(2, len(response))], query[:2]) {
+		return nil, errors.New("response ID doesn't match the query")
+	}
+
+	return response, nil
+}
+
+// httpsUpstream sends queries with POST requests over HTTP/2 connections, which are kept open by the client
+type httpsUpstream struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPSUpstream(upstreamURL *url.URL, tlsConfig *tls.Config) *httpsUpstream {
+	// server_name parameter is only meant for dns-go
+	query := upstreamURL.Query()
+	query.Del("server_name")
+
+	requestURL := *upstreamURL
+	requestURL.RawQuery = query.Encode()
+
+	return &httpsUpstream{
+		url: requestURL.String(),
+		client: &http.Client{
+			Timeout: exchangeTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig:     tlsConfig,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConnsPerHost: maxIdleConns,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+}
+
+func (v *httpsUpstream) Exchange(ctx context.Context, query *protocol.DnsPacket) (*protocol.DnsPacket, error) {
+	// ID is always 0, so that HTTP caches can reuse the responses (RFC 8484, section 4.1)
+	cacheableQuery := *query
+	cacheableQuery.Header.ID = 0
+
+	queryBuf, err := cacheableQuery.ToRawBufferWithSize(buffer.MaxDNSBufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, bytes.NewReader(queryBuf))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/dns-message")
+	request.Header.Set("Accept", "application/dns-message")
+
+	httpResponse, err := v.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream responded with HTTP status %d", httpResponse.StatusCode)
+	}
+
+	if !strings.HasPrefix(httpResponse.Header.Get("Content-Type"), "application/dns-message") {
+		return nil, fmt.Errorf("upstream responded with unexpected content type %q", httpResponse.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(httpResponse.Body, buffer.MaxDNSBufferSize))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := protocol.DnsPacketFromRawBuffer(body)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Header.ID = query.Header.ID
+
+	return response, nil
+}
+
+func (v *httpsUpstream) String() string {
+	return v.url
+}
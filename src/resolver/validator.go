@@ -0,0 +1,471 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/dnssec"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"log/slog"
+	"time"
+)
+
+const (
+	keyCacheSize       = 1000
+	maxKeyCacheTTL     = 24 * time.Hour
+	defaultKeyCacheTTL = 5 * time.Minute
+
+	// failedKeyCacheTTL is how long the failure to establish the chain of trust is remembered, so that queries for
+	// a bogus zone don't fetch its keys again each time (RFC 9520 limits it to 5 minutes)
+	failedKeyCacheTTL = time.Minute
+)
+
+// denialKind is what the authenticated denial of existence has to prove
+type denialKind int
+
+const (
+	noDataDenial             denialKind = iota // the name exists, but has no records of the given type
+	nameErrorDenial                            // the name doesn't exist
+	unsignedDelegationDenial                   // the name is a delegation without DS records
+)
+
+// zoneKeys holds the security status of a zone and, for secure zones, its validated DNSKEY records. If the chain
+// of trust couldn't be established, it holds the error instead.
+type zoneKeys struct {
+	status dnssec.Status
+	keys   []*dns_record.DNSKEY
+	err    error
+}
+
+// validateResponse checks the final response of a recursive lookup, performed by the name server authoritative for the given zone
+func (v *Resolver) validateResponse(ctx context.Context, queryID uint16, question *protocol.DnsQuestion, response *protocol.DnsPacket, zone string) (dnssec.Status, error) {
+	if zone == unknownZone {
+		return dnssec.Insecure, nil
+	}
+
+	answerSets := dnssec.GroupRRSets(response.Answers)
+	authoritySets := dnssec.GroupRRSets(response.Authorities)
+
+	switch {
+	case response.Header.ResultCode == common.NOERROR && len(answerSets) > 0:
+		return v.validateAnswer(ctx, queryID, answerSets, authoritySets, zone)
+	case response.Header.ResultCode == common.NOERROR || response.Header.ResultCode == common.NXDOMAIN:
+		kind := noDataDenial
+		if response.Header.ResultCode == common.NXDOMAIN {
+			kind = nameErrorDenial
+		}
+
+		return v.validateDenial(ctx, queryID, question, kind, authoritySets, zone)
+	default:
+		return dnssec.Indeterminate, nil
+	}
+}
+
+func (v *Resolver) validateAnswer(ctx context.Context, queryID uint16, answerSets []*dnssec.RRSet, authoritySets []*dnssec.RRSet, zone string) (dnssec.Status, error) {
+	result := dnssec.Secure
+
+	for _, set := range answerSets {
+		signer, err := answerSigner(set, zone)
+		if err != nil {
+			return dnssec.Bogus, err
+		}
+
+		zoneKeys, err := v.getZoneKeys(ctx, queryID, signer)
+		if err != nil {
+			return dnssec.Bogus, err
+		}
+
+		if zoneKeys.status != dnssec.Secure {
+			result = dnssec.Insecure
+			continue
+		}
+
+		rrsig, err := dnssec.VerifyRRSet(set, zoneKeys.keys, time.Now())
+		if err != nil {
+			return dnssec.Bogus, err
+		}
+
+		// answer was synthesized from a wildcard, so there must be a proof that the exact match doesn't exist
+		if int(rrsig.GetLabels()) < dnssec.LabelCount(set.Name) {
+			closestEncloser := dnssec.AncestorName(set.Name, int(rrsig.GetLabels()))
+
+			err = v.verifyWildcardAnswer(authoritySets, zoneKeys.keys, set.Name, closestEncloser)
+			if err != nil {
+				return dnssec.Bogus, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (v *Resolver) validateDenial(ctx context.Context, queryID uint16, question *protocol.DnsQuestion, kind denialKind, authoritySets []*dnssec.RRSet, zone string) (dnssec.Status, error) {
+	signer := zone
+
+	for _, set := range authoritySets {
+		if len(set.Signatures) > 0 {
+			signer = set.Signatures[0].GetSignerName()
+			break
+		}
+	}
+
+	if !dnssec.IsSubdomain(question.Name, signer) || !dnssec.IsSubdomain(signer, zone) {
+		return dnssec.Bogus, fmt.Errorf("denial of %s is signed by %s, which is not its zone", question.Name, displayName(signer))
+	}
+
+	zoneKeys, err := v.getZoneKeys(ctx, queryID, signer)
+	if err != nil {
+		return dnssec.Bogus, err
+	}
+
+	if zoneKeys.status != dnssec.Secure {
+		return zoneKeys.status, nil
+	}
+
+	return verifyAuthenticatedDenial(authoritySets, zoneKeys.keys, question.Name, question.QueryType, kind)
+}
+
+// answerSigner returns the zone whose keys validate the RRset. Signer name of RRSIG records is checked, otherwise
+// a forged signature could point to any provably insecure zone and get the RRset accepted as insecure: it has to be
+// the set's ancestor, at or below the zone which answered, unless the set is a CNAME target outside of that zone
+func answerSigner(set *dnssec.RRSet, zone string) (string, error) {
+	inZone := dnssec.IsSubdomain(set.Name, zone)
+
+	if len(set.Signatures) == 0 {
+		// zone of the out-of-zone CNAME target isn't known, so its unsigned records can't be proven insecure
+		if !inZone {
+			return "", fmt.Errorf("unsigned %s %s is outside of zone %s", set.Name, set.Type.String(), displayName(zone))
+		}
+
+		return zone, nil
+	}
+
+	signer := set.Signatures[0].GetSignerName()
+
+	if !dnssec.IsSubdomain(set.Name, signer) || (inZone && !dnssec.IsSubdomain(signer, zone)) {
+		return "", fmt.Errorf("%s %s is signed by %s, which is not its zone", set.Name, set.Type.String(), displayName(signer))
+	}
+
+	return signer, nil
+}
+
+// verifyAuthenticatedDenial checks signatures of NSEC/NSEC3 records from the authority section and whether they prove
+// that the name (or records of the given type) don't exist
+func verifyAuthenticatedDenial(authoritySets []*dnssec.RRSet, keys []*dns_record.DNSKEY, qName string, qType common.QueryType, kind denialKind) (dnssec.Status, error) {
+	nsecs, nsec3s, err := verifiedDenialRecords(authoritySets, keys)
+	if err != nil {
+		return dnssec.Bogus, err
+	}
+
+	if len(nsecs) > 0 {
+		switch kind {
+		case nameErrorDenial:
+			err = dnssec.VerifyNSECNameError(nsecs, qName)
+		case unsignedDelegationDenial:
+			err = dnssec.VerifyNSECUnsignedDelegation(nsecs, qName)
+		default:
+			err = dnssec.VerifyNSECNoData(nsecs, qName, qType)
+		}
+
+		if err != nil {
+			return dnssec.Bogus, err
+		}
+
+		return dnssec.Secure, nil
+	}
+
+	if len(nsec3s) > 0 {
+		var optOut bool
+
+		switch kind {
+		case nameErrorDenial:
+			optOut, err = dnssec.VerifyNSEC3NameError(nsec3s, qName)
+		case unsignedDelegationDenial:
+			optOut, err = dnssec.VerifyNSEC3UnsignedDelegation(nsec3s, qName)
+		default:
+			optOut, err = dnssec.VerifyNSEC3NoData(nsec3s, qName, qType)
+		}
+
+		if err == dnssec.ErrNSEC3IterationsTooHigh {
+			return dnssec.Insecure, nil
+		}
+
+		if err != nil {
+			return dnssec.Bogus, err
+		}
+
+		if optOut {
+			return dnssec.Insecure, nil
+		}
+
+		return dnssec.Secure, nil
+	}
+
+	return dnssec.Bogus, fmt.Errorf("no NSEC or NSEC3 records proving that %s %s doesn't exist", qName, qType.String())
+}
+
+func (v *Resolver) verifyWildcardAnswer(authoritySets []*dnssec.RRSet, keys []*dns_record.DNSKEY, name string, closestEncloser string) error {
+	nsecs, nsec3s, err := verifiedDenialRecords(authoritySets, keys)
+	if err != nil {
+		return err
+	}
+
+	if len(nsecs) > 0 {
+		return dnssec.VerifyNSECWildcardAnswer(nsecs, name)
+	}
+
+	if len(nsec3s) > 0 {
+		return dnssec.VerifyNSEC3WildcardAnswer(nsec3s, name, closestEncloser)
+	}
+
+	return fmt.Errorf("no NSEC or NSEC3 records proving wildcard expansion of %s", name)
+}
+
+func verifiedDenialRecords(sets []*dnssec.RRSet, keys []*dns_record.DNSKEY) ([]*dns_record.NSEC, []*dns_record.NSEC3, error) {
+	nsecs := make([]*dns_record.NSEC, 0)
+	nsec3s := make([]*dns_record.NSEC3, 0)
+
+	for _, set := range sets {
+		if set.Type != common.NSEC && set.Type != common.NSEC3 {
+			continue
+		}
+
+		_, err := dnssec.VerifyRRSet(set, keys, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, record := range set.Records {
+			switch r := record.(type) {
+			case *dns_record.NSEC:
+				nsecs = append(nsecs, r)
+			case *dns_record.NSEC3:
+				nsec3s = append(nsec3s, r)
+			}
+		}
+	}
+
+	return nsecs, nsec3s, nil
+}
+
+// getZoneKeys builds the chain of trust from the trust anchor to the zone and returns its validated keys
+func (v *Resolver) getZoneKeys(ctx context.Context, queryID uint16, zone string) (*zoneKeys, error) {
+	zone = dnssec.CanonicalName(zone)
+
+	cached, ok := v.keyCache.get(zone)
+	if ok {
+		result := cached.(*zoneKeys)
+		if result.err != nil {
+			return nil, result.err
+		}
+
+		return result, nil
+	}
+
+	var result *zoneKeys
+	var ttl uint32
+	var err error
+
+	anchors := v.getTrustAnchors(zone)
+
+	if len(anchors) > 0 {
+		result, ttl, err = v.getAnchoredKeys(ctx, queryID, zone, anchors)
+	} else if zone == "" {
+		err = fmt.Errorf("no trust anchor configured for the root zone")
+	} else {
+		result, ttl, err = v.getDelegatedKeys(ctx, queryID, zone)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("could not establish the chain of trust for %s: %s", displayName(zone), err.Error())
+
+		// the client giving up says nothing about the zone
+		if ctx.Err() == nil {
+			v.keyCache.set(zone, &zoneKeys{status: dnssec.Bogus, err: err}, failedKeyCacheTTL)
+		}
+
+		return nil, err
+	}
+
+	slog.Debug("Zone validated", "id", queryID, "zone", displayName(zone), "status", result.status.String())
+
+	v.keyCache.set(zone, result, keyCacheTTL(ttl))
+
+	return result, nil
+}
+
+// getAnchoredKeys fetches DNSKEY records of the zone and validates them with the key signing keys pointed by DS records (or trust anchors)
+func (v *Resolver) getAnchoredKeys(ctx context.Context, queryID uint16, zone string, delegations []dnssec.Delegation) (*zoneKeys, uint32, error) {
+	response, err := v.LookupRecursive(ctx, queryID, zone, common.DNSKEY)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keySet := dnssec.FindRRSet(dnssec.GroupRRSets(response.Answers), zone, common.DNSKEY)
+	if keySet == nil {
+		return nil, 0, fmt.Errorf("no DNSKEY records found")
+	}
+
+	keys := make([]*dns_record.DNSKEY, 0, len(keySet.Records))
+	entryPoints := make([]*dns_record.DNSKEY, 0)
+
+	for _, record := range keySet.Records {
+		key, ok := record.(*dns_record.DNSKEY)
+		if !ok {
+			continue
+		}
+
+		keys = append(keys, key)
+
+		for _, delegation := range delegations {
+			if dnssec.MatchesDelegation(key, delegation) {
+				entryPoints = append(entryPoints, key)
+				break
+			}
+		}
+	}
+
+	if len(entryPoints) == 0 {
+		return nil, 0, fmt.Errorf("none of DNSKEY records matches DS records")
+	}
+
+	_, err = dnssec.VerifyRRSet(keySet, entryPoints, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &zoneKeys{status: dnssec.Secure, keys: keys}, keySet.GetTTL(), nil
+}
+
+// getDelegatedKeys validates DS records of the zone with its parent's keys and uses them to validate the zone's keys,
+// or proves that the zone is insecure
+func (v *Resolver) getDelegatedKeys(ctx context.Context, queryID uint16, zone string) (*zoneKeys, uint32, error) {
+	response, answeringZone, err := v.lookupRecursive(ctx, queryID, zone, common.DS)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dsSet := dnssec.FindRRSet(dnssec.GroupRRSets(response.Answers), zone, common.DS)
+	authoritySets := dnssec.GroupRRSets(response.Authorities)
+
+	parentKeys, err := v.getZoneKeys(ctx, queryID, findParentZone(zone, answeringZone, dsSet, authoritySets))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if parentKeys.status != dnssec.Secure {
+		return &zoneKeys{status: parentKeys.status}, 0, nil
+	}
+
+	if dsSet == nil {
+		if response.Header.ResultCode != common.NOERROR {
+			return nil, 0, fmt.Errorf("DS lookup failed with %s", response.Header.ResultCode.String())
+		}
+
+		status, err := verifyAuthenticatedDenial(authoritySets, parentKeys.keys, zone, common.DS, unsignedDelegationDenial)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if status == dnssec.Secure {
+			// the delegation is provably unsigned
+			status = dnssec.Insecure
+		}
+
+		return &zoneKeys{status: status}, minTTL(authoritySets), nil
+	}
+
+	_, err = dnssec.VerifyRRSet(dsSet, parentKeys.keys, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	delegations := make([]dnssec.Delegation, 0)
+
+	for _, record := range dsSet.Records {
+		ds, ok := record.(*dns_record.DS)
+
+		if ok && dnssec.IsDelegationSupported(ds) {
+			delegations = append(delegations, ds)
+		}
+	}
+
+	// zone signed only with unsupported algorithms is treated as insecure (RFC 4035, section 5.2)
+	if len(delegations) == 0 {
+		return &zoneKeys{status: dnssec.Insecure}, dsSet.GetTTL(), nil
+	}
+
+	return v.getAnchoredKeys(ctx, queryID, zone, delegations)
+}
+
+func (v *Resolver) getTrustAnchors(zone string) []dnssec.Delegation {
+	result := make([]dnssec.Delegation, 0)
+
+	for _, anchor := range v.cfg.TrustAnchors {
+		if dnssec.EqualNames(anchor.Zone, zone) {
+			result = append(result, anchor)
+		}
+	}
+
+	return result
+}
+
+// findParentZone determines the zone holding the delegation, preferring the signer of DS or denial records
+func findParentZone(zone string, answeringZone string, dsSet *dnssec.RRSet, authoritySets []*dnssec.RRSet) string {
+	isParent := func(name string) bool {
+		return dnssec.IsSubdomain(zone, name) && !dnssec.EqualNames(zone, name)
+	}
+
+	sets := authoritySets
+	if dsSet != nil {
+		sets = []*dnssec.RRSet{dsSet}
+	}
+
+	for _, set := range sets {
+		for _, rrsig := range set.Signatures {
+			if isParent(rrsig.GetSignerName()) {
+				return rrsig.GetSignerName()
+			}
+		}
+	}
+
+	if isParent(answeringZone) {
+		return answeringZone
+	}
+
+	return dnssec.ParentName(zone)
+}
+
+func minTTL(sets []*dnssec.RRSet) uint32 {
+	var result uint32
+
+	for idx, set := range sets {
+		if idx == 0 || set.GetTTL() < result {
+			result = set.GetTTL()
+		}
+	}
+
+	return result
+}
+
+func keyCacheTTL(ttl uint32) time.Duration {
+	if ttl == 0 {
+		return defaultKeyCacheTTL
+	}
+
+	result := time.Duration(ttl) * time.Second
+	if result > maxKeyCacheTTL {
+		return maxKeyCacheTTL
+	}
+
+	return result
+}
+
+func displayName(name string) string {
+	if name == "" {
+		return "."
+	}
+
+	return name
+}
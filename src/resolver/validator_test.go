@@ -0,0 +1,483 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/dnssec"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTTL = 3600
+
+// testKey is a key signing the records of a test zone
+type testKey struct {
+	dnskey *dns_record.DNSKEY
+	sign   func(data []byte) ([]byte, error)
+}
+
+func newECDSAKey(t *testing.T, zone string) *testKey {
+	t.Helper()
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// public key and signature are encoded as x|y and r|s (RFC 6605, section 4)
+	publicKey := make([]byte, 64)
+	private.PublicKey.X.FillBytes(publicKey[:32])
+	private.PublicKey.Y.FillBytes(publicKey[32:])
+
+	return &testKey{
+		dnskey: newTestDNSKEY(t, zone, common.ECDSAP256SHA256, publicKey),
+		sign: func(data []byte) ([]byte, error) {
+			digest := sha256.Sum256(data)
+
+			r, s, err := ecdsa.Sign(rand.Reader, private, digest[:])
+			if err != nil {
+				return nil, err
+			}
+
+			signature := make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+
+			return signature, nil
+		},
+	}
+}
+
+func newEd25519Key(t *testing.T, zone string) *testKey {
+	t.Helper()
+
+	publicKey, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testKey{
+		dnskey: newTestDNSKEY(t, zone, common.ED25519, publicKey),
+		sign: func(data []byte) ([]byte, error) {
+			return private.Sign(rand.Reader, data, crypto.Hash(0))
+		},
+	}
+}
+
+func newTestDNSKEY(t *testing.T, zone string, algorithm common.DNSSECAlgorithm, publicKey []byte) *dns_record.DNSKEY {
+	t.Helper()
+
+	flags := dns_record.DNSKEYFlagZoneKey | dns_record.DNSKEYFlagSecureEntryPoint
+
+	result, err := dns_record.NewDNSKEY(zone, testTTL, flags, algorithm, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+// ds returns the DS record pointing to the key
+func (v *testKey) ds(t *testing.T) *dns_record.DS {
+	t.Helper()
+
+	digest, err := dnssec.KeyDigest(v.dnskey, common.DIGEST_SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := dns_record.NewDS(v.dnskey.GetName(), testTTL, v.dnskey.GetKeyTag(), v.dnskey.GetAlgorithm(), common.DIGEST_SHA256, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+// signWithValidity returns the RRset along with its RRSIG made by the key, valid in the given period
+func (v *testKey) signWithValidity(t *testing.T, inception time.Time, expiration time.Time, records ...protocol.DnsRecord) []protocol.DnsRecord {
+	t.Helper()
+
+	first := records[0]
+	newRRSIG := func(signature []byte) *dns_record.RRSIG {
+		rrsig, err := dns_record.NewRRSIG(first.GetName(), first.GetTTL(), first.GetType(), v.dnskey.GetAlgorithm(),
+			uint8(dnssec.LabelCount(first.GetName())), first.GetTTL(), uint32(expiration.Unix()), uint32(inception.Unix()),
+			v.dnskey.GetKeyTag(), v.dnskey.GetName(), signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return rrsig
+	}
+
+	// the data covered by the signature is built here independently of the validator (RFC 4034, section 3.1.8.1)
+	buf := buffer.NewBytePacketBufferWithSize(buffer.MaxDNSBufferSize)
+
+	err := newRRSIG(nil).WriteSignedData(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rDatas := make([][]byte, 0, len(records))
+
+	for _, record := range records {
+		rData, err := dns_record.CanonicalRData(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rDatas = append(rDatas, rData)
+	}
+
+	sort.Slice(rDatas, func(i, j int) bool { return bytes.Compare(rDatas[i], rDatas[j]) < 0 })
+
+	for _, rData := range rDatas {
+		for _, err := range []error{
+			buf.WriteLabel(strings.ToLower(first.GetName())),
+			buf.WriteUint16(uint16(first.GetType())),
+			buf.WriteUint16(uint16(first.GetClass())),
+			buf.WriteUint32(first.GetTTL()),
+			buf.WriteUint16(uint16(len(rData))),
+			buf.WriteBytes(rData),
+		} {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	signature, err := v.sign(buf.GetBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(records, newRRSIG(signature))
+}
+
+// signed returns the RRset along with its RRSIG made by the key, which is currently valid
+func (v *testKey) signed(t *testing.T, records ...protocol.DnsRecord) []protocol.DnsRecord {
+	t.Helper()
+
+	return v.signWithValidity(t, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), records...)
+}
+
+// testUpstream answers the queries forwarded to it with the responses prepared for their questions
+type testUpstream struct {
+	t         *testing.T
+	mu        sync.Mutex
+	responses map[string]*protocol.DnsPacket
+	queries   map[string]int
+}
+
+func newTestUpstream(t *testing.T) *testUpstream {
+	return &testUpstream{t: t, responses: make(map[string]*protocol.DnsPacket), queries: make(map[string]int)}
+}
+
+func testQuestionKey(qName string, qType common.QueryType) string {
+	return fmt.Sprintf("%s %s", dnssec.CanonicalName(qName), qType.String())
+}
+
+// respond prepares the response to the question, with the records of answer and authority sections
+func (v *testUpstream) respond(qName string, qType common.QueryType, resultCode common.ResultCode, answers []protocol.DnsRecord, authorities []protocol.DnsRecord) {
+	response := protocol.NewDnsPacket()
+	response.Header.ResultCode = resultCode
+	response.Answers = answers
+	response.Authorities = authorities
+
+	v.responses[testQuestionKey(qName, qType)] = response
+}
+
+func (v *testUpstream) queryCount(qName string, qType common.QueryType) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.queries[testQuestionKey(qName, qType)]
+}
+
+func (v *testUpstream) Exchange(ctx context.Context, query *protocol.DnsPacket) (*protocol.DnsPacket, error) {
+	question := query.Questions[0]
+	key := testQuestionKey(question.Name, question.QueryType)
+
+	v.mu.Lock()
+	v.queries[key]++
+	prepared, ok := v.responses[key]
+	v.mu.Unlock()
+
+	if !ok {
+		v.t.Errorf("unexpected query for %s", key)
+		return nil, fmt.Errorf("no response prepared for %s", key)
+	}
+
+	response := protocol.NewDnsPacket()
+	response.Header.ID = query.Header.ID
+	response.Header.IsResponse = true
+	response.Header.RecursionAvailable = true
+	response.Header.ResultCode = prepared.Header.ResultCode
+	response.AddQuestion(question)
+
+	for _, record := range prepared.Answers {
+		response.AddAnswer(record)
+	}
+
+	for _, record := range prepared.Authorities {
+		response.AddAuthority(record)
+	}
+
+	// the response goes through the wire format, like the one of a real upstream
+	rawResponse, err := response.ToRawBufferWithSize(buffer.MaxDNSBufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return protocol.DnsPacketFromRawBuffer(rawResponse)
+}
+
+func (v *testUpstream) String() string {
+	return "test://upstream"
+}
+
+// mustRecord returns the record built for a fixture, whose construction is not expected to fail
+func mustRecord[T protocol.DnsRecord](record T, err error) protocol.DnsRecord {
+	if err != nil {
+		panic(err)
+	}
+
+	return record
+}
+
+func records(records ...[]protocol.DnsRecord) []protocol.DnsRecord {
+	result := make([]protocol.DnsRecord, 0)
+
+	for _, set := range records {
+		result = append(result, set...)
+	}
+
+	return result
+}
+
+func testA(t *testing.T, name string) protocol.DnsRecord {
+	t.Helper()
+
+	return mustRecord(dns_record.NewA(name, testTTL, net.IPv4(192, 0, 2, 1)))
+}
+
+func testSOA(t *testing.T, zone string) protocol.DnsRecord {
+	t.Helper()
+
+	return mustRecord(dns_record.NewSOA(zone, testTTL, "ns."+zone, "hostmaster."+zone, 1, 7200, 3600, 1209600, 300))
+}
+
+func testNSEC(t *testing.T, name string, next string, types ...common.QueryType) protocol.DnsRecord {
+	t.Helper()
+
+	return mustRecord(dns_record.NewNSEC(name, testTTL, next, types))
+}
+
+// testNSEC3Chain returns NSEC3 records (no salt, no additional iterations) of the zone holding the given names
+func testNSEC3Chain(t *testing.T, zone string, types map[string][]common.QueryType) []protocol.DnsRecord {
+	t.Helper()
+
+	hashes := make([][]byte, 0, len(types))
+	hashTypes := make(map[string][]common.QueryType)
+
+	for name, nameTypes := range types {
+		hash, err := dnssec.HashName(name, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hashes = append(hashes, hash)
+		hashTypes[string(hash)] = nameTypes
+	}
+
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i], hashes[j]) < 0 })
+
+	result := make([]protocol.DnsRecord, 0, len(hashes))
+
+	for idx, hash := range hashes {
+		owner := strings.ToLower(base32.HexEncoding.WithPadding(base32.NoPadding).EncodeToString(hash)) + "." + zone
+		next := hashes[(idx+1)%len(hashes)]
+
+		result = append(result, mustRecord(dns_record.NewNSEC3(owner, testTTL, common.NSEC3_HASH_SHA1, 0, 0, []byte{}, next, hashTypes[string(hash)])))
+	}
+
+	return result
+}
+
+/*
+ * newTestNetwork prepares the upstream serving these zones, with the root's key as the trust anchor:
+ * - "example", signed with Ed25519, holding www (signed), bad (invalid signature) and old (expired signature),
+ *   whose non-existent names are proven by NSEC
+ * - "hashed", signed with ECDSA, holding www, whose non-existent names are proven by NSEC3
+ * - "insecure", delegated without DS records
+ * - "broken", whose DS doesn't match its DNSKEY
+ */
+func newTestNetwork(t *testing.T) (*testUpstream, *dnssec.TrustAnchor) {
+	t.Helper()
+
+	upstream := newTestUpstream(t)
+
+	rootKey := newECDSAKey(t, "")
+	exampleKey := newEd25519Key(t, "example")
+	hashedKey := newECDSAKey(t, "hashed")
+	brokenKey := newECDSAKey(t, "broken")
+	otherBrokenKey := newECDSAKey(t, "broken")
+
+	now := time.Now()
+
+	upstream.respond("", common.DNSKEY, common.NOERROR, rootKey.signed(t, rootKey.dnskey), nil)
+
+	for _, key := range []*testKey{exampleKey, hashedKey, brokenKey} {
+		upstream.respond(key.dnskey.GetName(), common.DS, common.NOERROR, rootKey.signed(t, key.ds(t)), nil)
+	}
+
+	upstream.respond("insecure", common.DS, common.NOERROR, nil,
+		rootKey.signed(t, testNSEC(t, "insecure", "zz", common.NS, common.RRSIG, common.NSEC)))
+
+	// example
+	upstream.respond("example", common.DNSKEY, common.NOERROR, exampleKey.signed(t, exampleKey.dnskey), nil)
+	upstream.respond("www.example", common.A, common.NOERROR, exampleKey.signed(t, testA(t, "www.example")), nil)
+
+	badSet := exampleKey.signed(t, mustRecord(dns_record.NewA("bad.example", testTTL, net.IPv4(192, 0, 2, 2))))
+	upstream.respond("bad.example", common.A, common.NOERROR, records([]protocol.DnsRecord{testA(t, "bad.example")}, badSet[1:]), nil)
+
+	upstream.respond("old.example", common.A, common.NOERROR,
+		exampleKey.signWithValidity(t, now.Add(-2*time.Hour), now.Add(-time.Hour), testA(t, "old.example")), nil)
+
+	upstream.respond("missing.example", common.A, common.NXDOMAIN, nil, records(
+		exampleKey.signed(t, testSOA(t, "example")),
+		exampleKey.signed(t, testNSEC(t, "example", "bad.example", common.NS, common.SOA, common.RRSIG, common.NSEC, common.DNSKEY)),
+		exampleKey.signed(t, testNSEC(t, "bad.example", "old.example", common.A, common.RRSIG, common.NSEC)),
+	))
+
+	// signatures of the denial were stripped
+	upstream.respond("gone.example", common.A, common.NXDOMAIN, nil, []protocol.DnsRecord{testSOA(t, "example")})
+
+	// hashed
+	upstream.respond("hashed", common.DNSKEY, common.NOERROR, hashedKey.signed(t, hashedKey.dnskey), nil)
+	upstream.respond("www.hashed", common.A, common.NOERROR, hashedKey.signed(t, testA(t, "www.hashed")), nil)
+
+	nsec3s := make([]protocol.DnsRecord, 0)
+	for _, nsec3 := range testNSEC3Chain(t, "hashed", map[string][]common.QueryType{
+		"hashed":     {common.NS, common.SOA, common.RRSIG, common.DNSKEY, common.NSEC3PARAM},
+		"www.hashed": {common.A, common.RRSIG},
+	}) {
+		nsec3s = append(nsec3s, hashedKey.signed(t, nsec3)...)
+	}
+
+	upstream.respond("missing.hashed", common.A, common.NXDOMAIN, nil, records(hashedKey.signed(t, testSOA(t, "hashed")), nsec3s))
+
+	// insecure
+	upstream.respond("www.insecure", common.A, common.NOERROR, []protocol.DnsRecord{testA(t, "www.insecure")}, nil)
+	upstream.respond("www.insecure", common.SOA, common.NOERROR, nil, []protocol.DnsRecord{testSOA(t, "insecure")})
+
+	// broken
+	upstream.respond("broken", common.DNSKEY, common.NOERROR, otherBrokenKey.signed(t, otherBrokenKey.dnskey), nil)
+	upstream.respond("www.broken", common.A, common.NOERROR, brokenKey.signed(t, testA(t, "www.broken")), nil)
+
+	digest, err := dnssec.KeyDigest(rootKey.dnskey, common.DIGEST_SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anchor, err := dnssec.ParseTrustAnchor(fmt.Sprintf(". %d %d %d %X", rootKey.dnskey.GetKeyTag(), rootKey.dnskey.GetAlgorithm(), common.DIGEST_SHA256, digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return upstream, anchor
+}
+
+func newTestResolver(upstream *testUpstream, anchor *dnssec.TrustAnchor) *Resolver {
+	return New(Config{
+		Upstreams:        []Upstream{upstream},
+		DNSSECValidation: true,
+		TrustAnchors:     []*dnssec.TrustAnchor{anchor},
+	})
+}
+
+// resolveValidated sends the query with DO bit set, so that AD bit of the response tells whether it's secure
+func resolveValidated(t *testing.T, resolver *Resolver, qName string, qType common.QueryType) *protocol.DnsPacket {
+	t.Helper()
+
+	query := protocol.NewDnsPacket()
+	query.Header.ID = 1
+	query.Header.RecursionDesired = true
+	query.AddQuestion(protocol.DnsQuestion{Name: qName, QueryType: qType, Class: common.IN})
+	query.AddResource(dns_record.NewOPT(ednsUDPPayloadSize, true))
+
+	response, err := resolver.ResolveQuery(context.Background(), query)
+	if err != nil {
+		t.Fatalf("could not resolve %s: %s", qName, err)
+	}
+
+	return response
+}
+
+func TestValidation(t *testing.T) {
+	upstream, anchor := newTestNetwork(t)
+
+	tests := []struct {
+		name       string
+		qName      string
+		resultCode common.ResultCode
+		secure     bool
+	}{
+		{"secure answer signed with Ed25519", "www.example", common.NOERROR, true},
+		{"secure answer signed with ECDSA", "www.hashed", common.NOERROR, true},
+		{"insecure delegation", "www.insecure", common.NOERROR, false},
+		{"bogus signature", "bad.example", common.SERVFAIL, false},
+		{"expired signature", "old.example", common.SERVFAIL, false},
+		{"name error proven by NSEC", "missing.example", common.NXDOMAIN, true},
+		{"name error proven by NSEC3", "missing.hashed", common.NXDOMAIN, true},
+		{"name error without proof", "gone.example", common.SERVFAIL, false},
+		{"DS not matching DNSKEY", "www.broken", common.SERVFAIL, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := resolveValidated(t, newTestResolver(upstream, anchor), test.qName, common.A)
+
+			if response.Header.ResultCode != test.resultCode {
+				t.Errorf("expected %s, got %s", test.resultCode.String(), response.Header.ResultCode.String())
+			}
+
+			if response.Header.AuthedData != test.secure {
+				t.Errorf("expected AD bit to be %t", test.secure)
+			}
+		})
+	}
+}
+
+func TestChainOfTrustFailureIsCached(t *testing.T) {
+	upstream, anchor := newTestNetwork(t)
+	resolver := newTestResolver(upstream, anchor)
+
+	for i := 0; i < 3; i++ {
+		response := resolveValidated(t, resolver, "www.broken", common.A)
+
+		if response.Header.ResultCode != common.SERVFAIL {
+			t.Fatalf("expected SERVFAIL, got %s", response.Header.ResultCode.String())
+		}
+	}
+
+	if count := upstream.queryCount("broken", common.DNSKEY); count != 1 {
+		t.Errorf("expected DNSKEY of the bogus zone to be fetched once, got %d queries", count)
+	}
+}
@@ -0,0 +1,86 @@
+package acl
+
+import (
+	"github.com/wiktor-mazur/dns-go/src/utils"
+	"net"
+	"sync"
+)
+
+// List decides which clients are permitted: deny entries take precedence and an empty allow list permits everyone
+type List struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+func (v *List) Permits(ip net.IP) bool {
+	if utils.ContainsIP(v.Deny, ip) {
+		return false
+	}
+
+	return len(v.Allow) == 0 || utils.ContainsIP(v.Allow, ip)
+}
+
+// Rules tell who may send queries at all and who may get recursive resolution
+type Rules struct {
+	Query     List
+	Recursion List
+}
+
+// ParseRules builds the rules from lists of network prefixes (or single addresses)
+func ParseRules(queryAllow, queryDeny, recursionAllow, recursionDeny []string) (Rules, error) {
+	var err error
+	result := Rules{}
+
+	result.Query.Allow, err = utils.ParseCIDRs(queryAllow)
+	if err != nil {
+		return Rules{}, err
+	}
+
+	result.Query.Deny, err = utils.ParseCIDRs(queryDeny)
+	if err != nil {
+		return Rules{}, err
+	}
+
+	result.Recursion.Allow, err = utils.ParseCIDRs(recursionAllow)
+	if err != nil {
+		return Rules{}, err
+	}
+
+	result.Recursion.Deny, err = utils.ParseCIDRs(recursionDeny)
+	if err != nil {
+		return Rules{}, err
+	}
+
+	return result, nil
+}
+
+// ACL holds the rules of a single listener, which can be replaced while it's running
+type ACL struct {
+	mu    sync.RWMutex
+	rules Rules
+}
+
+func New(rules Rules) *ACL {
+	return &ACL{rules: rules}
+}
+
+func (v *ACL) SetRules(rules Rules) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.rules = rules
+}
+
+func (v *ACL) AllowsQuery(ip net.IP) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.rules.Query.Permits(ip)
+}
+
+func (v *ACL) AllowsRecursion(ip net.IP) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.rules.Recursion.Permits(ip)
+}
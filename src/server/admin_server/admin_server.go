@@ -0,0 +1,202 @@
+package admin_server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"github.com/wiktor-mazur/dns-go/src/resolver"
+	"github.com/wiktor-mazur/dns-go/src/utils"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolveTimeout limits the time of lookups requested via the API
+const resolveTimeout = 30 * time.Second
+
+type Config struct {
+	ListenIP      net.IP
+	ListenPort    int
+	Token         string               // required in the Authorization header as a bearer token
+	CurrentConfig func() *utils.Config // returns the config currently in use, which may change when it's reloaded
+}
+
+// AdminServer serves the REST API for inspecting and managing the resolver, responding with JSON
+type AdminServer struct {
+	cfg      Config
+	resolver *resolver.Resolver
+	server   *http.Server
+}
+
+func New(cfg Config, resolver *resolver.Resolver) *AdminServer {
+	result := &AdminServer{cfg: cfg, resolver: resolver}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /resolve", result.resolve)
+	mux.HandleFunc("GET /cache", result.getCache)
+	mux.HandleFunc("DELETE /cache", result.flushCache)
+	mux.HandleFunc("GET /upstreams", result.getUpstreams)
+	mux.HandleFunc("GET /config", result.getConfig)
+	mux.HandleFunc("POST /zones/reload", result.reloadZones)
+
+	result.server = &http.Server{
+		Addr:              net.JoinHostPort(cfg.ListenIP.String(), strconv.Itoa(cfg.ListenPort)),
+		Handler:           result.authenticate(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return result
+}
+
+func (v *AdminServer) Start(wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	listener, err := net.Listen("tcp", v.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Admin API server listening", "address", listener.Addr().String())
+
+	err = v.server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown stops accepting connections and waits for the requests being handled, until the context expires
+func (v *AdminServer) Shutdown(ctx context.Context) error {
+	return v.server.Shutdown(ctx)
+}
+
+// authenticate only lets through the requests with the configured bearer token
+func (v *AdminServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(v.cfg.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dns-go"`)
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// resolve resolves the name given in the "name" parameter, of the type given in the "type" parameter (A by default).
+// With "dnssec" parameter set to true, DNSSEC records are returned, and with "cd" set to true, validation is disabled.
+func (v *AdminServer) resolve(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	name := params.Get("name")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name parameter is required"))
+		return
+	}
+
+	qType := common.A
+	if params.Has("type") {
+		var err error
+
+		qType, err = common.ParseQueryType(params.Get("type"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	query := protocol.NewDnsPacket()
+	query.Header.RecursionDesired = true
+	query.Header.CheckingDisabled = params.Get("cd") == "true"
+
+	question := protocol.NewDnsQuestion()
+	question.Name = strings.TrimSuffix(name, ".")
+	question.QueryType = qType
+
+	query.AddQuestion(*question)
+	query.AddResource(dns_record.NewOPT(uint16(4096), params.Get("dnssec") == "true"))
+
+	ctx, cancel := context.WithTimeout(r.Context(), resolveTimeout)
+	defer cancel()
+
+	response, err := v.resolver.ResolveQuery(ctx, query)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (v *AdminServer) getCache(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, v.resolver.CacheEntries())
+}
+
+// flushCache removes the cached data of the name given in the "name" parameter, of the name and all names below it
+// given in the "suffix" parameter, or the whole cache if neither is given
+func (v *AdminServer) flushCache(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var flushed int
+
+	switch {
+	case params.Has("name") && params.Has("suffix"):
+		writeError(w, http.StatusBadRequest, errors.New("name and suffix parameters can't be used together"))
+		return
+	case params.Has("name"):
+		flushed = v.resolver.FlushCacheName(params.Get("name"))
+	case params.Has("suffix"):
+		flushed = v.resolver.FlushCacheSuffix(params.Get("suffix"))
+	default:
+		flushed = v.resolver.FlushCache()
+	}
+
+	slog.Info("Cache flushed via admin API", "entries", flushed)
+
+	writeJSON(w, http.StatusOK, map[string]int{"flushed": flushed})
+}
+
+func (v *AdminServer) getUpstreams(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, v.resolver.UpstreamHealth())
+}
+
+// getConfig returns the config in use, with the admin token hidden
+func (v *AdminServer) getConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := *v.cfg.CurrentConfig()
+
+	if cfg.ADMIN_TOKEN != "" {
+		cfg.ADMIN_TOKEN = "<redacted>"
+	}
+
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// reloadZones can't do anything, as dns-go only resolves names and doesn't serve zones of its own yet
+func (v *AdminServer) reloadZones(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotImplemented, errors.New("dns-go doesn't serve zones (authoritative mode is not supported), there's nothing to reload"))
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		slog.Debug("Could not send admin API response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
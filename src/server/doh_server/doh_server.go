@@ -0,0 +1,223 @@
+package doh_server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"github.com/wiktor-mazur/dns-go/src/server/query_handler"
+	"github.com/wiktor-mazur/dns-go/src/utils"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Path        = "/dns-query"
+	contentType = "application/dns-message"
+)
+
+type Config struct {
+	ListenIP       net.IP
+	ListenPort     int
+	TLS            *tls.Config  // if not set, plain HTTP is served (e.g. behind a reverse proxy terminating TLS)
+	TrustedProxies []*net.IPNet // proxies whose X-Forwarded-For header tells the client's address
+}
+
+// DoHServer serves DNS-over-HTTPS (RFC 8484) queries, sent either with GET or POST method
+type DoHServer struct {
+	cfg     Config
+	handler *query_handler.QueryHandler
+	server  *http.Server
+}
+
+func New(cfg Config, handler *query_handler.QueryHandler) *DoHServer {
+	result := &DoHServer{cfg: cfg, handler: handler}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(Path, result.ServeHTTP)
+
+	result.server = &http.Server{
+		Addr:              net.JoinHostPort(cfg.ListenIP.String(), strconv.Itoa(cfg.ListenPort)),
+		Handler:           mux,
+		TLSConfig:         cfg.TLS,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	return result
+}
+
+func (v *DoHServer) Start(wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	listener, err := net.Listen("tcp", v.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("DNS-over-HTTPS server listening", "address", listener.Addr().String(), "tls", v.cfg.TLS != nil)
+
+	if v.cfg.TLS != nil {
+		// certificate is provided by the TLS config, which also enables HTTP/2
+		err = v.server.ServeTLS(listener, "", "")
+	} else {
+		err = v.server.Serve(listener)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown stops accepting connections and waits for the requests being handled, until the context expires
+func (v *DoHServer) Shutdown(ctx context.Context) error {
+	return v.server.Shutdown(ctx)
+}
+
+func (v *DoHServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rawQueryPacket, status, err := readQuery(r)
+	if err != nil {
+		if status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", "GET, POST")
+		}
+
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	clientAddr := v.clientAddr(r)
+
+	response := v.handler.Handle(clientAddr, rawQueryPacket, query_handler.HTTPS)
+	if response == nil {
+		http.Error(w, "invalid DNS query", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", maxAge(response)))
+
+	_, err = w.Write(response)
+	if err != nil {
+		slog.Debug("Could not send response", "client", clientAddr, "error", err)
+	}
+}
+
+// readQuery returns the query from "dns" parameter of GET request or from the body of POST request (RFC 8484, section 4.1)
+func readQuery(r *http.Request) ([]byte, int, error) {
+	switch r.Method {
+	case http.MethodGet:
+		value := r.URL.Query().Get("dns")
+		if value == "" {
+			return nil, http.StatusBadRequest, errors.New("missing dns parameter")
+		}
+
+		// padding is not used, but some clients send it anyway
+		result, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("dns parameter is not valid base64url")
+		}
+
+		return result, 0, nil
+	case http.MethodPost:
+		// parameters (e.g. charset) are allowed, though they mean nothing for binary data
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != contentType {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be %s", contentType)
+		}
+
+		result, err := io.ReadAll(io.LimitReader(r.Body, buffer.MaxDNSBufferSize+1))
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+
+		if len(result) > buffer.MaxDNSBufferSize {
+			return nil, http.StatusRequestEntityTooLarge, errors.New("query is too large")
+		}
+
+		return result, 0, nil
+	default:
+		return nil, http.StatusMethodNotAllowed, errors.New("only GET and POST methods are allowed")
+	}
+}
+
+// clientAddr returns the address of the client, which is the rightmost untrusted address of X-Forwarded-For header
+// if the request came through a trusted proxy
+func (v *DoHServer) clientAddr(r *http.Request) net.Addr {
+	result, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		return &net.TCPAddr{}
+	}
+
+	if !utils.ContainsIP(v.cfg.TrustedProxies, result.IP) {
+		return result
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+
+		result = &net.TCPAddr{IP: ip}
+		if !utils.ContainsIP(v.cfg.TrustedProxies, ip) {
+			break
+		}
+	}
+
+	return result
+}
+
+// maxAge returns how long the response may be cached by HTTP caches, which must not exceed the smallest TTL of the
+// answers (RFC 8484, section 5.1); negative responses use TTLs from the authority section (RFC 2308)
+func maxAge(response []byte) uint32 {
+	packet, err := protocol.DnsPacketFromRawBuffer(response)
+	if err != nil || packet.Header.TruncatedMessage {
+		return 0
+	}
+
+	records := packet.Answers
+	if len(records) == 0 {
+		records = packet.Authorities
+	}
+
+	if len(records) == 0 {
+		return 0
+	}
+
+	result := recordTTL(records[0])
+
+	for _, record := range records[1:] {
+		if ttl := recordTTL(record); ttl < result {
+			result = ttl
+		}
+	}
+
+	return result
+}
+
+// recordTTL returns the time the record may be cached for, which for SOA in negative responses is also limited
+// by its minimum field (RFC 2308, section 5)
+func recordTTL(record protocol.DnsRecord) uint32 {
+	soa, ok := record.(*dns_record.SOA)
+	if ok && soa.GetMinimum() < soa.GetTTL() {
+		return soa.GetMinimum()
+	}
+
+	return record.GetTTL()
+}
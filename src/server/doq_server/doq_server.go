@@ -0,0 +1,232 @@
+package doq_server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/quic-go/quic-go"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/server/query_handler"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Error codes used when closing connections and streams (RFC 9250, section 4.3)
+const (
+	DOQ_NO_ERROR          quic.ApplicationErrorCode = 0x0
+	DOQ_INTERNAL_ERROR    quic.ApplicationErrorCode = 0x1
+	DOQ_PROTOCOL_ERROR    quic.ApplicationErrorCode = 0x2
+	DOQ_REQUEST_CANCELLED quic.StreamErrorCode      = 0x3
+)
+
+// defaultIdleTimeout is the time after which an idle connection is closed
+const defaultIdleTimeout = 30 * time.Second
+
+// streamTimeout is the time the client has to send the whole query after opening a stream
+const streamTimeout = 10 * time.Second
+
+// maxConcurrentStreams is the number of queries a single connection may have in progress at the same time
+const maxConcurrentStreams = 100
+
+type Config struct {
+	ListenIP    net.IP
+	ListenPort  int
+	IdleTimeout time.Duration
+	TLS         *tls.Config
+}
+
+// DoQServer serves DNS-over-QUIC (RFC 9250) queries, each sent on its own stream. Connection migration is handled
+// by QUIC, so responses are sent to the client's current address.
+type DoQServer struct {
+	cfg      Config
+	handler  *query_handler.QueryHandler
+	listener *quic.Listener
+	mu       sync.Mutex
+	closing  bool
+	conns    map[*quic.Conn]struct{}
+	inFlight sync.WaitGroup
+}
+
+func New(cfg Config, handler *query_handler.QueryHandler) *DoQServer {
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+
+	return &DoQServer{cfg: cfg, handler: handler, conns: make(map[*quic.Conn]struct{})}
+}
+
+func (v *DoQServer) Start(wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	listener, err := quic.ListenAddr(net.JoinHostPort(v.cfg.ListenIP.String(), strconv.Itoa(v.cfg.ListenPort)), v.cfg.TLS, &quic.Config{
+		MaxIdleTimeout:     v.cfg.IdleTimeout,
+		MaxIncomingStreams: maxConcurrentStreams,
+		// queries are only sent on bidirectional streams (RFC 9250, section 4.2)
+		MaxIncomingUniStreams: -1,
+	})
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	if v.closing {
+		v.mu.Unlock()
+		return listener.Close()
+	}
+	v.listener = listener
+	v.mu.Unlock()
+
+	slog.Info("DNS-over-QUIC server listening", "address", listener.Addr().String())
+
+	for {
+		conn, err := listener.Accept(context.Background())
+		if err != nil {
+			if errors.Is(err, quic.ErrServerClosed) {
+				return nil
+			}
+
+			slog.Warn("Could not accept DNS-over-QUIC connection", "error", err)
+			continue
+		}
+
+		if !v.trackConnection(conn) {
+			conn.CloseWithError(DOQ_NO_ERROR, "")
+			return nil
+		}
+
+		go v.handleConnection(conn)
+	}
+}
+
+// Shutdown stops accepting connections and queries, waits for the queries being handled and closes the connections.
+// If the context expires first, the connections are closed anyway and context's error is returned.
+func (v *DoQServer) Shutdown(ctx context.Context) error {
+	v.mu.Lock()
+	v.closing = true
+
+	if v.listener != nil {
+		v.listener.Close()
+	}
+	v.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		v.inFlight.Wait()
+		close(drained)
+	}()
+
+	var err error
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	v.mu.Lock()
+	for conn := range v.conns {
+		conn.CloseWithError(DOQ_NO_ERROR, "")
+	}
+	v.mu.Unlock()
+
+	return err
+}
+
+func (v *DoQServer) trackConnection(conn *quic.Conn) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.closing {
+		return false
+	}
+
+	v.conns[conn] = struct{}{}
+
+	return true
+}
+
+func (v *DoQServer) untrackConnection(conn *quic.Conn) {
+	v.mu.Lock()
+	delete(v.conns, conn)
+	v.mu.Unlock()
+}
+
+// beginQuery registers the query as in-flight, unless the server is shutting down
+func (v *DoQServer) beginQuery() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.closing {
+		return false
+	}
+
+	v.inFlight.Add(1)
+
+	return true
+}
+
+func (v *DoQServer) handleConnection(conn *quic.Conn) {
+	defer v.untrackConnection(conn)
+
+	for {
+		// stream can't be accepted once the connection is closed (by either side or after the idle timeout)
+		stream, err := conn.AcceptStream(conn.Context())
+		if err != nil {
+			return
+		}
+
+		if !v.beginQuery() {
+			stream.CancelRead(DOQ_REQUEST_CANCELLED)
+			stream.CancelWrite(DOQ_REQUEST_CANCELLED)
+			continue
+		}
+
+		go v.handleStream(conn, stream)
+	}
+}
+
+// handleStream serves a single query, which is sent with two-byte length prefix like over TCP (RFC 9250, section 4.2)
+func (v *DoQServer) handleStream(conn *quic.Conn, stream *quic.Stream) {
+	defer v.inFlight.Done()
+
+	clientAddr := conn.RemoteAddr()
+
+	err := stream.SetReadDeadline(time.Now().Add(streamTimeout))
+	if err != nil {
+		stream.CancelWrite(DOQ_REQUEST_CANCELLED)
+		return
+	}
+
+	rawQueryPacket, err := protocol.ReadTCPMessage(stream)
+	if err != nil {
+		slog.Debug("Could not read query", "client", clientAddr, "error", err)
+		stream.CancelRead(DOQ_REQUEST_CANCELLED)
+		stream.CancelWrite(DOQ_REQUEST_CANCELLED)
+		return
+	}
+
+	// message ID must be 0, as streams already identify the queries (RFC 9250, section 4.2.1)
+	if len(rawQueryPacket) < protocol.DnsHeaderSize || rawQueryPacket[0] != 0 || rawQueryPacket[1] != 0 {
+		slog.Debug("Received invalid query, closing the connection", "client", clientAddr)
+		conn.CloseWithError(DOQ_PROTOCOL_ERROR, "invalid query")
+		return
+	}
+
+	response := v.handler.Handle(clientAddr, rawQueryPacket, query_handler.QUIC)
+	if response == nil {
+		stream.CancelWrite(DOQ_REQUEST_CANCELLED)
+		return
+	}
+
+	err = protocol.WriteTCPMessage(stream, response)
+	if err != nil {
+		slog.Debug("Could not send response", "client", clientAddr, "error", err)
+		return
+	}
+
+	// the response is followed by the end of the stream
+	stream.Close()
+}
@@ -0,0 +1,227 @@
+package doq_server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/quic-go/quic-go"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/resolver"
+	"github.com/wiktor-mazur/dns-go/src/server/query_handler"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTimeout = 5 * time.Second
+
+// selfSignedCertificate creates the certificate for 127.0.0.1, which the client trusts through the returned pool
+func selfSignedCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns-go test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// startServer starts the server on a random loopback port and returns the connection of a client dialing it
+func startServer(t *testing.T) *quic.Conn {
+	t.Helper()
+
+	certificate, pool := selfSignedCertificate(t)
+
+	server := New(Config{
+		ListenIP:   net.IPv4(127, 0, 0, 1),
+		ListenPort: 0,
+		TLS: &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			MinVersion:   tls.VersionTLS12,
+			NextProtos:   []string{"doq"},
+		},
+	}, query_handler.New(resolver.New(resolver.Config{})))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		err := server.Start(&wg)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		server.Shutdown(ctx)
+		wg.Wait()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	conn, err := quic.DialAddr(ctx, listenAddr(t, server).String(), &tls.Config{
+		RootCAs:    pool,
+		NextProtos: []string{"doq"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.CloseWithError(DOQ_NO_ERROR, "")
+	})
+
+	return conn
+}
+
+// listenAddr waits until the server listens and returns its address
+func listenAddr(t *testing.T, server *DoQServer) net.Addr {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+
+	for time.Now().Before(deadline) {
+		server.mu.Lock()
+		listener := server.listener
+		server.mu.Unlock()
+
+		if listener != nil {
+			return listener.Addr()
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("server didn't start listening")
+
+	return nil
+}
+
+// sendQuery sends the query for a CHAOS class name (not served, so it's answered without resolving anything) on a new stream
+func sendQuery(t *testing.T, conn *quic.Conn, id uint16) *quic.Stream {
+	t.Helper()
+
+	query := protocol.NewDnsPacket()
+	query.Header.ID = id
+	query.Header.RecursionDesired = true
+	query.AddQuestion(protocol.DnsQuestion{Name: "version.bind", QueryType: common.QueryType(16), Class: common.Class(3)})
+
+	rawQuery, err := query.ToRawBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stream.SetDeadline(time.Now().Add(testTimeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = protocol.WriteTCPMessage(stream, rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the client indicates the end of the query by closing its side of the stream (RFC 9250, section 4.2)
+	err = stream.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stream
+}
+
+func TestQueryIsAnsweredOnItsStream(t *testing.T) {
+	conn := startServer(t)
+	stream := sendQuery(t, conn, 0)
+
+	rawResponse, err := protocol.ReadTCPMessage(stream)
+	if err != nil {
+		t.Fatalf("could not read the response: %s", err)
+	}
+
+	response, err := protocol.DnsPacketFromRawBuffer(rawResponse)
+	if err != nil {
+		t.Fatalf("could not parse the response: %s", err)
+	}
+
+	if response.Header.ID != 0 || !response.Header.IsResponse {
+		t.Errorf("expected the response with ID 0, got ID %d and QR %t", response.Header.ID, response.Header.IsResponse)
+	}
+
+	if response.Header.ResultCode != common.REFUSED {
+		t.Errorf("expected REFUSED for the CHAOS query, got %s", response.Header.ResultCode.String())
+	}
+
+	if len(response.Questions) != 1 || response.Questions[0].Name != "version.bind" {
+		t.Errorf("expected the question to be echoed, got %v", response.Questions)
+	}
+
+	// the server closes its side of the stream after the response
+	_, err = stream.Read(make([]byte, 1))
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("expected the end of the stream after the response, got %v", err)
+	}
+}
+
+func TestNonZeroMessageIDClosesConnection(t *testing.T) {
+	conn := startServer(t)
+	sendQuery(t, conn, 1234)
+
+	select {
+	case <-conn.Context().Done():
+	case <-time.After(testTimeout):
+		t.Fatal("connection wasn't closed")
+	}
+
+	var appErr *quic.ApplicationError
+
+	err := context.Cause(conn.Context())
+	if !errors.As(err, &appErr) || !appErr.Remote || appErr.ErrorCode != DOQ_PROTOCOL_ERROR {
+		t.Errorf("expected the server to close the connection with DOQ_PROTOCOL_ERROR, got %v", err)
+	}
+}
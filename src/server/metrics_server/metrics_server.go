@@ -0,0 +1,61 @@
+package metrics_server
+
+import (
+	"context"
+	"errors"
+	"github.com/wiktor-mazur/dns-go/src/metrics"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const Path = "/metrics"
+
+type Config struct {
+	ListenIP   net.IP
+	ListenPort int
+}
+
+// MetricsServer exposes metrics over plain HTTP, to be scraped by Prometheus
+type MetricsServer struct {
+	server *http.Server
+}
+
+func New(cfg Config) *MetricsServer {
+	mux := http.NewServeMux()
+	mux.Handle(Path, metrics.Handler())
+
+	return &MetricsServer{
+		server: &http.Server{
+			Addr:              net.JoinHostPort(cfg.ListenIP.String(), strconv.Itoa(cfg.ListenPort)),
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+func (v *MetricsServer) Start(wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	listener, err := net.Listen("tcp", v.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Metrics server listening", "address", listener.Addr().String())
+
+	err = v.server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown stops accepting connections and waits for the scrapes being served, until the context expires
+func (v *MetricsServer) Shutdown(ctx context.Context) error {
+	return v.server.Shutdown(ctx)
+}
@@ -0,0 +1,368 @@
+package query_handler
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/dnstap"
+	"github.com/wiktor-mazur/dns-go/src/logging"
+	"github.com/wiktor-mazur/dns-go/src/metrics"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/resolver"
+	"github.com/wiktor-mazur/dns-go/src/server/acl"
+	"github.com/wiktor-mazur/dns-go/src/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+type Transport string
+
+const (
+	UDP   Transport = "udp"
+	TCP   Transport = "tcp"
+	TLS   Transport = "tls"
+	HTTPS Transport = "https"
+	QUIC  Transport = "quic"
+)
+
+// Name returns the name of the transport used in logs
+func (v Transport) Name() string {
+	switch v {
+	case TLS:
+		return "DNS-over-TLS"
+	case HTTPS:
+		return "DNS-over-HTTPS"
+	case QUIC:
+		return "DNS-over-QUIC"
+	default:
+		return strings.ToUpper(string(v))
+	}
+}
+
+// QueryHandler processes raw DNS queries, regardless of the transport they were received with
+type QueryHandler struct {
+	resolver *resolver.Resolver
+	acl      *acl.ACL
+	queryLog *logging.QueryLog
+	dnstap   *dnstap.Tap
+}
+
+func New(resolver *resolver.Resolver) *QueryHandler {
+	return &QueryHandler{resolver: resolver}
+}
+
+// WithACL returns the handler which only serves the clients permitted by the ACL, so that each listener can have its own
+func (v *QueryHandler) WithACL(acl *acl.ACL) *QueryHandler {
+	result := *v
+	result.acl = acl
+
+	return &result
+}
+
+// WithQueryLog returns the handler which records the queries it handles in the query log
+func (v *QueryHandler) WithQueryLog(queryLog *logging.QueryLog) *QueryHandler {
+	result := *v
+	result.queryLog = queryLog
+
+	return &result
+}
+
+// WithDnstap returns the handler which logs the queries and responses exchanged with clients to dnstap
+func (v *QueryHandler) WithDnstap(tap *dnstap.Tap) *QueryHandler {
+	result := *v
+	result.dnstap = tap
+
+	return &result
+}
+
+// queryInfo collects the details of the handled query, which are reported in metrics and the query log
+type queryInfo struct {
+	query *protocol.DnsPacket // nil if the query couldn't be parsed
+	cache logging.CacheStatus
+}
+
+// Handle resolves the query and returns the raw response that should be sent back to the client,
+// or nil if there's nothing to respond with
+func (v *QueryHandler) Handle(clientAddr net.Addr, rawPacket []byte, transport Transport) []byte {
+	inFlight := metrics.QueriesInFlight.WithLabelValues(string(transport))
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "Query", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		tracing.Transport.String(string(transport)),
+		tracing.Client.String(clientAddr.String()),
+	))
+	defer span.End()
+
+	start := time.Now()
+	info := &queryInfo{cache: logging.CacheNone}
+	response := v.handle(ctx, clientAddr, rawPacket, transport, info)
+	latency := time.Since(start)
+
+	traceQuery(span, info, response)
+
+	if v.dnstap != nil {
+		v.tap(clientAddr, transport, rawPacket, response, start)
+	}
+
+	metrics.QueryDuration.WithLabelValues(string(transport)).Observe(latency.Seconds())
+	metrics.Queries.WithLabelValues(string(transport), queryTypeLabel(info.query), resultCodeLabel(response)).Inc()
+
+	if v.queryLog != nil && info.query != nil {
+		v.logQuery(clientAddr, transport, info, response, latency)
+	}
+
+	return response
+}
+
+func (v *QueryHandler) handle(ctx context.Context, clientAddr net.Addr, rawPacket []byte, transport Transport, info *queryInfo) []byte {
+	// without the header there's not even an ID to respond to
+	if len(rawPacket) < protocol.DnsHeaderSize {
+		slog.Debug("Received query which is too short", "client", clientAddr, "length", len(rawPacket))
+
+		return nil
+	}
+
+	queryPacket, err := protocol.DnsPacketFromRawBuffer(rawPacket)
+	if err != nil {
+		slog.Debug("Received query which could not be parsed", "id", queryPacket.Header.ID, "client", clientAddr, "error", err)
+
+		return v.errResponse(queryPacket, common.FORMERR)
+	}
+
+	info.query = queryPacket
+
+	// denied clients are refused before their query is looked at, so they learn nothing about what would be accepted
+	if v.acl != nil && !v.acl.AllowsQuery(addrIP(clientAddr)) {
+		slog.Debug("Client is not allowed to query, refusing", "id", queryPacket.Header.ID, "client", clientAddr)
+
+		return v.errResponse(queryPacket, common.REFUSED)
+	}
+
+	resultCode := resolver.ValidateQuery(queryPacket)
+	if resultCode != common.NOERROR {
+		slog.Debug("Received invalid query", "id", queryPacket.Header.ID, "client", clientAddr, "rcode", resultCode.String())
+
+		return v.errResponse(queryPacket, resultCode)
+	}
+
+	slog.Debug("Received query", "id", queryPacket.Header.ID, "transport", transport, "client", clientAddr, "question", queryPacket.Questions[0].CompactString())
+
+	// there's no data that could be served without recursion, so the query is refused (RFC 5358, section 4)
+	if v.acl != nil && !v.acl.AllowsRecursion(addrIP(clientAddr)) {
+		slog.Debug("Client is not allowed to use recursion, refusing", "id", queryPacket.Header.ID, "client", clientAddr)
+
+		response := v.resolver.QueryToErrResponse(queryPacket, common.REFUSED)
+		response.Header.RecursionAvailable = false
+
+		return serialize(response)
+	}
+
+	// answers are not cached (only DNSSEC keys are), so every resolved query is a cache miss
+	info.cache = logging.CacheMiss
+
+	responsePacket, err := v.resolver.ResolveQuery(ctx, queryPacket)
+	if err != nil {
+		slog.Warn("Could not perform the lookup", "id", queryPacket.Header.ID, "error", err)
+
+		return v.errResponse(queryPacket, common.SERVFAIL)
+	}
+
+	responsePacketBuf, err := responsePacket.ToRawBufferTruncated(maxResponseSize(queryPacket, transport))
+	if err != nil {
+		slog.Error("Could not serialize response packet", "id", queryPacket.Header.ID, "error", err)
+
+		return v.errResponse(queryPacket, common.SERVFAIL)
+	}
+
+	if responsePacket.Header.TruncatedMessage {
+		slog.Debug("Response was truncated", "id", queryPacket.Header.ID, "size", maxResponseSize(queryPacket, transport))
+	}
+
+	return responsePacketBuf
+}
+
+// traceQuery records the question and the result of the query in its span
+func traceQuery(span trace.Span, info *queryInfo, response []byte) {
+	if info.query != nil {
+		span.SetAttributes(tracing.QueryID.Int(int(info.query.Header.ID)))
+
+		if len(info.query.Questions) > 0 {
+			span.SetAttributes(
+				tracing.QueryName.String(info.query.Questions[0].Name),
+				tracing.QueryType.String(info.query.Questions[0].QueryType.String()),
+			)
+		}
+	}
+
+	resultCode := resultCodeLabel(response)
+	span.SetAttributes(tracing.ResultCode.String(resultCode))
+
+	if resultCode == "SERVFAIL" {
+		span.SetStatus(codes.Error, "SERVFAIL")
+	}
+}
+
+// dnstapProtocols maps transports to the ones reported in dnstap
+var dnstapProtocols = map[Transport]dnstap.SocketProtocol{
+	UDP:   dnstap.UDP,
+	TCP:   dnstap.TCP,
+	TLS:   dnstap.DOT,
+	HTTPS: dnstap.DOH,
+	QUIC:  dnstap.DOQ,
+}
+
+func (v *QueryHandler) tap(clientAddr net.Addr, transport Transport, rawPacket []byte, response []byte, start time.Time) {
+	v.dnstap.Log(&dnstap.Message{
+		Type:         dnstap.CLIENT_QUERY,
+		Protocol:     dnstapProtocols[transport],
+		QueryAddr:    clientAddr,
+		QueryTime:    start,
+		QueryMessage: rawPacket,
+	})
+
+	if response == nil {
+		return
+	}
+
+	v.dnstap.Log(&dnstap.Message{
+		Type:            dnstap.CLIENT_RESPONSE,
+		Protocol:        dnstapProtocols[transport],
+		QueryAddr:       clientAddr,
+		QueryTime:       start,
+		ResponseTime:    time.Now(),
+		QueryMessage:    rawPacket,
+		ResponseMessage: response,
+	})
+}
+
+func (v *QueryHandler) logQuery(clientAddr net.Addr, transport Transport, info *queryInfo, response []byte, latency time.Duration) {
+	entry := logging.QueryLogEntry{
+		Client:    clientAddr,
+		Transport: string(transport),
+		ID:        info.query.Header.ID,
+		RCode:     resultCodeLabel(response),
+		Latency:   latency,
+		Cache:     info.cache,
+	}
+
+	if len(info.query.Questions) > 0 {
+		question := info.query.Questions[0]
+
+		entry.Name = question.Name
+		entry.Type = question.QueryType.String()
+		entry.Class = question.Class.String()
+	}
+
+	if len(response) >= protocol.DnsHeaderSize {
+		entry.Answers = int(binary.BigEndian.Uint16(response[6:8]))
+	}
+
+	v.queryLog.Log(entry)
+}
+
+// HandleOversized returns FORMERR response to the query which was too large to be received in whole
+func (v *QueryHandler) HandleOversized(clientAddr net.Addr, rawPacket []byte) []byte {
+	slog.Debug("Received query which is too large", "client", clientAddr, "length", len(rawPacket))
+
+	return v.Reject(rawPacket, common.FORMERR)
+}
+
+// Reject returns the error response to the query without resolving it, e.g. when the server is overloaded
+func (v *QueryHandler) Reject(rawPacket []byte, resultCode common.ResultCode) []byte {
+	if len(rawPacket) < protocol.DnsHeaderSize {
+		return nil
+	}
+
+	queryPacket, err := protocol.DnsPacketFromRawBuffer(rawPacket)
+	if err != nil {
+		// the query is incomplete, so only the header can be relied on
+		queryPacket = &protocol.DnsPacket{Header: queryPacket.Header}
+	}
+
+	return v.errResponse(queryPacket, resultCode)
+}
+
+// errResponse returns the raw error response to the query, or nil if even that couldn't be serialized
+func (v *QueryHandler) errResponse(queryPacket *protocol.DnsPacket, resultCode common.ResultCode) []byte {
+	// responses are never answered, so that two servers can't end up in a loop
+	if queryPacket.Header.IsResponse {
+		return nil
+	}
+
+	return serialize(v.resolver.QueryToErrResponse(queryPacket, resultCode))
+}
+
+// serialize returns the raw error response, or nil if it couldn't be serialized
+func serialize(response *protocol.DnsPacket) []byte {
+	result, err := response.ToRawBufferTruncated(buffer.DNSBufferSize)
+	if err != nil {
+		slog.Error("Could not serialize error response", "id", response.Header.ID, "error", err)
+
+		return nil
+	}
+
+	return result
+}
+
+// queryTypeLabel returns the type of the query used in metrics. Types without a registered mnemonic are all labelled
+// "other", as clients could otherwise create a series for each of 65536 types.
+func queryTypeLabel(queryPacket *protocol.DnsPacket) string {
+	if queryPacket == nil || len(queryPacket.Questions) == 0 {
+		return "none"
+	}
+
+	qType := queryPacket.Questions[0].QueryType
+	if !qType.IsKnown() {
+		return "other"
+	}
+
+	return qType.String()
+}
+
+// resultCodeLabel returns the result code of the raw response used in metrics
+func resultCodeLabel(response []byte) string {
+	if len(response) < protocol.DnsHeaderSize {
+		return "none"
+	}
+
+	resultCode := common.ResultCode(response[3] & 0x0F)
+
+	return resultCode.String()
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch value := addr.(type) {
+	case *net.UDPAddr:
+		return value.IP
+	case *net.TCPAddr:
+		return value.IP
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+
+	return net.ParseIP(host)
+}
+
+// maxResponseSize returns the size of response the client is able to receive: for UDP it's 512 bytes
+// or the size advertised via EDNS (RFC 6891, section 6.2.5), while stream transports can carry any DNS message
+func maxResponseSize(queryPacket *protocol.DnsPacket, transport Transport) uint {
+	if transport != UDP {
+		return buffer.MaxDNSBufferSize
+	}
+
+	opt := queryPacket.GetOPT()
+	if opt == nil || opt.GetUDPPayloadSize() < buffer.DNSBufferSize {
+		return buffer.DNSBufferSize
+	}
+
+	return uint(opt.GetUDPPayloadSize())
+}
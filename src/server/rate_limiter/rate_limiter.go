@@ -0,0 +1,225 @@
+package rate_limiter
+
+import (
+	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/utils"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * Response rate limiting, as described in https://kb.isc.org/docs/aa-00994 - it limits the number of similar responses
+ * sent to a single network, so that the server can't be used to amplify reflection attacks with spoofed source addresses.
+ * Limited responses are dropped, except for every n-th one (slip) that is sent truncated. Legitimate clients which happen
+ * to share the network with a victim are then able to repeat their query over TCP, which can't be spoofed.
+ */
+
+type Config struct {
+	ResponsesPerSecond float64
+	Slip               int           // every n-th limited response is truncated instead of dropped, 0 means all are dropped
+	Window             time.Duration // how long the network has to stay below the limit to get all responses again
+	IPv4PrefixLength   int
+	IPv6PrefixLength   int
+	Exempt             []*net.IPNet
+	MaxEntries         int
+}
+
+type account struct {
+	balance  float64
+	lastSeen time.Time
+	limited  uint64
+}
+
+type RateLimiter struct {
+	cfg         Config
+	mu          sync.Mutex
+	accounts    map[string]*account
+	lastCleanup time.Time
+	dropped     uint64
+	slipped     uint64
+}
+
+func New(cfg Config) *RateLimiter {
+	if cfg.Window < time.Second {
+		cfg.Window = time.Second
+	}
+
+	return &RateLimiter{cfg: cfg, accounts: make(map[string]*account), lastCleanup: time.Now()}
+}
+
+// Limit returns the response which should be sent to the client: the original one, truncated one or nil if it's dropped
+func (v *RateLimiter) Limit(clientIP net.IP, response []byte) []byte {
+	if response == nil || utils.ContainsIP(v.cfg.Exempt, clientIP) {
+		return response
+	}
+
+	key, ok := v.responseKey(clientIP, response)
+	if !ok {
+		return response
+	}
+
+	limited, slip := v.account(key, time.Now())
+	if !limited {
+		return response
+	}
+
+	if !slip {
+		atomic.AddUint64(&v.dropped, 1)
+		return nil
+	}
+
+	atomic.AddUint64(&v.slipped, 1)
+
+	return slipResponse(response)
+}
+
+// Dropped returns the number of responses that weren't sent because of the limit
+func (v *RateLimiter) Dropped() uint64 {
+	return atomic.LoadUint64(&v.dropped)
+}
+
+// Slipped returns the number of responses that were truncated because of the limit
+func (v *RateLimiter) Slipped() uint64 {
+	return atomic.LoadUint64(&v.slipped)
+}
+
+// account charges the response to the account and tells whether it's over the limit and whether it should slip
+func (v *RateLimiter) account(key string, now time.Time) (bool, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if now.Sub(v.lastCleanup) > v.cfg.Window {
+		v.cleanup(now)
+	}
+
+	acc, ok := v.accounts[key]
+	if !ok {
+		// under a flood of distinct networks, the table is kept bounded by forgetting the ones not seen for the longest time
+		if v.cfg.MaxEntries > 0 && len(v.accounts) >= v.cfg.MaxEntries {
+			v.evict()
+		}
+
+		acc = &account{balance: v.cfg.ResponsesPerSecond, lastSeen: now}
+		v.accounts[key] = acc
+	}
+
+	// credit is earned over time up to one second worth of responses, and debt up to the window is remembered
+	acc.balance += now.Sub(acc.lastSeen).Seconds() * v.cfg.ResponsesPerSecond
+	if acc.balance > v.cfg.ResponsesPerSecond {
+		acc.balance = v.cfg.ResponsesPerSecond
+	}
+
+	acc.lastSeen = now
+	acc.balance--
+
+	if minBalance := -v.cfg.ResponsesPerSecond * v.cfg.Window.Seconds(); acc.balance < minBalance {
+		acc.balance = minBalance
+	}
+
+	if acc.balance >= 0 {
+		acc.limited = 0
+		return false, false
+	}
+
+	acc.limited++
+
+	return true, v.cfg.Slip > 0 && acc.limited%uint64(v.cfg.Slip) == 0
+}
+
+// cleanup forgets accounts which have been paid off, as they're no different from new ones
+func (v *RateLimiter) cleanup(now time.Time) {
+	for key, acc := range v.accounts {
+		if acc.balance+now.Sub(acc.lastSeen).Seconds()*v.cfg.ResponsesPerSecond >= v.cfg.ResponsesPerSecond {
+			delete(v.accounts, key)
+		}
+	}
+
+	v.lastCleanup = now
+}
+
+// evict forgets the least recently seen half of the accounts, so that the cost of sorting them is spread over
+// as many new accounts
+func (v *RateLimiter) evict() {
+	keys := make([]string, 0, len(v.accounts))
+	for key := range v.accounts {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return v.accounts[keys[i]].lastSeen.Before(v.accounts[keys[j]].lastSeen) })
+
+	for _, key := range keys[:(len(keys)+1)/2] {
+		delete(v.accounts, key)
+	}
+}
+
+// responseKey identifies similar responses sent to the client's network: answers and empty answers are distinguished
+// by the question, while NXDOMAIN and error responses are limited together, regardless of the name asked for
+func (v *RateLimiter) responseKey(clientIP net.IP, response []byte) (string, bool) {
+	buf := buffer.BytePacketBufferFromRawBuffer(response)
+
+	header := protocol.DnsHeader{}
+	if header.Read(buf) != nil {
+		return "", false
+	}
+
+	key := new(strings.Builder)
+	key.WriteString(v.clientNetwork(clientIP))
+
+	switch {
+	case header.ResultCode == common.NXDOMAIN:
+		key.WriteString("|nxdomain")
+	case header.ResultCode != common.NOERROR:
+		key.WriteString("|error")
+	default:
+		if header.AnswersCount > 0 {
+			key.WriteString("|answer|")
+		} else {
+			key.WriteString("|nodata|")
+		}
+
+		question := protocol.NewDnsQuestion()
+		if header.QuestionsCount > 0 && question.Read(buf) == nil {
+			key.WriteString(strings.ToLower(question.Name))
+			key.WriteString("|")
+			key.WriteString(question.QueryType.String())
+		}
+	}
+
+	return key.String(), true
+}
+
+func (v *RateLimiter) clientNetwork(clientIP net.IP) string {
+	if ipv4 := clientIP.To4(); ipv4 != nil {
+		return ipv4.Mask(net.CIDRMask(v.cfg.IPv4PrefixLength, 8*net.IPv4len)).String()
+	}
+
+	return clientIP.Mask(net.CIDRMask(v.cfg.IPv6PrefixLength, 8*net.IPv6len)).String()
+}
+
+// slipResponse truncates the response, leaving only the question, so the client retries over TCP
+func slipResponse(response []byte) []byte {
+	packet, err := protocol.DnsPacketFromRawBuffer(response)
+	if err != nil {
+		return nil
+	}
+
+	truncated := &protocol.DnsPacket{Header: packet.Header, Questions: packet.Questions}
+	truncated.Header.TruncatedMessage = true
+
+	if opt := packet.GetOPT(); opt != nil {
+		truncated.AddResource(opt)
+	}
+
+	result, err := truncated.ToRawBuffer()
+	if err != nil {
+		return nil
+	}
+
+	return result
+}
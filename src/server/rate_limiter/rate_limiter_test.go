@@ -0,0 +1,179 @@
+package rate_limiter
+
+import (
+	"fmt"
+	"github.com/wiktor-mazur/dns-go/src/common"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/protocol/dns_record"
+	"net"
+	"testing"
+	"time"
+)
+
+var testClientIP = net.ParseIP("192.0.2.10")
+
+func testResponse(t *testing.T) []byte {
+	t.Helper()
+
+	record, err := dns_record.NewA("example.com", 300, net.IPv4(192, 0, 2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet := protocol.NewDnsPacket()
+	packet.Header.ID = 1234
+	packet.Header.IsResponse = true
+	packet.AddQuestion(protocol.DnsQuestion{Name: "example.com", QueryType: common.A, Class: common.IN})
+	packet.AddAnswer(record)
+
+	result, err := packet.ToRawBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+// charge accounts the responses at the given time and returns how many of them were limited
+func charge(limiter *RateLimiter, key string, now time.Time, responses int) int {
+	result := 0
+
+	for i := 0; i < responses; i++ {
+		if limited, _ := limiter.account(key, now); limited {
+			result++
+		}
+	}
+
+	return result
+}
+
+func TestBalance(t *testing.T) {
+	limiter := New(Config{ResponsesPerSecond: 5, Window: 15 * time.Second})
+	start := time.Now()
+
+	if limited := charge(limiter, "key", start, 5); limited != 0 {
+		t.Errorf("expected a second worth of responses to be sent, %d were limited", limited)
+	}
+
+	if limited := charge(limiter, "key", start, 5); limited != 5 {
+		t.Errorf("expected responses over the limit to be limited, %d of 5 were", limited)
+	}
+
+	// a second pays the debt of five responses off, but earns no credit yet
+	if limited := charge(limiter, "key", start.Add(time.Second), 1); limited != 1 {
+		t.Errorf("expected the response to be limited until the debt is paid off")
+	}
+
+	// credit is capped at a second worth of responses
+	if limited := charge(limiter, "key", start.Add(time.Minute), 6); limited != 1 {
+		t.Errorf("expected a second worth of responses to be sent after a long pause, %d of 6 were limited", limited)
+	}
+}
+
+func TestDebtIsLimitedByWindow(t *testing.T) {
+	limiter := New(Config{ResponsesPerSecond: 1, Window: 2 * time.Second})
+	start := time.Now()
+
+	charge(limiter, "key", start, 100)
+
+	// the debt of 99 responses is forgotten except for the window of 2 seconds
+	if limited := charge(limiter, "key", start.Add(3*time.Second), 1); limited != 0 {
+		t.Errorf("expected the response to be sent once the network stayed below the limit for the window")
+	}
+}
+
+func TestSlip(t *testing.T) {
+	limiter := New(Config{ResponsesPerSecond: 1, Slip: 2, Window: time.Minute, IPv4PrefixLength: 24, IPv6PrefixLength: 56})
+	response := testResponse(t)
+
+	if result := limiter.Limit(testClientIP, response); result == nil {
+		t.Fatalf("expected the first response to be sent")
+	}
+
+	for i := 0; i < 4; i++ {
+		result := limiter.Limit(testClientIP, response)
+
+		if i%2 == 0 {
+			if result != nil {
+				t.Errorf("expected limited response %d to be dropped", i+1)
+			}
+
+			continue
+		}
+
+		if result == nil {
+			t.Fatalf("expected limited response %d to slip", i+1)
+		}
+
+		packet, err := protocol.DnsPacketFromRawBuffer(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !packet.Header.TruncatedMessage || len(packet.Answers) != 0 || len(packet.Questions) != 1 {
+			t.Errorf("expected truncated response with the question only")
+		}
+	}
+
+	if limiter.Dropped() != 2 || limiter.Slipped() != 2 {
+		t.Errorf("expected 2 dropped and 2 slipped responses, got %d and %d", limiter.Dropped(), limiter.Slipped())
+	}
+}
+
+func TestNetworksAreLimitedTogether(t *testing.T) {
+	limiter := New(Config{ResponsesPerSecond: 1, Window: time.Minute, IPv4PrefixLength: 24, IPv6PrefixLength: 56})
+	response := testResponse(t)
+
+	if limiter.Limit(net.ParseIP("192.0.2.1"), response) == nil {
+		t.Fatalf("expected the first response to be sent")
+	}
+
+	if limiter.Limit(net.ParseIP("192.0.2.2"), response) != nil {
+		t.Errorf("expected the response to another address of the network to be limited")
+	}
+
+	if limiter.Limit(net.ParseIP("198.51.100.1"), response) == nil {
+		t.Errorf("expected the response to another network to be sent")
+	}
+}
+
+func TestCleanupForgetsPaidOffAccounts(t *testing.T) {
+	limiter := New(Config{ResponsesPerSecond: 1, Window: 2 * time.Second})
+	start := time.Now()
+
+	charge(limiter, "paid", start, 1)
+	charge(limiter, "indebted", start.Add(time.Second), 5)
+	charge(limiter, "new", start.Add(3*time.Second), 1)
+
+	if _, ok := limiter.accounts["paid"]; ok {
+		t.Errorf("expected the paid off account to be forgotten")
+	}
+
+	if _, ok := limiter.accounts["indebted"]; !ok {
+		t.Errorf("expected the account still in debt to be kept")
+	}
+}
+
+func TestFullTableEvictsLeastRecentlySeen(t *testing.T) {
+	limiter := New(Config{ResponsesPerSecond: 1, Window: time.Hour, MaxEntries: 4})
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		charge(limiter, fmt.Sprint(i), start.Add(time.Duration(i)*time.Millisecond), 2)
+	}
+
+	// the new network is still limited, even though the table is full
+	if limited := charge(limiter, "new", start.Add(time.Second), 2); limited != 1 {
+		t.Errorf("expected the response over the limit to be limited when the table is full")
+	}
+
+	if len(limiter.accounts) != 3 {
+		t.Errorf("expected half of the accounts to be evicted, %d are left", len(limiter.accounts))
+	}
+
+	for _, key := range []string{"2", "3", "new"} {
+		if _, ok := limiter.accounts[key]; !ok {
+			t.Errorf("expected the account %s to be kept", key)
+		}
+	}
+}
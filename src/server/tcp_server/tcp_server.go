@@ -0,0 +1,222 @@
+package tcp_server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/wiktor-mazur/dns-go/src/protocol"
+	"github.com/wiktor-mazur/dns-go/src/server/query_handler"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultIdleTimeout is the time after which an idle connection is closed (RFC 7766, section 6.2.3)
+const defaultIdleTimeout = 10 * time.Second
+
+// maxPipelinedQueries is the number of queries handled concurrently within a single connection (RFC 7766, section 6.2.1.1)
+const maxPipelinedQueries = 16
+
+type Config struct {
+	ListenIP    net.IP
+	ListenPort  int
+	IdleTimeout time.Duration
+	TLS         *tls.Config // if set, connections are secured with TLS (DNS-over-TLS, RFC 7858)
+}
+
+type TCPServer struct {
+	cfg       Config
+	handler   *query_handler.QueryHandler
+	transport query_handler.Transport
+	listener  net.Listener
+	mu        sync.Mutex
+	closing   bool
+	conns     map[net.Conn]struct{}
+	inFlight  sync.WaitGroup
+}
+
+func New(cfg Config, handler *query_handler.QueryHandler) *TCPServer {
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+
+	transport := query_handler.TCP
+	if cfg.TLS != nil {
+		transport = query_handler.TLS
+	}
+
+	return &TCPServer{cfg: cfg, handler: handler, transport: transport, conns: make(map[net.Conn]struct{})}
+}
+
+func (v *TCPServer) Start(wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	tcpListener, err := net.ListenTCP("tcp", &net.TCPAddr{
+		Port: v.cfg.ListenPort,
+		IP:   v.cfg.ListenIP,
+	})
+	if err != nil {
+		return err
+	}
+
+	var listener net.Listener = tcpListener
+	if v.cfg.TLS != nil {
+		listener = tls.NewListener(listener, v.cfg.TLS)
+	}
+
+	v.mu.Lock()
+	if v.closing {
+		v.mu.Unlock()
+		return listener.Close()
+	}
+	v.listener = listener
+	v.mu.Unlock()
+
+	slog.Info(v.transport.Name()+" server listening", "address", listener.Addr().String())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			slog.Warn("Could not accept "+v.transport.Name()+" connection", "error", err)
+			continue
+		}
+
+		if !v.trackConnection(conn) {
+			conn.Close()
+			return nil
+		}
+
+		go v.handleConnection(conn)
+	}
+}
+
+// Shutdown stops accepting connections and lets the open ones finish the queries being handled, after which they're closed.
+// If the context expires first, the connections are closed anyway and context's error is returned.
+func (v *TCPServer) Shutdown(ctx context.Context) error {
+	v.mu.Lock()
+	v.closing = true
+
+	if v.listener != nil {
+		v.listener.Close()
+	}
+
+	// connections waiting for the next query are interrupted, the ones handling queries stop after responding
+	for conn := range v.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	v.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		v.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		v.mu.Lock()
+		for conn := range v.conns {
+			conn.Close()
+		}
+		v.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// trackConnection registers the connection as open, unless the server is shutting down
+func (v *TCPServer) trackConnection(conn net.Conn) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.closing {
+		return false
+	}
+
+	v.conns[conn] = struct{}{}
+	v.inFlight.Add(1)
+
+	return true
+}
+
+func (v *TCPServer) untrackConnection(conn net.Conn) {
+	v.mu.Lock()
+	delete(v.conns, conn)
+	v.mu.Unlock()
+
+	conn.Close()
+	v.inFlight.Done()
+}
+
+// waitForQuery sets the idle timeout for reading the next query, returning false if the server is shutting down
+func (v *TCPServer) waitForQuery(conn net.Conn) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.closing {
+		return false
+	}
+
+	return conn.SetReadDeadline(time.Now().Add(v.cfg.IdleTimeout)) == nil
+}
+
+// handleConnection serves queries sent over the connection, each prefixed with two-byte length (RFC 1035, section 4.2.2).
+// Queries are handled concurrently and responses are sent as soon as they're ready, possibly out of order.
+func (v *TCPServer) handleConnection(conn net.Conn) {
+	defer v.untrackConnection(conn)
+
+	clientAddr := conn.RemoteAddr()
+
+	var pending sync.WaitGroup
+	var writeMu sync.Mutex
+	slots := make(chan struct{}, maxPipelinedQueries)
+
+	// responses to the queries already received are sent before the connection is closed
+	defer pending.Wait()
+
+	for v.waitForQuery(conn) {
+		rawQueryPacket, err := protocol.ReadTCPMessage(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !isTimeout(err) {
+				slog.Debug("Could not read query", "client", clientAddr, "error", err)
+			}
+
+			return
+		}
+
+		slots <- struct{}{}
+		pending.Add(1)
+
+		go func() {
+			defer pending.Done()
+			defer func() { <-slots }()
+
+			response := v.handler.Handle(clientAddr, rawQueryPacket, v.transport)
+			if response == nil {
+				return
+			}
+
+			writeMu.Lock()
+			defer writeMu.Unlock()
+
+			err := protocol.WriteTCPMessage(conn, response)
+			if err != nil {
+				slog.Debug("Could not send response", "client", clientAddr, "error", err)
+			}
+		}()
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,56 @@
+package tls_certificate
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// Loader keeps the certificate loaded from files, which can be reloaded (e.g. after renewal) without restarting servers
+type Loader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+}
+
+func NewLoader(certFile string, keyFile string) (*Loader, error) {
+	result := &Loader{certFile: certFile, keyFile: keyFile}
+
+	err := result.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Reload loads the certificate from files again, keeping the previous one if they're invalid
+func (v *Loader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(v.certFile, v.keyFile)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.cert = &cert
+
+	return nil
+}
+
+func (v *Loader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.cert, nil
+}
+
+// TLSConfig returns server's TLS config using the loaded certificate, with given application protocols (ALPN)
+func (v *Loader) TLSConfig(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		GetCertificate: v.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     nextProtos,
+	}
+}
@@ -1,29 +1,85 @@
 package udp_server
 
 import (
-	"github.com/wiktor-mazur/dns-go/src/buffer"
+	"context"
+	"fmt"
 	"github.com/wiktor-mazur/dns-go/src/common"
-	"github.com/wiktor-mazur/dns-go/src/protocol"
-	"github.com/wiktor-mazur/dns-go/src/resolver"
-	"log"
+	"github.com/wiktor-mazur/dns-go/src/server/query_handler"
+	"github.com/wiktor-mazur/dns-go/src/server/rate_limiter"
+	"log/slog"
 	"net"
-	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
+// maxQuerySize is the largest query accepted; queries may exceed 512 bytes when they carry EDNS options
+const maxQuerySize = 4096
+
+// QueueFullPolicy tells what happens to queries received while all workers are busy and the queue is full
+type QueueFullPolicy string
+
+const (
+	DROP     QueueFullPolicy = "drop"     // query is ignored, so the client will retry or try another server
+	REFUSED  QueueFullPolicy = "refused"  // client gets REFUSED response right away
+	SERVFAIL QueueFullPolicy = "servfail" // client gets SERVFAIL response right away
+)
+
+func ParseQueueFullPolicy(value string) (QueueFullPolicy, error) {
+	switch policy := QueueFullPolicy(strings.ToLower(value)); policy {
+	case DROP, REFUSED, SERVFAIL:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown queue full policy %q", value)
+	}
+}
+
 type Config struct {
-	ListenIP   net.IP
-	ListenPort int
+	ListenIP        net.IP
+	ListenPort      int
+	Workers         int
+	QueueSize       int
+	QueueFullPolicy QueueFullPolicy
+	RateLimiter     *rate_limiter.RateLimiter // optional, responses are not limited if it's nil
+}
+
+type request struct {
+	clientAddr *net.UDPAddr
+	buf        *[]byte
+	length     int
 }
 
 type UDPServer struct {
 	cfg      Config
-	resolver *resolver.Resolver
+	handler  *query_handler.QueryHandler
 	conn     *net.UDPConn
+	queue    chan request
+	buffers  sync.Pool
+	mu       sync.Mutex
+	closing  bool
+	inFlight sync.WaitGroup
 }
 
-func New(cfg Config, resolver *resolver.Resolver) *UDPServer {
-	return &UDPServer{cfg: cfg, resolver: resolver}
+func New(cfg Config, handler *query_handler.QueryHandler) *UDPServer {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+
+	if cfg.QueueSize < 0 {
+		cfg.QueueSize = 0
+	}
+
+	if cfg.QueueFullPolicy == "" {
+		cfg.QueueFullPolicy = DROP
+	}
+
+	result := &UDPServer{cfg: cfg, handler: handler, queue: make(chan request, cfg.QueueSize)}
+	result.buffers.New = func() any {
+		buf := make([]byte, maxQuerySize+1)
+		return &buf
+	}
+
+	return result
 }
 
 func (v *UDPServer) Start(wg *sync.WaitGroup) error {
@@ -37,83 +93,155 @@ func (v *UDPServer) Start(wg *sync.WaitGroup) error {
 		return err
 	}
 
+	v.mu.Lock()
+	if v.closing {
+		v.mu.Unlock()
+		return conn.Close()
+	}
 	v.conn = conn
+	v.mu.Unlock()
+
+	slog.Info("UDP server listening", "address", conn.LocalAddr().String(), "workers", v.cfg.Workers, "queue_size", v.cfg.QueueSize)
+
+	for i := 0; i < v.cfg.Workers; i++ {
+		go v.worker()
+	}
 
-	log.Printf("UDP server listening at %s\n", conn.LocalAddr().String())
+	// workers exit once the queries remaining in the queue are handled
+	defer close(v.queue)
 
 	for {
-		// @TODO: Implement timeout to reading from UDP socket
-		rawQueryPacket := make([]byte, buffer.DNSBufferSize+1)
-		packetLength, clientAddr, err := conn.ReadFromUDP(rawQueryPacket)
+		buf := v.buffers.Get().(*[]byte)
+		packetLength, clientAddr, err := conn.ReadFromUDP(*buf)
 
-		if packetLength > buffer.DNSBufferSize {
-			// @TODO: Respond to client with FORMERR when that happens
-			log.Printf("Packet received from [%s] is too large (%d/%d bytes).", clientAddr, packetLength, buffer.DNSBufferSize)
-			continue
+		if !v.beginRequest() {
+			v.buffers.Put(buf)
+			return nil
 		}
 
 		if err != nil {
-			// @TODO: Respond to client with FORMERR when that happens
-			log.Printf("Could not read packet received from [%s]: %s", clientAddr, err.Error())
+			v.finishRequest(buf)
+			slog.Debug("Could not read packet", "client", clientAddr, "error", err)
+			continue
+		}
+
+		if packetLength > maxQuerySize {
+			v.sendResponse(clientAddr, v.handler.HandleOversized(clientAddr, (*buf)[:packetLength]))
+			v.finishRequest(buf)
 			continue
 		}
 
-		go v.handleRequest(clientAddr, rawQueryPacket[:packetLength])
+		select {
+		case v.queue <- request{clientAddr: clientAddr, buf: buf, length: packetLength}:
+		default:
+			v.rejectRequest(clientAddr, (*buf)[:packetLength])
+			v.finishRequest(buf)
+		}
 	}
 }
 
-func (v *UDPServer) handleRequest(clientAddr *net.UDPAddr, rawPacket []byte) {
-	queryPacket, err := protocol.DnsPacketFromRawBuffer(rawPacket)
-	if err != nil {
-		logFormat := "[%s] Received query from [%s] but could not parse the packet: %s"
+// Shutdown stops receiving queries and waits for the ones being handled to be responded to, before closing the socket.
+// If the context expires first, the socket is closed anyway and context's error is returned.
+func (v *UDPServer) Shutdown(ctx context.Context) error {
+	v.mu.Lock()
+	v.closing = true
+	conn := v.conn
+	v.mu.Unlock()
 
-		if queryPacket != nil {
-			log.Printf(logFormat, strconv.Itoa(int(queryPacket.Header.ID)), clientAddr, err.Error())
+	if conn == nil {
+		return nil
+	}
 
-			response, _ := v.resolver.QueryToErrResponse(queryPacket, common.FORMERR).ToRawBuffer()
+	if v.cfg.RateLimiter != nil {
+		slog.Info("Response rate limiting stats", "dropped", v.cfg.RateLimiter.Dropped(), "slipped", v.cfg.RateLimiter.Slipped())
+	}
 
-			go v.sendResponse(clientAddr, queryPacket.Header.ID, response)
-		} else {
-			log.Printf(logFormat, "?", clientAddr, err.Error())
-		}
+	// the socket stays open to send the remaining responses, so the read loop is interrupted with a deadline instead
+	err := conn.SetReadDeadline(time.Now())
+	if err != nil {
+		return err
+	}
 
-		return
+	drained := make(chan struct{})
+	go func() {
+		v.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return conn.Close()
+	case <-ctx.Done():
+		conn.Close()
+		return ctx.Err()
 	}
+}
 
-	log.Printf("[%d] Received query from [%s]: %s", queryPacket.Header.ID, clientAddr, queryPacket.Questions[0].CompactString())
+// beginRequest registers the request as in-flight, unless the server is shutting down
+func (v *UDPServer) beginRequest() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 
-	responsePacket, err := v.resolver.ResolveQuery(queryPacket)
-	if err != nil {
-		log.Printf("[%d] Could not perform the lookup: %s", queryPacket.Header.ID, err.Error())
+	if v.closing {
+		return false
+	}
 
-		response := v.resolver.QueryToErrResponse(queryPacket, common.SERVFAIL)
-		respBuf, _ := response.ToRawBuffer()
+	v.inFlight.Add(1)
 
-		go v.sendResponse(clientAddr, queryPacket.Header.ID, respBuf)
+	return true
+}
 
-		return
+func (v *UDPServer) finishRequest(buf *[]byte) {
+	v.buffers.Put(buf)
+	v.inFlight.Done()
+}
+
+func (v *UDPServer) worker() {
+	for req := range v.queue {
+		v.handleRequest(req.clientAddr, (*req.buf)[:req.length])
+		v.finishRequest(req.buf)
 	}
+}
 
-	responsePacketBuf, err := responsePacket.ToRawBuffer()
-	if err != nil {
-		log.Printf("[%d] Could not serialize response packet: %s", queryPacket.Header.ID, err.Error())
+func (v *UDPServer) handleRequest(clientAddr *net.UDPAddr, rawPacket []byte) {
+	v.sendResponse(clientAddr, v.handler.Handle(clientAddr, rawPacket, query_handler.UDP))
+}
 
-		resp := v.resolver.QueryToErrResponse(queryPacket, common.SERVFAIL)
-		respBuf, _ := resp.ToRawBuffer()
+// rejectRequest applies the queue full policy to the query which couldn't be queued
+func (v *UDPServer) rejectRequest(clientAddr *net.UDPAddr, rawPacket []byte) {
+	slog.Debug("Query queue is full, applying the policy", "client", clientAddr, "policy", v.cfg.QueueFullPolicy)
 
-		go v.sendResponse(clientAddr, queryPacket.Header.ID, respBuf)
+	switch v.cfg.QueueFullPolicy {
+	case REFUSED:
+		v.sendResponse(clientAddr, v.handler.Reject(rawPacket, common.REFUSED))
+	case SERVFAIL:
+		v.sendResponse(clientAddr, v.handler.Reject(rawPacket, common.SERVFAIL))
+	}
+}
 
+func (v *UDPServer) sendResponse(addr *net.UDPAddr, data []byte) {
+	if v.cfg.RateLimiter != nil {
+		data = v.cfg.RateLimiter.Limit(addr.IP, data)
+	}
+
+	if data == nil {
 		return
 	}
 
-	go v.sendResponse(clientAddr, responsePacket.Header.ID, responsePacketBuf)
-}
+	ID := responseID(data)
 
-func (v *UDPServer) sendResponse(addr *net.UDPAddr, ID uint16, data []byte) {
 	_, err := v.conn.WriteToUDP(data, addr)
 	if err != nil {
-		log.Printf("[%d] Couldn't send response %v", ID, err)
+		slog.Debug("Could not send response", "id", ID, "error", err)
 	} else {
-		log.Printf("[%d] Response sent to client", ID)
+		slog.Debug("Response sent to client", "id", ID)
+	}
+}
+
+func responseID(data []byte) uint16 {
+	if len(data) < 2 {
+		return 0
 	}
+
+	return uint16(data[0])<<8 | uint16(data[1])
 }
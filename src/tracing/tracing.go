@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/wiktor-mazur/dns-go"
+
+// span attributes describing DNS messages
+const (
+	Transport  = attribute.Key("dns.transport")
+	Client     = attribute.Key("dns.client")
+	Nameserver = attribute.Key("dns.nameserver")
+	QueryID    = attribute.Key("dns.id")
+	QueryName  = attribute.Key("dns.question.name")
+	QueryType  = attribute.Key("dns.question.type")
+	ResultCode = attribute.Key("dns.rcode")
+	RTT        = attribute.Key("dns.rtt_ms")
+)
+
+type Config struct {
+	Endpoint    string // URL of OTLP/HTTP collector, including the path, e.g. http://localhost:4318/v1/traces
+	ServiceName string
+	SampleRatio float64 // fraction of client queries which are traced
+}
+
+// Tracer returns the tracer used by dns-go; spans are not recorded until Setup is called
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup starts exporting spans to the OTLP collector. The returned function flushes the spans waiting to be exported,
+// and should be called before exiting.
+func Setup(cfg Config) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	serviceResource, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
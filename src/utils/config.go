@@ -1,26 +1,184 @@
 package utils
 
 import (
+	"errors"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Config struct {
+	LOG_LEVEL  string `default:"info"`
+	LOG_FORMAT string `default:"text"`
+
+	QUERY_LOG_ENABLED     bool   `default:"false"`
+	QUERY_LOG_FORMAT      string `default:"json"`
+	QUERY_LOG_FILE        string
+	QUERY_LOG_SAMPLE_RATE float64 `default:"1"`
+
 	UDP_ENABLED bool   `default:"true"`
 	UDP_IP      string `default:"0.0.0.0"`
 	UDP_PORT    int    `default:"8053"`
 
-	INTERNET_ROOT_SERVER string
+	UDP_WORKERS           int    `default:"256"`
+	UDP_QUEUE_SIZE        int    `default:"1024"`
+	UDP_QUEUE_FULL_POLICY string `default:"drop"`
+
+	RRL_ENABLED              bool          `default:"false"`
+	RRL_RESPONSES_PER_SECOND float64       `default:"20"`
+	RRL_SLIP                 int           `default:"2"`
+	RRL_WINDOW               time.Duration `default:"15s"`
+	RRL_IPV4_PREFIX_LENGTH   int           `default:"24"`
+	RRL_IPV6_PREFIX_LENGTH   int           `default:"56"`
+	RRL_EXEMPT               []string      `default:"127.0.0.0/8,::1"`
+	RRL_MAX_ENTRIES          int           `default:"100000"`
+
+	UDP_ACL_QUERY_ALLOW     []string
+	UDP_ACL_QUERY_DENY      []string
+	UDP_ACL_RECURSION_ALLOW []string
+	UDP_ACL_RECURSION_DENY  []string
+
+	TCP_ENABLED bool   `default:"true"`
+	TCP_IP      string `default:"0.0.0.0"`
+	TCP_PORT    int    `default:"8053"`
+
+	TCP_IDLE_TIMEOUT time.Duration `default:"10s"`
+
+	TCP_ACL_QUERY_ALLOW     []string
+	TCP_ACL_QUERY_DENY      []string
+	TCP_ACL_RECURSION_ALLOW []string
+	TCP_ACL_RECURSION_DENY  []string
+
+	TLS_ENABLED      bool          `default:"false"`
+	TLS_IP           string        `default:"0.0.0.0"`
+	TLS_PORT         int           `default:"853"`
+	TLS_IDLE_TIMEOUT time.Duration `default:"30s"`
+	TLS_CERT_FILE    string
+	TLS_KEY_FILE     string
+
+	TLS_ACL_QUERY_ALLOW     []string
+	TLS_ACL_QUERY_DENY      []string
+	TLS_ACL_RECURSION_ALLOW []string
+	TLS_ACL_RECURSION_DENY  []string
+
+	DOH_ENABLED         bool   `default:"false"`
+	DOH_IP              string `default:"0.0.0.0"`
+	DOH_PORT            int    `default:"443"`
+	DOH_TLS             bool   `default:"true"`
+	DOH_CERT_FILE       string
+	DOH_KEY_FILE        string
+	DOH_TRUSTED_PROXIES []string
+
+	DOH_ACL_QUERY_ALLOW     []string
+	DOH_ACL_QUERY_DENY      []string
+	DOH_ACL_RECURSION_ALLOW []string
+	DOH_ACL_RECURSION_DENY  []string
+
+	DOQ_ENABLED      bool          `default:"false"`
+	DOQ_IP           string        `default:"0.0.0.0"`
+	DOQ_PORT         int           `default:"853"`
+	DOQ_IDLE_TIMEOUT time.Duration `default:"30s"`
+	DOQ_CERT_FILE    string
+	DOQ_KEY_FILE     string
+
+	DOQ_ACL_QUERY_ALLOW     []string
+	DOQ_ACL_QUERY_DENY      []string
+	DOQ_ACL_RECURSION_ALLOW []string
+	DOQ_ACL_RECURSION_DENY  []string
+
+	DNSTAP_ENABLED     bool `default:"false"`
+	DNSTAP_SOCKET      string
+	DNSTAP_FILE        string
+	DNSTAP_IDENTITY    string
+	DNSTAP_BUFFER_SIZE int  `default:"10000"`
+	DNSTAP_CLIENT      bool `default:"true"`
+	DNSTAP_RESOLVER    bool `default:"true"`
+
+	TRACING_ENABLED       bool    `default:"false"`
+	TRACING_OTLP_ENDPOINT string  `default:"http://localhost:4318/v1/traces"`
+	TRACING_SERVICE_NAME  string  `default:"dns-go"`
+	TRACING_SAMPLE_RATIO  float64 `default:"1"`
+
+	METRICS_ENABLED bool   `default:"false"`
+	METRICS_IP      string `default:"127.0.0.1"`
+	METRICS_PORT    int    `default:"9153"`
+
+	ADMIN_ENABLED bool   `default:"false"`
+	ADMIN_IP      string `default:"127.0.0.1"`
+	ADMIN_PORT    int    `default:"8080"`
+	ADMIN_TOKEN   string
+
+	SHUTDOWN_TIMEOUT time.Duration `default:"5s"`
+
+	INTERNET_ROOT_SERVER string `default:"198.41.0.4"`
+	FORWARD_UPSTREAMS    []string
+
+	DNSSEC_VALIDATION    bool     `default:"true"`
+	DNSSEC_TRUST_ANCHORS []string `default:". 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D,. 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16"`
 }
 
+var (
+	envMu sync.Mutex
+	// osEnv holds names of variables provided by the OS, which take precedence over the .env file
+	osEnv map[string]bool
+	// fileEnv holds names of variables set from the .env file
+	fileEnv map[string]bool
+)
+
 func LoadConfig() (*Config, error) {
+	envMu.Lock()
+	defer envMu.Unlock()
+
+	osEnv = make(map[string]bool)
+	for _, value := range os.Environ() {
+		osEnv[strings.SplitN(value, "=", 2)[0]] = true
+	}
+
+	return loadConfig()
+}
+
+// ReloadConfig reads the .env file again, so that changed values can be applied without restart
+func ReloadConfig() (*Config, error) {
+	envMu.Lock()
+	defer envMu.Unlock()
+
+	return loadConfig()
+}
+
+func loadConfig() (*Config, error) {
 	result := &Config{}
 
-	err := godotenv.Load()
-	if err != nil {
+	// .env file is optional, as the config can be provided via OS's env vars only
+	values, err := godotenv.Read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, err
 	}
 
+	// variables removed from the file since the last load fall back to defaults
+	for key := range fileEnv {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+		}
+	}
+
+	fileEnv = make(map[string]bool)
+	for key, value := range values {
+		if osEnv[key] {
+			continue
+		}
+
+		err = os.Setenv(key, value)
+		if err != nil {
+			return nil, err
+		}
+
+		fileEnv[key] = true
+	}
+
 	err = envconfig.Process("", result)
 	if err != nil {
 		return nil, err
@@ -1,6 +1,10 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"strings"
+)
 
 type IPv4 struct {
 	Octets []byte
@@ -36,3 +40,51 @@ func (v *IPv6) String() string {
 		v.Data[0], v.Data[1], v.Data[2], v.Data[3], v.Data[4], v.Data[5], v.Data[6], v.Data[7], v.Data[8], v.Data[9], v.Data[10], v.Data[11], v.Data[12], v.Data[13], v.Data[14], v.Data[15],
 	)
 }
+
+// ParseCIDRs parses the list of network prefixes, where a single address is treated as a prefix of full length
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(values))
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", value)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, network)
+	}
+
+	return result, nil
+}
+
+// ContainsIP checks whether the address belongs to any of the networks
+func ContainsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}